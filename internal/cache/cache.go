@@ -0,0 +1,307 @@
+// Package cache implements a content-addressed cache of image root
+// filesystems, keyed by image digest.
+//
+// The cache directory layout is:
+//
+//	<dir>/<algo>-<hex>/rootfs          -- a copy of image root filesystem;
+//	<dir>/<algo>-<hex>/component.json  -- image OpenShift component labels.
+//
+// The modification time of <dir>/<algo>-<hex> is updated on every use,
+// and the least recently used entries are evicted once the cache size
+// exceeds the limit. The entries in use (see Get and Release) are never
+// evicted or replaced.
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+const (
+	rootfsDir     = "rootfs"
+	componentFile = "component.json"
+)
+
+var (
+	// mu serializes cache eviction, and protects inUse.
+	mu sync.Mutex
+	// inUse are the reference counts of the entries (by path) in use.
+	inUse = make(map[string]int)
+)
+
+type Cache struct {
+	dir     string
+	maxSize int64 // In bytes; 0 means no limit.
+}
+
+// New returns a cache in the directory dir, creating it if needed.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, maxSize: maxSize}, nil
+}
+
+// DigestFromRef returns the digest part of the image reference
+// (such as "quay.io/some/image@sha256:1234..."), or an empty string
+// if the reference is not by digest.
+func DigestFromRef(ref string) string {
+	if i := strings.LastIndexByte(ref, '@'); i != -1 {
+		return ref[i+1:]
+	}
+	return ""
+}
+
+func (c *Cache) entry(digest string) (string, error) {
+	algo, hex, ok := strings.Cut(digest, ":")
+	if !ok || algo == "" || hex == "" || strings.ContainsAny(digest, `/\.`) {
+		return "", fmt.Errorf("cache: invalid digest %q", digest)
+	}
+	return filepath.Join(c.dir, algo+"-"+hex), nil
+}
+
+// Get returns the root filesystem path and the component for the image
+// with a given digest, if it is found in the cache. The entry found is
+// in use (so it is not evicted) until Release is called.
+func (c *Cache) Get(digest string) (string, *types.OpenshiftComponent, bool) {
+	entry, err := c.entry(digest)
+	if err != nil {
+		return "", nil, false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	data, err := os.ReadFile(filepath.Join(entry, componentFile))
+	if err != nil {
+		// Either not cached or not completely written.
+		return "", nil, false
+	}
+	var component *types.OpenshiftComponent
+	if err := json.Unmarshal(data, &component); err != nil {
+		klog.Warningf("cache: bad entry %s: %v", entry, err)
+		return "", nil, false
+	}
+	// Mark as recently used.
+	now := time.Now()
+	_ = os.Chtimes(entry, now, now)
+	inUse[entry]++
+	klog.V(1).InfoS("cache hit", "digest", digest)
+
+	return filepath.Join(entry, rootfsDir), component, true
+}
+
+// Release tells the entry with a given digest, returned by Get, is no
+// longer in use.
+func (c *Cache) Release(digest string) {
+	entry, err := c.entry(digest)
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if inUse[entry]--; inUse[entry] <= 0 {
+		delete(inUse, entry)
+	}
+}
+
+// ErrTooLarge is returned by Put for a root filesystem larger than the
+// cache size limit, which would be evicted right away.
+var ErrTooLarge = errors.New("root filesystem is larger than the cache size limit")
+
+// Put copies the root filesystem from src to the cache, under a given
+// digest, and evicts the least recently used entries if needed. A root
+// filesystem larger than the cache size limit is not cached.
+func (c *Cache) Put(digest, src string, component *types.OpenshiftComponent) error {
+	entry, err := c.entry(digest)
+	if err != nil {
+		return err
+	}
+	if c.maxSize > 0 {
+		if size := dirSize(src); size > c.maxSize {
+			return fmt.Errorf("cache: %w (%d > %d bytes)", ErrTooLarge, size, c.maxSize)
+		}
+	}
+	// Copy to a temporary directory first, and then rename it,
+	// so a partially copied entry is never used.
+	tmp, err := os.MkdirTemp(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // No-op after a successful rename.
+
+	klog.V(1).InfoS("cache: copying rootfs", "digest", digest, "src", src)
+	if err := copyTree(src, filepath.Join(tmp, rootfsDir)); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	data, err := json.Marshal(component)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, componentFile), data, 0o600); err != nil {
+		return err
+	}
+	if err := c.replace(tmp, entry); err != nil {
+		return err
+	}
+
+	c.evict()
+	return nil
+}
+
+// replace renames tmp to entry, removing the old entry, if any (e.g. after
+// --no-cache), unless it is in use.
+func (c *Cache) replace(tmp, entry string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if inUse[entry] > 0 {
+		klog.V(1).InfoS("cache: not replacing the entry in use", "entry", entry)
+		return nil
+	}
+	if err := os.RemoveAll(entry); err != nil {
+		return err
+	}
+	return os.Rename(tmp, entry)
+}
+
+// evict removes the least recently used entries (not in use) until the
+// cache size is within the limit.
+func (c *Cache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	type entry struct {
+		path  string
+		size  int64
+		atime time.Time
+	}
+	dirs, err := os.ReadDir(c.dir)
+	if err != nil {
+		klog.Warningf("cache: can't evict: %v", err)
+		return
+	}
+	var entries []entry
+	var total int64
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(c.dir, d.Name())
+		size := dirSize(path)
+		entries = append(entries, entry{path: path, size: size, atime: info.ModTime()})
+		total += size
+	}
+	// Least recently used first.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].atime.Before(entries[j].atime)
+	})
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		if inUse[e.path] > 0 {
+			continue
+		}
+		klog.V(1).InfoS("cache: evicting", "entry", e.path, "size", e.size)
+		if err := os.RemoveAll(e.path); err != nil {
+			klog.Warningf("cache: can't evict: %v", err)
+			continue
+		}
+		total -= e.size
+	}
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil //nolint:nilerr // Best effort.
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// copyTree copies the directory tree from src to dst, preserving file modes.
+// Only directories, regular files, and symlinks are copied.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode()
+		switch {
+		case mode.IsDir():
+			// Make sure the directory is writable by owner, so
+			// it can be populated (and eventually removed).
+			if err := os.Mkdir(target, 0o700); err != nil {
+				return err
+			}
+			return os.Chmod(target, mode.Perm()|0o700)
+		case mode.IsRegular():
+			return copyFile(path, target, mode)
+		case mode&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		// Skip devices, sockets, pipes etc.
+		return nil
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// Preserve permissions along with setuid/setgid/sticky bits.
+	return os.Chmod(dst, mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky))
+}
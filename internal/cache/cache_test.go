@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// makeRoot returns a root filesystem with a single file of a given size.
+func makeRoot(t *testing.T, size int) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/bin/foo"), make([]byte, size), 0o755))
+	return root
+}
+
+// put is Cache.Put of a root filesystem with a file of a given size.
+func put(t *testing.T, c *Cache, digest string, size int) {
+	t.Helper()
+	require.NoError(t, c.Put(digest, makeRoot(t, size), &types.OpenshiftComponent{Component: digest}))
+}
+
+func has(c *Cache, digest string) bool {
+	_, _, ok := c.Get(digest)
+	if ok {
+		c.Release(digest)
+	}
+	return ok
+}
+
+func TestCacheGetPut(t *testing.T) {
+	c, err := New(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	_, _, ok := c.Get("sha256:1")
+	assert.False(t, ok)
+	put(t, c, "sha256:1", 1000)
+	root, component, ok := c.Get("sha256:1")
+	require.True(t, ok)
+	defer c.Release("sha256:1")
+	assert.Equal(t, "sha256:1", component.Component)
+	data, err := os.ReadFile(filepath.Join(root, "usr/bin/foo"))
+	require.NoError(t, err)
+	assert.Len(t, data, 1000)
+
+	assert.Error(t, c.Put("../x", makeRoot(t, 1), nil))
+}
+
+func TestCacheEvict(t *testing.T) {
+	c, err := New(t.TempDir(), 2500)
+	require.NoError(t, err)
+
+	put(t, c, "sha256:1", 1000)
+	time.Sleep(10 * time.Millisecond)
+	put(t, c, "sha256:2", 1000)
+	assert.True(t, has(c, "sha256:1"))
+	assert.True(t, has(c, "sha256:2"))
+
+	// The entry in use is not evicted, even though it is the least
+	// recently used one.
+	_, _, ok := c.Get("sha256:1")
+	require.True(t, ok)
+	entry1, _ := c.entry("sha256:1")
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(entry1, old, old))
+	put(t, c, "sha256:3", 1000)
+	assert.True(t, has(c, "sha256:1"))
+	assert.False(t, has(c, "sha256:2"))
+	assert.True(t, has(c, "sha256:3"))
+
+	// Once released, it is.
+	c.Release("sha256:1")
+	require.NoError(t, os.Chtimes(entry1, old, old))
+	put(t, c, "sha256:4", 1000)
+	assert.False(t, has(c, "sha256:1"))
+	assert.True(t, has(c, "sha256:4"))
+
+	// An entry larger than the limit is not cached at all.
+	assert.ErrorIs(t, c.Put("sha256:5", makeRoot(t, 3000), nil), ErrTooLarge)
+	assert.False(t, has(c, "sha256:5"))
+	assert.True(t, has(c, "sha256:3"))
+	assert.True(t, has(c, "sha256:4"))
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c, err := New(t.TempDir(), 2500)
+	require.NoError(t, err)
+	put(t, c, "sha256:0", 1000)
+
+	// Scans of the cached entry, while other entries are added.
+	var roots []string
+	for i := 0; i < 4; i++ {
+		root, _, ok := c.Get("sha256:0")
+		require.True(t, ok)
+		roots = append(roots, root)
+	}
+	var wg sync.WaitGroup
+	for i, root := range roots {
+		wg.Add(2)
+		go func(root string) {
+			defer wg.Done()
+			defer c.Release("sha256:0")
+			for j := 0; j < 10; j++ {
+				_, err := os.Stat(filepath.Join(root, "usr/bin/foo"))
+				assert.NoError(t, err)
+				time.Sleep(time.Millisecond)
+			}
+		}(root)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, c.Put(fmt.Sprintf("sha256:%d", i+1), makeRoot(t, 1000), nil))
+		}(i)
+	}
+	wg.Wait()
+	assert.Empty(t, inUse)
+}
@@ -0,0 +1,138 @@
+// Package logging implements the log formats for klog.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+// Setup configures klog to write logs in a given format, which is either
+// "text" (klog default), or "json" (a JSON object per line, to stderr).
+func Setup(format string) error {
+	switch format {
+	case "text", "":
+	case "json":
+		klog.SetLogger(NewJSONLogger(os.Stderr))
+	default:
+		return fmt.Errorf("log format %q is not supported (use text or json)", format)
+	}
+	return nil
+}
+
+// NewJSONLogger returns a logger writing JSON lines to w. Every line is
+// an object with "ts", "level" ("info" or "error"), "v" (the verbosity
+// level), "msg", and "err" (for errors) fields, followed by the key/value
+// pairs passed to the logger. Verbosity checks are done by klog.
+func NewJSONLogger(w io.Writer) logr.Logger {
+	return logr.New(&jsonSink{mu: &sync.Mutex{}, w: w, now: time.Now})
+}
+
+type jsonSink struct {
+	mu     *sync.Mutex // Shared by all derived sinks.
+	w      io.Writer
+	now    func() time.Time
+	name   string
+	values []interface{}
+}
+
+func (s *jsonSink) Init(logr.RuntimeInfo) {}
+
+func (s *jsonSink) Enabled(int) bool {
+	return true
+}
+
+func (s *jsonSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.write("info", level, nil, msg, keysAndValues)
+}
+
+func (s *jsonSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.write("error", 0, err, msg, keysAndValues)
+}
+
+func (s *jsonSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	c := *s
+	c.values = append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &c
+}
+
+func (s *jsonSink) WithName(name string) logr.LogSink {
+	c := *s
+	if c.name != "" {
+		name = c.name + "/" + name
+	}
+	c.name = name
+	return &c
+}
+
+func (s *jsonSink) write(level string, v int, err error, msg string, keysAndValues []interface{}) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	writeKV(&b, "ts", s.now().UTC().Format(time.RFC3339Nano))
+	writeKV(&b, "level", level)
+	writeKV(&b, "v", v)
+	if s.name != "" {
+		writeKV(&b, "logger", s.name)
+	}
+	// Messages of non-structured klog calls end with a newline.
+	writeKV(&b, "msg", strings.TrimSuffix(msg, "\n"))
+	if err != nil {
+		writeKV(&b, "err", err)
+	}
+	for _, kvs := range [][]interface{}{s.values, keysAndValues} {
+		for i := 0; i < len(kvs); i += 2 {
+			key := fmt.Sprint(kvs[i])
+			if i+1 == len(kvs) {
+				writeKV(&b, key, "(MISSING)")
+				break
+			}
+			writeKV(&b, key, kvs[i+1])
+		}
+	}
+	b.WriteString("}\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.w.Write(b.Bytes())
+}
+
+// writeKV appends a JSON object member to b.
+func writeKV(b *bytes.Buffer, key string, value interface{}) {
+	if b.Len() > 1 {
+		b.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	b.Write(k)
+	b.WriteByte(':')
+	b.Write(jsonValue(value))
+}
+
+// jsonValue marshals the value, using the error message for errors,
+// and the string representation for values that can't be marshaled.
+// A nil pointer (which may implement error or fmt.Stringer, but panic when
+// called) is marshaled as null.
+func jsonValue(value interface{}) []byte {
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return []byte("null")
+	}
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case fmt.Stringer:
+		value = v.String()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", value))
+	}
+	return data
+}
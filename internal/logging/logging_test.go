@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	log := logr.New(&jsonSink{mu: &sync.Mutex{}, w: &buf, now: func() time.Time { return ts }})
+
+	log.V(1).Info("scanning success", "path", "/bin/foo", "status", "success")
+	log.WithValues("image", "quay.io/foo").Error(errors.New("boom"), "scan failed", "count", 2, "odd")
+	log.Info("plain message\n")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	want := []map[string]interface{}{
+		{"ts": "2023-01-02T03:04:05Z", "level": "info", "v": 1.0, "msg": "scanning success", "path": "/bin/foo", "status": "success"},
+		{"ts": "2023-01-02T03:04:05Z", "level": "error", "v": 0.0, "msg": "scan failed", "err": "boom", "image": "quay.io/foo", "count": 2.0, "odd": "(MISSING)"},
+		{"ts": "2023-01-02T03:04:05Z", "level": "info", "v": 0.0, "msg": "plain message"},
+	}
+	for i, line := range lines {
+		var got map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &got), line)
+		assert.Equal(t, want[i], got)
+	}
+}
+
+func TestJSONValueNil(t *testing.T) {
+	var u *url.URL // A fmt.Stringer.
+	var err *os.PathError
+	assert.Equal(t, "null", string(jsonValue(u)))
+	assert.Equal(t, "null", string(jsonValue(err)))
+	assert.Equal(t, "null", string(jsonValue(nil)))
+}
+
+func TestSetup(t *testing.T) {
+	assert.NoError(t, Setup("text"))
+	assert.Error(t, Setup("xml"))
+}
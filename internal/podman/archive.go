@@ -0,0 +1,79 @@
+package podman
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Podman transports for image archives.
+const (
+	TransportOCIArchive    = "oci-archive"
+	TransportDockerArchive = "docker-archive"
+)
+
+// ArchiveTransport inspects the tarball and returns a podman transport
+// to use for it, i.e. TransportOCIArchive or TransportDockerArchive.
+// An error is returned if the file is neither.
+func ArchiveTransport(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var isOCI, isDocker bool
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: not a valid tar archive: %w", file, err)
+		}
+		switch path.Clean(hdr.Name) {
+		case "oci-layout":
+			isOCI = true
+		case "manifest.json":
+			isDocker = true
+		}
+	}
+	// Newer docker versions save archives having both oci-layout and
+	// manifest.json, in which case OCI is preferred.
+	if isOCI {
+		return TransportOCIArchive, nil
+	}
+	if isDocker {
+		return TransportDockerArchive, nil
+	}
+	return "", fmt.Errorf("%s: neither an OCI (no oci-layout) nor a docker archive (no manifest.json)", file)
+}
+
+// PullArchive loads the image from an OCI or docker archive file into local
+// storage, and returns the image ID. The transport is the one returned by
+// ArchiveTransport for the file; if empty, the file is inspected to find it.
+// Only the TempDir field of opts is used.
+func PullArchive(ctx context.Context, file, transport string, opts *PullOptions) (string, error) {
+	if transport == "" {
+		var err error
+		if transport, err = ArchiveTransport(file); err != nil {
+			return "", err
+		}
+	}
+	stdout, err := runPodmanEnv(ctx, opts.env(), "pull", "--quiet", transport+":"+file)
+	if err != nil {
+		return "", err
+	}
+	// With --quiet, podman only prints the image ID.
+	id := strings.TrimSpace(stdout.String())
+	if id == "" {
+		return "", fmt.Errorf("%s: can't get image id from podman pull", file)
+	}
+	return id, nil
+}
@@ -0,0 +1,56 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ContainerInfo is the container information needed for scanning.
+type ContainerInfo struct {
+	ID      string
+	Image   string // Image ID.
+	Running bool
+	// MergedDir is the container root filesystem (only available
+	// if the container is running or mounted).
+	MergedDir string
+}
+
+// InspectContainer returns information about a container given its name or ID.
+func InspectContainer(ctx context.Context, container string) (*ContainerInfo, error) {
+	stdout, err := runPodman(ctx, "container", "inspect", "--format",
+		`{{.Id}}|{{.Image}}|{{.State.Running}}|{{index .GraphDriver.Data "MergedDir"}}`, container)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.TrimSpace(stdout.String()), "|")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("container %s: unexpected podman inspect output: %q", container, stdout.String())
+	}
+	info := &ContainerInfo{
+		ID:        parts[0],
+		Image:     parts[1],
+		Running:   parts[2] == "true",
+		MergedDir: parts[3],
+	}
+	if info.MergedDir == "<no value>" {
+		info.MergedDir = ""
+	}
+	return info, nil
+}
+
+// MountContainer mounts the container root filesystem, and returns its path.
+func MountContainer(ctx context.Context, id string) (string, error) {
+	stdout, err := runPodman(ctx, "container", "mount", id)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// UnmountContainer unmounts the container root filesystem
+// mounted by MountContainer.
+func UnmountContainer(ctx context.Context, id string) error {
+	_, err := runPodman(ctx, "container", "unmount", id)
+	return err
+}
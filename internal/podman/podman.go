@@ -3,10 +3,12 @@ package podman
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strings"
 
+	"github.com/openshift/check-payload/internal/proc"
 	"github.com/openshift/check-payload/internal/types"
 	"k8s.io/klog/v2"
 )
@@ -27,20 +29,113 @@ func Mount(ctx context.Context, id string) (string, error) {
 	return strings.TrimSpace(stdout.String()), nil
 }
 
-func Pull(ctx context.Context, image string, insecure bool) error {
+// PullOptions are the options for Pull.
+type PullOptions struct {
+	// Insecure disables TLS verification.
+	Insecure bool
+	// AuthFile is the registry credentials file (in docker config.json
+	// format). If empty, podman default is used.
+	AuthFile string
+	// Arch is the architecture to pull (from a manifest list).
+	// If empty, podman default (the host architecture) is used.
+	Arch string
+	// Env is a list of additional environment variables, in "key=value"
+	// form, to set for podman (such as proxy settings).
+	Env []string
+	// TempDir, if set, is the directory for podman temporary files (such
+	// as image layers being pulled).
+	TempDir string
+}
+
+// env returns the additional environment variables for podman.
+func (o *PullOptions) env() []string {
+	if o.TempDir == "" {
+		return o.Env
+	}
+	return append(o.Env[:len(o.Env):len(o.Env)], "TMPDIR="+o.TempDir)
+}
+
+func Pull(ctx context.Context, image string, opts *PullOptions) error {
 	args := []string{"pull"}
-	if insecure {
+	if opts.Insecure {
 		args = append(args, "--tls-verify=false")
 	}
+	if opts.AuthFile != "" {
+		args = append(args, "--authfile", opts.AuthFile)
+	}
+	if opts.Arch != "" {
+		args = append(args, "--arch", opts.Arch)
+	}
 	args = append(args, image)
 
-	_, err := runPodman(ctx, args...)
+	_, err := runPodmanEnv(ctx, opts.env(), args...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Platform is a platform of an image in a manifest list.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// ManifestPlatforms returns the platforms of images in a manifest list
+// (which is looked up in the registry, if not available locally), or
+// nil if image is not a manifest list. Only Insecure, AuthFile, and Env
+// fields of opts are used.
+func ManifestPlatforms(ctx context.Context, image string, opts *PullOptions) ([]Platform, error) {
+	args := []string{"manifest", "inspect"}
+	if opts.Insecure {
+		args = append(args, "--tls-verify=false")
+	}
+	if opts.AuthFile != "" {
+		args = append(args, "--authfile", opts.AuthFile)
+	}
+	args = append(args, image)
+
+	stdout, err := runPodmanEnv(ctx, opts.Env, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifestPlatforms(stdout.Bytes())
+}
+
+// parseManifestPlatforms parses the output of podman manifest inspect.
+func parseManifestPlatforms(data []byte) ([]Platform, error) {
+	var list struct {
+		Manifests []struct {
+			Platform *Platform `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("can't parse manifest: %w", err)
+	}
+	var platforms []Platform
+	for _, m := range list.Manifests {
+		if m.Platform != nil {
+			platforms = append(platforms, *m.Platform)
+		}
+	}
+	return platforms, nil
+}
+
+// RemoveImage removes the image from local storage.
+func RemoveImage(ctx context.Context, id string) error {
+	_, err := runPodman(ctx, "image", "rm", id)
+	return err
+}
+
 func Inspect(ctx context.Context, image string, args ...string) (string, error) {
 	cmdArgs := append([]string{"inspect", image}, args...)
 	stdout, err := runPodman(ctx, cmdArgs...)
@@ -50,14 +145,63 @@ func Inspect(ctx context.Context, image string, args ...string) (string, error)
 	return stdout.String(), nil
 }
 
+// ImageDigest returns the digest of a local image.
+func ImageDigest(ctx context.Context, image string) (string, error) {
+	stdout, err := runPodman(ctx, "image", "inspect", "--format", "{{.Digest}}", image)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ImageArch returns the architecture of a local image.
+func ImageArch(ctx context.Context, image string) (string, error) {
+	stdout, err := runPodman(ctx, "image", "inspect", "--format", "{{.Architecture}}", image)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ImageDiff returns the paths of files (and directories) which were
+// added or changed in image compared to base, according to their layers.
+// Both images must be available locally.
+func ImageDiff(ctx context.Context, base, image string) ([]string, error) {
+	stdout, err := runPodman(ctx, "image", "diff", base, image)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		// Each line is "<A|C|D> <path>".
+		kind, path, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if kind == "A" || kind == "C" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
 func runPodman(ctx context.Context, args ...string) (bytes.Buffer, error) {
+	return runPodmanEnv(ctx, nil, args...)
+}
+
+// runPodmanEnv is like runPodman but also sets the additional environment
+// variables. Note that env is not logged as it may contain credentials.
+func runPodmanEnv(ctx context.Context, env []string, args ...string) (bytes.Buffer, error) {
 	klog.V(1).InfoS("podman "+args[0], "args", args[1:])
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	cmd := exec.CommandContext(ctx, "podman", args...)
+	cmd := exec.Command("podman", args...)
+	if len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
+	if err := proc.Run(ctx, cmd); err != nil {
 		return stdout, fmt.Errorf("podman error (args=%v) (stderr=%v) (error=%w)", args, stderr.String(), err)
 	}
 	return stdout, nil
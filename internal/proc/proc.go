@@ -0,0 +1,49 @@
+// Package proc runs external commands so that they do not outlive the
+// context they are run with.
+package proc
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// Run starts the command in a new process group and waits for it to
+// finish. If ctx is done before the command finishes, the whole process
+// group (that is, the command and any processes it has started) is
+// killed with SIGKILL, and ctx.Err() is returned.
+//
+// The cmd should be created using exec.Command, not exec.CommandContext.
+func Run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	killed := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Negative pid means the process group.
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			close(killed)
+		case <-done:
+		}
+	}()
+
+	err := cmd.Wait()
+	close(done)
+	select {
+	case <-killed:
+		return ctx.Err()
+	default:
+	}
+	return err
+}
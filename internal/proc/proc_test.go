@@ -0,0 +1,39 @@
+package proc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	if err := Run(context.Background(), exec.Command("true")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Run(context.Background(), exec.Command("false")); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
+
+func TestRunKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The shell starts a child which would outlive the shell if only
+	// the shell itself was killed; Wait would then block on its stdout.
+	cmd := exec.Command("sh", "-c", "sleep 30; true")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	start := time.Now()
+	err := Run(ctx, cmd)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("took too long (%v), process group not killed?", elapsed)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"strings"
 
 	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/proc"
 )
 
 type Info struct {
@@ -24,11 +26,11 @@ func GetFilesFromRPM(ctx context.Context, root, rpm string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	cmd := exec.CommandContext(ctx, "rpm", "-ql", "--dbpath", dbpath, "--root", root, rpm)
+	cmd := exec.Command("rpm", "-ql", "--dbpath", dbpath, "--root", root, rpm)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
+	if err := proc.Run(ctx, cmd); err != nil {
 		return nil, fmt.Errorf("rpm -ql error: %w (stderr=%v)", err, stderr.String())
 	}
 
@@ -40,17 +42,48 @@ func GetFilesFromRPM(ctx context.Context, root, rpm string) ([]string, error) {
 	return files, nil
 }
 
+// GetAllFiles returns all files from all rpm packages installed under
+// root, as a map of file paths to package names. It is cheaper than
+// calling GetFilesFromRPM for every package.
+func GetAllFiles(ctx context.Context, root string) (map[string]string, error) {
+	klog.Info("rpm -qa --qf [%{FILENAMES}]")
+	dbpath, err := rpmDBPath(root)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("rpm", "-qa", "--dbpath", dbpath, "--root", root, "--qf", "[%{NAME} %{FILENAMES}\n]")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := proc.Run(ctx, cmd); err != nil {
+		return nil, fmt.Errorf("rpm -qa error: %w (stderr=%v)", err, stderr.String())
+	}
+	files := make(map[string]string)
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		name, file, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || file == "" {
+			continue
+		}
+		files[file] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rpm -qa: %w", err)
+	}
+	return files, nil
+}
+
 func GetAllRPMs(ctx context.Context, root string) ([]Info, error) {
 	klog.Info("rpm -qa")
 	dbpath, err := rpmDBPath(root)
 	if err != nil {
 		return nil, err
 	}
-	cmd := exec.CommandContext(ctx, "rpm", "-qa", "--dbpath", dbpath, "--root", root, "--qf", "%{NAME} %{NVRA}\n")
+	cmd := exec.Command("rpm", "-qa", "--dbpath", dbpath, "--root", root, "--qf", "%{NAME} %{NVRA}\n")
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
+	if err := proc.Run(ctx, cmd); err != nil {
 		return nil, fmt.Errorf("rpm -qa error: %w (stderr=%v)", err, stderr.String())
 	}
 	rpms := []Info{}
@@ -94,13 +127,13 @@ func NameFromFile(ctx context.Context, root, path string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	cmd := exec.CommandContext(ctx, "rpm", "-qf", "--dbpath", dbpath, "--root", root, "--queryformat=%{NAME}", path)
+	cmd := exec.Command("rpm", "-qf", "--dbpath", dbpath, "--root", root, "--queryformat=%{NAME}", path)
 	cmd.Env = append(cmd.Environ(), "LANG=C") // Do not localize error messages.
 	var outbuf, errbuf bytes.Buffer
 	cmd.Stdout = &outbuf
 	cmd.Stderr = &errbuf
 
-	if err := cmd.Run(); err != nil {
+	if err := proc.Run(ctx, cmd); err != nil {
 		errB := errbuf.Bytes()
 		// If the file does not belong to any package, do not return an error.
 		if bytes.Contains(errB, []byte("is not owned by any package")) ||
@@ -0,0 +1,221 @@
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+	"github.com/openshift/check-payload/internal/validations"
+)
+
+// archiveSep separates the archive path from the path of a file inside
+// it in scan results, such as "/opt/foo.tar!/bin/foo".
+const archiveSep = "!"
+
+// Supported archive kinds.
+const (
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+	archiveZip   = "zip" // Including jar.
+)
+
+var errArchiveTooBig = errors.New("archive extracted size limit exceeded")
+
+// archiveKind detects if the file is an archive by its contents, and returns
+// the archive kind, or an empty string if the file is not a supported archive.
+func archiveKind(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	head, err := r.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return archiveZip, nil
+	case isTarHeader(head):
+		return archiveTar, nil
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return "", nil
+		}
+		defer zr.Close()
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(zr, head)
+		if isTarHeader(head[:n]) {
+			return archiveTarGz, nil
+		}
+	}
+	return "", nil
+}
+
+// isTarHeader tells if the data starts with a POSIX or GNU tar header.
+func isTarHeader(head []byte) bool {
+	return len(head) >= 262 && string(head[257:262]) == "ustar"
+}
+
+// extractArchive extracts regular files from the archive of a given kind
+// to dir. The total size of extracted files is limited to *budget, which is
+// decreased accordingly; errArchiveTooBig is returned if it is exceeded.
+func extractArchive(path, kind, dir string, budget *int64) error {
+	if kind == archiveZip {
+		return extractZip(path, dir, budget)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if kind == archiveTarGz {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			// Directories are created as needed; links,
+			// devices etc. are skipped.
+			continue
+		}
+		if err := extractFile(tr, dir, hdr.Name, hdr.FileInfo().Mode(), budget); err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(path, dir string, budget *int64) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(r, dir, zf.Name, zf.Mode(), budget)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractFile writes the contents of a single archive member to dir.
+func extractFile(r io.Reader, dir, name string, mode fs.FileMode, budget *int64) error {
+	// Do not let the member be written outside of dir.
+	name = filepath.Join("/", name)
+	dst := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	// Read one more byte than allowed to detect an excess.
+	n, err := io.Copy(f, io.LimitReader(r, *budget+1))
+	*budget -= n
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && *budget < 0 {
+		err = errArchiveTooBig
+	}
+	return err
+}
+
+// scanArchive extracts the archive file (found at innerPath) to a temporary
+// directory, and scans all the files in it, including nested archives (up to
+// cfg.ArchiveMaxDepth levels deep). The outer is the path of the outermost
+// archive, and budget is the remaining extracted size limit.
+func scanArchive(ctx context.Context, cfg *types.Config, topDir, outer, file, innerPath, kind string, depth int, budget *int64, disabledChecks []string, errIgnores ...types.ErrIgnoreList) []*types.ScanResult {
+	dir, remove, err := createTempDir("archive-", cfg.KeepTemp)
+	if err != nil {
+		return []*types.ScanResult{types.NewScanResult().SetPath(innerPath).SetError(&OperationalError{err})}
+	}
+	defer func() {
+		if cfg.KeepTemp {
+			klog.InfoS("keeping extracted archive", "archive", innerPath, "path", dir)
+		}
+		remove()
+	}()
+
+	klog.V(1).InfoS("extracting archive", "archive", innerPath, "kind", kind, "depth", depth)
+	if err := extractArchive(file, kind, dir, budget); err != nil {
+		// Do not scan a partially extracted archive.
+		res := types.NewScanResult().SetPath(innerPath).SetError(fmt.Errorf("can't extract archive: %w", err))
+		res.Error.SetWarning()
+		return []*types.ScanResult{res}
+	}
+
+	var results []*types.ScanResult
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		memberPath := innerPath + archiveSep + strings.TrimPrefix(path, dir)
+		// Archive members are often not executable (e.g. in zip
+		// files), so try them all; non-ELF files are skipped.
+		res := withBinaryTimeout(ctx, cfg, memberPath, func(ctx context.Context) *types.ScanResult {
+			return validations.ScanArchiveMember(ctx, cfg, topDir, outer, path, memberPath, disabledChecks, errIgnores...)
+		})
+		// Keep the files skipped for a reason (such as core dumps).
+		if !res.Skip || res.SkipReason != "" {
+			results = append(results, res)
+			return nil
+		}
+		if depth >= cfg.ArchiveMaxDepth {
+			return nil
+		}
+		if kind, _ := archiveKind(path); kind != "" {
+			results = append(results, scanArchive(ctx, cfg, topDir, outer, path, memberPath, kind, depth+1, budget, disabledChecks, errIgnores...)...)
+		}
+		return nil
+	})
+	if err != nil {
+		results = append(results, types.NewScanResult().SetPath(innerPath).SetError(&OperationalError{err}))
+	}
+	return results
+}
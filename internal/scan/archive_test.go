@@ -0,0 +1,132 @@
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// writeTar writes a tar (optionally gzipped) archive with the files.
+func writeTar(t *testing.T, path string, gz bool, files map[string][]byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var w io.Writer = f
+	if gz {
+		zw := gzip.NewWriter(f)
+		defer zw.Close()
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
+
+func writeZip(t *testing.T, path string, files map[string][]byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+}
+
+func TestArchiveKind(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{"bin/foo": []byte("foo")}
+	writeTar(t, filepath.Join(dir, "a.tar"), false, files)
+	writeTar(t, filepath.Join(dir, "a.tgz"), true, files)
+	writeZip(t, filepath.Join(dir, "a.jar"), files)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "text"), []byte("hello"), 0o644))
+
+	for name, want := range map[string]string{
+		"a.tar": archiveTar,
+		"a.tgz": archiveTarGz,
+		"a.jar": archiveZip,
+		"text":  "",
+	} {
+		kind, err := archiveKind(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, kind, name)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "a.tar.gz")
+	writeTar(t, archive, true, map[string][]byte{
+		"bin/foo":          []byte("foo"),
+		"../../etc/passwd": []byte("bar"),
+	})
+
+	out := filepath.Join(dir, "out")
+	budget := int64(100)
+	require.NoError(t, extractArchive(archive, archiveTarGz, out, &budget))
+	assert.Equal(t, int64(94), budget)
+	assert.FileExists(t, filepath.Join(out, "bin/foo"))
+	// Not written outside of out.
+	assert.FileExists(t, filepath.Join(out, "etc/passwd"))
+
+	budget = 4
+	err := extractArchive(archive, archiveTarGz, filepath.Join(dir, "out2"), &budget)
+	assert.ErrorIs(t, err, errArchiveTooBig)
+}
+
+func TestScanArchive(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	nested := filepath.Join(dir, "nested.jar")
+	writeZip(t, nested, map[string][]byte{"lib/true": exe})
+	nestedData, err := os.ReadFile(nested)
+	require.NoError(t, err)
+	archive := filepath.Join(dir, "a.tar")
+	writeTar(t, archive, false, map[string][]byte{
+		"README":         []byte("not a binary"),
+		"lib/nested.jar": nestedData,
+		"bin/true":       exe,
+		"empty":          nil,
+	})
+
+	scan := func(maxDepth int) []string {
+		cfg := &types.Config{ArchiveMaxDepth: maxDepth, Checks: []string{"dyn-linked"}}
+		budget := int64(1 << 30)
+		var paths []string
+		for _, res := range scanArchive(context.Background(), cfg, "/", "/opt/a.tar", archive, "/opt/a.tar", archiveTar, 1, &budget, nil) {
+			paths = append(paths, res.Path)
+		}
+		return paths
+	}
+	assert.ElementsMatch(t, []string{"/opt/a.tar!/bin/true"}, scan(1))
+	assert.ElementsMatch(t, []string{"/opt/a.tar!/bin/true", "/opt/a.tar!/lib/nested.jar!/lib/true"}, scan(2))
+
+	// Temporary directories are removed.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
@@ -0,0 +1,154 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// Baseline is a set of known failures (see --baseline), which are
+// reported as warnings rather than failures.
+type Baseline struct {
+	file    string
+	entries map[baselineEntry]bool
+	// downgraded are the results downgraded to warnings by Apply.
+	downgraded map[*types.ScanResult]bool
+}
+
+// baselineFile is the baseline file format.
+type baselineFile struct {
+	Failures []baselineEntry `json:"failures"`
+}
+
+// baselineEntry is a known failure. The Image is the payload tag name,
+// if known (since image digests change from one release to another), or
+// the image pull spec (or empty, for node scans).
+type baselineEntry struct {
+	Image string `json:"image"`
+	Path  string `json:"path"`
+}
+
+func newBaselineEntry(tag, image, path string) baselineEntry {
+	if tag != "" {
+		image = tag
+	}
+	return baselineEntry{Image: image, Path: path}
+}
+
+// LoadBaseline reads the baseline file.
+func LoadBaseline(file string) (*Baseline, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var bf baselineFile
+	if err := json.Unmarshal(data, &bf); err != nil {
+		return nil, fmt.Errorf("%s: can't parse baseline: %w", file, err)
+	}
+	b := &Baseline{
+		file:       file,
+		entries:    make(map[baselineEntry]bool, len(bf.Failures)),
+		downgraded: make(map[*types.ScanResult]bool),
+	}
+	for _, e := range bf.Failures {
+		b.entries[e] = true
+	}
+	klog.V(1).InfoS("baseline loaded", "file", file, "failures", len(b.entries))
+	return b, nil
+}
+
+// Has tells if the result is a failure found in the baseline.
+// Operational errors are never considered known.
+func (b *Baseline) Has(res *types.ScanResult) bool {
+	if !res.IsLevel(types.Error) || res.Path == "" {
+		return false
+	}
+	var opErr *OperationalError
+	if errors.As(res.Error.Error, &opErr) {
+		return false
+	}
+	return b.entries[newBaselineEntry(getTag(res), getImage(res), res.Path)]
+}
+
+// Apply downgrades the failures found in the baseline to warnings, and
+// returns the number of those. The failures at required paths (see
+// types.Config.RequiredRule) are never downgraded.
+func (b *Baseline) Apply(cfg *types.Config, results []*types.ScanResults) int {
+	n := 0
+	for _, result := range results {
+		for _, res := range result.Items {
+			if !b.Has(res) || cfg.RefuseException(res.Path, res.Error.Error, "baseline "+b.file) {
+				continue
+			}
+			res.Error.SetWarning()
+			res.AddException("baseline " + b.file)
+			b.downgraded[res] = true
+			n++
+		}
+	}
+	if n > 0 {
+		klog.InfoS("known failures downgraded to warnings", "baseline", b.file, "count", n)
+	}
+	return n
+}
+
+// IsWarnings is like scan.IsWarnings, except that the failures downgraded
+// to warnings by Apply are not considered (so the known failures don't fail
+// the run with --fail-on-warnings either).
+func (b *Baseline) IsWarnings(results []*types.ScanResults) bool {
+	for _, result := range results {
+		for _, res := range result.Items {
+			if res.IsLevel(types.Warning) && !b.downgraded[res] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteBaseline writes the baseline with all failures found in the JSON
+// report (as produced by --output-format json) to outputFile, or to stdout
+// if outputFile is empty.
+func WriteBaseline(reportFile, outputFile string) error {
+	report, err := readJSONReport(reportFile)
+	if err != nil {
+		return err
+	}
+	seen := make(map[baselineEntry]bool)
+	bf := baselineFile{Failures: []baselineEntry{}}
+	for _, jr := range report.Results {
+		if jr.Status != "failed" || jr.Path == "" {
+			continue
+		}
+		e := newBaselineEntry(jr.Tag, jr.Image, jr.Path)
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		bf.Failures = append(bf.Failures, e)
+	}
+	sort.Slice(bf.Failures, func(i, j int) bool {
+		a, b := bf.Failures[i], bf.Failures[j]
+		if a.Image != b.Image {
+			return a.Image < b.Image
+		}
+		return a.Path < b.Path
+	})
+
+	data, err := json.MarshalIndent(&bf, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if outputFile == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(outputFile, data, 0o644)
+}
@@ -0,0 +1,111 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.json")
+	require.NoError(t, os.WriteFile(report, []byte(`{"results": [
+		{"tag": "foo", "image": "quay.io/foo@sha256:1", "path": "/bin/known", "status": "failed"},
+		{"tag": "foo", "image": "quay.io/foo@sha256:1", "path": "/bin/warn", "status": "warning"},
+		{"image": "quay.io/bar@sha256:2", "path": "/bin/known", "status": "failed"},
+		{"image": "quay.io/bar@sha256:2", "path": "/bin/ok", "status": "success", "success": true}
+	]}`), 0o644))
+	file := filepath.Join(dir, "baseline.json")
+	require.NoError(t, WriteBaseline(report, file))
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"failures": [
+		{"image": "foo", "path": "/bin/known"},
+		{"image": "quay.io/bar@sha256:2", "path": "/bin/known"}
+	]}`, string(data))
+
+	b, err := LoadBaseline(file)
+	require.NoError(t, err)
+
+	// A new release, so the image digest has changed.
+	tag := &v1.TagReference{Name: "foo", From: &corev1.ObjectReference{Name: "quay.io/foo@sha256:3"}}
+	known := types.NewScanResult().SetPath("/bin/known").SetTag(tag).SetError(types.ErrNotDynLinked)
+	unknown := types.NewScanResult().SetPath("/bin/new").SetTag(tag).SetError(types.ErrNotDynLinked)
+	opErr := types.NewScanResult().SetPath("/bin/known").SetTag(tag).SetError(&OperationalError{errors.New("boom")})
+	results := []*types.ScanResults{types.NewScanResults().Append(known).Append(unknown).Append(opErr)}
+
+	assert.Equal(t, 1, b.Apply(&types.Config{}, results))
+	assert.True(t, known.IsLevel(types.Warning))
+	assert.True(t, unknown.IsLevel(types.Error))
+	assert.True(t, opErr.IsLevel(types.Error))
+	assert.False(t, IsFailed([]*types.ScanResults{types.NewScanResults().Append(known)}))
+
+	// The known failures don't count as warnings for --fail-on-warnings.
+	assert.False(t, b.IsWarnings([]*types.ScanResults{types.NewScanResults().Append(known)}))
+	warn := types.NewScanResult().SetPath("/bin/warn").SetTag(tag).SetError(types.ErrNotDynLinked)
+	warn.Error.SetWarning()
+	assert.True(t, b.IsWarnings([]*types.ScanResults{types.NewScanResults().Append(known).Append(warn)}))
+
+	// Failures at required paths are never downgraded.
+	cfg := &types.Config{ConfigFile: types.ConfigFile{Required: []types.RequiredPaths{{Dirs: []string{"/bin"}}}}}
+	known = types.NewScanResult().SetPath("/bin/known").SetTag(tag).SetError(types.ErrNotDynLinked)
+	assert.Equal(t, 0, b.Apply(cfg, []*types.ScanResults{types.NewScanResults().Append(known)}))
+	assert.True(t, known.IsLevel(types.Error))
+}
+
+func TestBaselineDedupe(t *testing.T) {
+	tag := &v1.TagReference{Name: "foo", From: &corev1.ObjectReference{Name: "quay.io/foo@sha256:1"}}
+	fail := func(path string) *types.ScanResult {
+		return types.NewScanResult().SetPath(path).SetTag(tag).SetSHA256("11").SetError(types.ErrNotDynLinked)
+	}
+	orig := []*types.ScanResults{types.NewScanResults().SetTag(tag).
+		Append(fail("/usr/lib64/libfoo.so.1")).
+		Append(fail("/usr/lib/libfoo.so.1")).
+		Append(fail("/usr/lib64/libfoo.so.2"))}
+
+	dir := t.TempDir()
+	writeReport := func(name string, results []*types.ScanResults) string {
+		file := filepath.Join(dir, name)
+		f, err := os.Create(file)
+		require.NoError(t, err)
+		require.NoError(t, writeJSON(f, results, nil, nil))
+		require.NoError(t, f.Close())
+		return file
+	}
+	report := writeReport("report.json", filterResults(&types.Config{Dedupe: true}, orig))
+	full := writeReport("full.json", orig)
+
+	// A deduped report has the same failures as the full one.
+	oldReport, err := readJSONReport(report)
+	require.NoError(t, err)
+	newReport, err := readJSONReport(full)
+	require.NoError(t, err)
+	diff := diffReports(oldReport, newReport)
+	assert.Empty(t, diff.NewFailures)
+	assert.Empty(t, diff.Fixed)
+	assert.Len(t, diff.Unchanged, 3)
+
+	// A baseline made from a deduped report has all the paths.
+	file := filepath.Join(dir, "baseline.json")
+	require.NoError(t, WriteBaseline(report, file))
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"failures": [
+		{"image": "foo", "path": "/usr/lib/libfoo.so.1"},
+		{"image": "foo", "path": "/usr/lib64/libfoo.so.1"},
+		{"image": "foo", "path": "/usr/lib64/libfoo.so.2"}
+	]}`, string(data))
+
+	b, err := LoadBaseline(file)
+	require.NoError(t, err)
+	assert.Equal(t, 3, b.Apply(&types.Config{}, orig))
+	assert.False(t, IsFailed(orig))
+}
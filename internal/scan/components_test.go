@@ -0,0 +1,27 @@
+package scan
+
+import (
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestComponentPatterns(t *testing.T) {
+	tags := []v1.TagReference{{Name: "cluster-version-operator"}, {Name: "cluster-dns-operator"}, {Name: "etcd"}}
+
+	assert.NoError(t, checkComponentPatterns(nil, tags))
+	assert.NoError(t, checkComponentPatterns([]string{"cluster-*", "etcd"}, tags))
+	err := checkComponentPatterns([]string{"cluster-*", "etc", "cluster-foo*"}, tags)
+	assert.EqualError(t, err, "--components: no payload components match etc, cluster-foo*")
+	assert.Error(t, checkComponentPatterns([]string{"cluster-["}, tags))
+
+	cfg := &types.Config{Components: []string{"cluster-*", "etcd"}}
+	for _, tag := range tags {
+		assert.True(t, cfg.IsComponentSelected(tag.Name), tag.Name)
+	}
+	assert.False(t, cfg.IsComponentSelected("machine-config-operator"))
+	assert.True(t, (&types.Config{}).IsComponentSelected("machine-config-operator"))
+}
@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// configSummary is a number of config entries, used by verify-config.
+type configSummary struct {
+	// Sections are the global section, followed by [payload.*], [tag.*],
+	// and [rpm.*] sections, sorted by name, and [[component]] sections.
+	Sections     []configSection `json:"sections"`
+	FilterImages int             `json:"filter_images"`
+	FilterRPMs   int             `json:"filter_rpms"`
+	IncludeFiles int             `json:"include_files"`
+	IncludeDirs  int             `json:"include_dirs"`
+	Required     int             `json:"required"`
+}
+
+type configSection struct {
+	Name        string `json:"name"`
+	FilterFiles int    `json:"filter_files"`
+	FilterDirs  int    `json:"filter_dirs"`
+	Exceptions  int    `json:"exceptions"`
+	// DisableChecks is only set for [[component]] sections.
+	DisableChecks int `json:"disable_checks,omitempty"`
+}
+
+func newConfigSummary(cfg *types.ConfigFile) *configSummary {
+	sum := &configSummary{
+		Sections: []configSection{{
+			Name:        "global",
+			FilterFiles: len(cfg.FilterFiles),
+			FilterDirs:  len(cfg.FilterDirs),
+			Exceptions:  len(cfg.ErrIgnores),
+		}},
+		FilterImages: len(cfg.FilterImages),
+		FilterRPMs:   len(cfg.FilterRPMs),
+		IncludeFiles: len(cfg.IncludeFiles),
+		IncludeDirs:  len(cfg.IncludeDirs),
+		Required:     len(cfg.Required),
+	}
+	for _, s := range []struct {
+		prefix string
+		lists  map[string]types.IgnoreLists
+	}{
+		{"payload", cfg.PayloadIgnores},
+		{"tag", cfg.TagIgnores},
+		{"rpm", cfg.RPMIgnores},
+	} {
+		names := make([]string, 0, len(s.lists))
+		for name := range s.lists {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			l := s.lists[name]
+			sum.Sections = append(sum.Sections, configSection{
+				Name:        s.prefix + "." + name,
+				FilterFiles: len(l.FilterFiles),
+				FilterDirs:  len(l.FilterDirs),
+				Exceptions:  len(l.ErrIgnores),
+			})
+		}
+	}
+	for _, o := range cfg.ComponentOverrides {
+		sum.Sections = append(sum.Sections, configSection{
+			Name:          "component." + o.Name,
+			Exceptions:    len(o.ErrIgnores),
+			DisableChecks: len(o.DisableChecks),
+		})
+	}
+	return sum
+}
+
+// PrintConfigSummary prints the number of filters and exceptions in
+// each config section, in a given format.
+func PrintConfigSummary(cfg *types.Config, format string) error {
+	sum := newConfigSummary(&cfg.ConfigFile)
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sum)
+	}
+	switch format {
+	case "table", "csv", "markdown", "html":
+	default:
+		return fmt.Errorf("output format %q is not supported", format)
+	}
+
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Section", "Filter Files", "Filter Dirs", "Exceptions", "Disabled Checks"})
+	for _, s := range sum.Sections {
+		tw.AppendRow(table.Row{s.Name, s.FilterFiles, s.FilterDirs, s.Exceptions, s.DisableChecks})
+	}
+	fmt.Println(renderTable(tw, format))
+
+	tw = table.NewWriter()
+	tw.AppendHeader(table.Row{"Filter Images", "Filter RPMs", "Include Files", "Include Dirs", "Required"})
+	tw.AppendRow(table.Row{sum.FilterImages, sum.FilterRPMs, sum.IncludeFiles, sum.IncludeDirs, sum.Required})
+	fmt.Println(renderTable(tw, format))
+
+	return nil
+}
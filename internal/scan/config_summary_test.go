@@ -0,0 +1,31 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestNewConfigSummary(t *testing.T) {
+	cfg := &types.ConfigFile{
+		FilterFiles:  []string{"/a", "/b"},
+		FilterImages: []string{"img"},
+		RPMIgnores: map[string]types.IgnoreLists{
+			"foo": {ErrIgnores: types.ErrIgnoreList{{Files: []string{"/c"}}}},
+		},
+		PayloadIgnores: map[string]types.IgnoreLists{
+			"b": {FilterDirs: []string{"/d"}},
+			"a": {FilterFiles: []string{"/e"}},
+		},
+	}
+	sum := newConfigSummary(cfg)
+	assert.Equal(t, []configSection{
+		{Name: "global", FilterFiles: 2},
+		{Name: "payload.a", FilterFiles: 1},
+		{Name: "payload.b", FilterDirs: 1},
+		{Name: "rpm.foo", Exceptions: 1},
+	}, sum.Sections)
+	assert.Equal(t, 1, sum.FilterImages)
+}
@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/podman"
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// RunContainerScan scans the root filesystem of an existing (running
+// or stopped) container, given its name or ID.
+func RunContainerScan(ctx context.Context, cfg *types.Config, container string) []*types.ScanResults {
+	start := time.Now()
+	results := containerScan(ctx, cfg, container)
+	return []*types.ScanResults{results.SetTime(start, time.Now())}
+}
+
+func containerScan(ctx context.Context, cfg *types.Config, container string) *types.ScanResults {
+	info, err := podman.InspectContainer(ctx, container)
+	if err != nil {
+		return newResults(cfg, types.NewScanResult().SetError(&OperationalError{err}))
+	}
+
+	root := info.MergedDir
+	if !info.Running || root == "" {
+		// The rootfs is not available (the container is stopped), mount it.
+		root, err = podman.MountContainer(ctx, info.ID)
+		if err != nil {
+			return newResults(cfg, types.NewScanResult().SetError(&OperationalError{err}))
+		}
+		defer func() {
+			// Use a fresh context so the cleanup is done even
+			// if ctx is canceled or timed out.
+			if err := podman.UnmountContainer(context.Background(), info.ID); err != nil {
+				klog.Warningf("can't unmount container %s: %v", container, err)
+			}
+		}()
+	}
+	klog.InfoS("scanning container", "container", container, "root", root)
+
+	if cfg.UseRPMScan {
+		// Same as "scan node".
+		progress := startProgress(cfg, "rpms")
+		defer progress.Stop()
+		return rpmRootScan(ctx, cfg, root, progress, cfg.Parallelism)
+	}
+
+	// Use the container image component for per-component config rules.
+	component, _ := podman.GetOpenshiftComponentFromImage(ctx, info.Image)
+	progress := startProgress(cfg, "")
+	defer progress.Stop()
+	return walkDirScan(ctx, cfg, nil, component, root, nil, cfg.Parallelism)
+}
@@ -0,0 +1,210 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/proc"
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// credentialHelperPrefix is the name prefix of docker credential helpers.
+const credentialHelperPrefix = "docker-credential-"
+
+// parseCredentialHelper parses the credential helper rule in the
+// "[registry=]helper" form, where registry is a host name (or a shell
+// pattern, such as *.dkr.ecr.us-east-1.amazonaws.com), and helper is
+// either a helper name (such as ecr-login), or the helper binary (such
+// as docker-credential-ecr-login, or a path to it). A rule with no
+// registry is for all registries.
+func parseCredentialHelper(rule string) (registry, helper string, err error) {
+	registry, helper, ok := strings.Cut(rule, "=")
+	if !ok {
+		registry, helper = "*", registry
+	}
+	registry, helper = strings.TrimSpace(registry), strings.TrimSpace(helper)
+	if registry == "" || helper == "" {
+		return "", "", fmt.Errorf("invalid credential helper %q (want [registry=]helper)", rule)
+	}
+	if _, err := path.Match(registry, ""); err != nil {
+		return "", "", fmt.Errorf("invalid credential helper %q: %w", rule, err)
+	}
+	if !strings.Contains(helper, "/") && !strings.HasPrefix(helper, credentialHelperPrefix) {
+		helper = credentialHelperPrefix + helper
+	}
+	return registry, helper, nil
+}
+
+// ValidateCredentialHelpers checks the credential helper rules (see
+// parseCredentialHelper), and that the helper binaries are found.
+func ValidateCredentialHelpers(rules []string) error {
+	for _, rule := range rules {
+		_, helper, err := parseCredentialHelper(rule)
+		if err != nil {
+			return err
+		}
+		if _, err := exec.LookPath(helper); err != nil {
+			return fmt.Errorf("credential helper: %w", err)
+		}
+	}
+	return nil
+}
+
+// imageRegistry returns the registry host name of the image reference,
+// as podman sees it (so a reference with no host is from docker.io).
+func imageRegistry(image string) string {
+	host, _, ok := strings.Cut(image, "/")
+	if !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return "docker.io"
+	}
+	return host
+}
+
+// credentialHelperFor returns the helper to get the credentials for the
+// registry from (the first matching rule wins), or an empty string.
+func credentialHelperFor(rules []string, registry string) string {
+	for _, rule := range rules {
+		pattern, helper, err := parseCredentialHelper(rule)
+		if err != nil {
+			continue
+		}
+		if ok, _ := path.Match(pattern, registry); ok {
+			return helper
+		}
+	}
+	return ""
+}
+
+// helperCredentials are the credentials returned by a credential helper.
+type helperCredentials struct {
+	Username string
+	Secret   string
+}
+
+// credentialCache caches the credentials by helper and registry, so each
+// helper is run once per registry (the tokens are valid for hours).
+var credentialCache = struct {
+	sync.Mutex
+	creds map[[2]string]*helperCredentials
+}{creds: make(map[[2]string]*helperCredentials)}
+
+// runCredentialHelper gets the credentials for the registry from the
+// credential helper, using the docker credential helper protocol.
+func runCredentialHelper(ctx context.Context, helper, registry string) (*helperCredentials, error) {
+	credentialCache.Lock()
+	defer credentialCache.Unlock()
+	key := [2]string{helper, registry}
+	if creds, ok := credentialCache.creds[key]; ok {
+		return creds, nil
+	}
+
+	klog.V(1).InfoS("running credential helper", "helper", helper, "registry", registry)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(helper, "get")
+	cmd.Stdin = strings.NewReader(registry + "\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := proc.Run(ctx, cmd); err != nil {
+		// Helpers print the error message to stdout.
+		msg := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		return nil, fmt.Errorf("credential helper %s: %w (output=%s)", helper, err, msg)
+	}
+	creds := &helperCredentials{}
+	if err := json.Unmarshal(stdout.Bytes(), creds); err != nil {
+		return nil, fmt.Errorf("credential helper %s: %w", helper, err)
+	}
+	if creds.Secret == "" {
+		return nil, fmt.Errorf("credential helper %s: no credentials for %s", helper, registry)
+	}
+	credentialCache.creds[key] = creds
+	return creds, nil
+}
+
+// registryAuthFile returns the registry credentials file to use for
+// pulling the image: cfg.RegistryAuthFile(), or, if there is a credential
+// helper for the image registry (see cfg.CredentialHelpers), a temporary
+// copy of it (or a new file), with the credentials from the helper added.
+// The returned function removes the temporary file.
+func registryAuthFile(ctx context.Context, cfg *types.Config, image string) (string, func(), error) {
+	authFile := cfg.RegistryAuthFile()
+	registry := imageRegistry(image)
+	helper := credentialHelperFor(cfg.CredentialHelpers, registry)
+	if helper == "" {
+		return authFile, func() {}, nil
+	}
+	creds, err := runCredentialHelper(ctx, helper, registry)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var data []byte
+	if authFile != "" {
+		if data, err = os.ReadFile(authFile); err != nil {
+			return "", nil, err
+		}
+	}
+	if data, err = addRegistryAuth(data, registry, creds); err != nil {
+		return "", nil, fmt.Errorf("registry auth file %s: %w", authFile, err)
+	}
+
+	// The file is created with 0600 permissions.
+	f, err := os.CreateTemp("", tempDirPrefix+"auth-*.json")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		// Removed even with --keep-temp, as it contains secrets.
+		if err := os.Remove(f.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			klog.Warningf("can't remove temporary auth file: %v", err)
+		}
+	}
+	_, err = f.Write(data)
+	if err = multierr.Append(err, f.Close()); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return f.Name(), cleanup, nil
+}
+
+// addRegistryAuth adds the credentials for the registry to the registry
+// auth file contents (in docker config.json format, possibly empty),
+// keeping all the other settings and credentials.
+func addRegistryAuth(data []byte, registry string, creds *helperCredentials) ([]byte, error) {
+	auth := make(map[string]json.RawMessage)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &auth); err != nil {
+			return nil, err
+		}
+	}
+	auths := make(map[string]json.RawMessage)
+	if data, ok := auth["auths"]; ok {
+		if err := json.Unmarshal(data, &auths); err != nil {
+			return nil, err
+		}
+	}
+	entry := map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Secret))}
+	if creds.Username == "<token>" {
+		// An identity token, rather than a user name and password.
+		entry = map[string]string{"identitytoken": creds.Secret}
+	}
+	var err error
+	if auths[registry], err = json.Marshal(entry); err != nil {
+		return nil, err
+	}
+	if auth["auths"], err = json.Marshal(auths); err != nil {
+		return nil, err
+	}
+	return json.Marshal(auth)
+}
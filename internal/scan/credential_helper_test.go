@@ -0,0 +1,121 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestParseCredentialHelper(t *testing.T) {
+	for rule, want := range map[string][2]string{
+		"ecr-login": {"*", "docker-credential-ecr-login"},
+		"*.dkr.ecr.us-east-1.amazonaws.com = ecr-login": {"*.dkr.ecr.us-east-1.amazonaws.com", "docker-credential-ecr-login"},
+		"gcr.io=docker-credential-gcr":                  {"gcr.io", "docker-credential-gcr"},
+		"gcr.io=/opt/bin/gcr-helper":                    {"gcr.io", "/opt/bin/gcr-helper"},
+	} {
+		registry, helper, err := parseCredentialHelper(rule)
+		require.NoError(t, err, rule)
+		assert.Equal(t, want, [2]string{registry, helper}, rule)
+	}
+	for _, rule := range []string{"", "gcr.io=", "=gcr", "[=gcr"} {
+		_, _, err := parseCredentialHelper(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestCredentialHelperFor(t *testing.T) {
+	for image, want := range map[string]string{
+		"quay.io/foo/bar:1":                     "quay.io",
+		"localhost/foo":                         "localhost",
+		"mirror.local:5000/foo@sha256:1":        "mirror.local:5000",
+		"library/fedora":                        "docker.io",
+		"fedora":                                "docker.io",
+		"123.dkr.ecr.us-east-1.amazonaws.com/x": "123.dkr.ecr.us-east-1.amazonaws.com",
+	} {
+		assert.Equal(t, want, imageRegistry(image), image)
+	}
+
+	rules := []string{"*.dkr.ecr.*.amazonaws.com=ecr-login", "gcr.io=gcr", "other"}
+	assert.Equal(t, "docker-credential-ecr-login", credentialHelperFor(rules, "123.dkr.ecr.us-east-1.amazonaws.com"))
+	assert.Equal(t, "docker-credential-gcr", credentialHelperFor(rules, "gcr.io"))
+	assert.Equal(t, "docker-credential-other", credentialHelperFor(rules, "quay.io"))
+	assert.Empty(t, credentialHelperFor(rules[:2], "quay.io"))
+}
+
+func TestAddRegistryAuth(t *testing.T) {
+	data, err := addRegistryAuth([]byte(`{"auths": {"quay.io": {"auth": "Zm9vOmJhcg==", "email": "a@b"}}, "credHelpers": {"x": "y"}}`),
+		"gcr.io", &helperCredentials{Username: "user", Secret: "pass"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"auths": {
+			"quay.io": {"auth": "Zm9vOmJhcg==", "email": "a@b"},
+			"gcr.io": {"auth": "dXNlcjpwYXNz"}
+		},
+		"credHelpers": {"x": "y"}
+	}`, string(data))
+
+	data, err = addRegistryAuth(nil, "gcr.io", &helperCredentials{Username: "<token>", Secret: "tok"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"auths": {"gcr.io": {"identitytoken": "tok"}}}`, string(data))
+
+	_, err = addRegistryAuth([]byte("not json"), "gcr.io", &helperCredentials{Secret: "tok"})
+	assert.Error(t, err)
+}
+
+func TestRegistryAuthFile(t *testing.T) {
+	dir := t.TempDir()
+	// The helper logs its invocations, to check they are cached.
+	helper := filepath.Join(dir, "docker-credential-test")
+	log := filepath.Join(dir, "log")
+	require.NoError(t, os.WriteFile(helper, []byte(`#!/bin/sh
+read registry
+echo "$1 $registry" >> `+log+`
+[ "$registry" = bad.io ] && { echo "credentials not found in native keychain"; exit 1; }
+echo '{"ServerURL": "'$registry'", "Username": "AWS", "Secret": "token"}'
+`), 0o755))
+	require.NoError(t, ValidateCredentialHelpers([]string{helper}))
+	assert.Error(t, ValidateCredentialHelpers([]string{"nonexistent-helper"}))
+
+	pullSecret := filepath.Join(dir, "pull-secret.json")
+	require.NoError(t, os.WriteFile(pullSecret, []byte(`{"auths": {"quay.io": {"auth": "Zm9vOmJhcg=="}}}`), 0o600))
+	cfg := &types.Config{CredentialHelpers: []string{"*.example.com=" + helper, "bad.io=" + helper}}
+	cfg.PullSecret = pullSecret
+	credentialCache.creds = make(map[[2]string]*helperCredentials)
+
+	// No helper for quay.io.
+	file, cleanup, err := registryAuthFile(context.Background(), cfg, "quay.io/foo:1")
+	require.NoError(t, err)
+	cleanup()
+	assert.Equal(t, pullSecret, file)
+
+	for i := 0; i < 2; i++ {
+		file, cleanup, err = registryAuthFile(context.Background(), cfg, "registry.example.com/foo:1")
+		require.NoError(t, err)
+		fi, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var auth struct{ Auths map[string]map[string]string }
+		require.NoError(t, json.Unmarshal(data, &auth))
+		assert.Equal(t, "Zm9vOmJhcg==", auth.Auths["quay.io"]["auth"])
+		assert.Equal(t, "QVdTOnRva2Vu", auth.Auths["registry.example.com"]["auth"]) // AWS:token
+		cleanup()
+		assert.NoFileExists(t, file)
+	}
+
+	_, _, err = registryAuthFile(context.Background(), cfg, "bad.io/foo:1")
+	assert.ErrorContains(t, err, "credentials not found in native keychain")
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"get registry.example.com", "get bad.io"}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+}
@@ -0,0 +1,165 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// reportDiff is the difference between two JSON reports.
+type reportDiff struct {
+	// NewFailures are failures and warnings found in the new report only.
+	NewFailures []jsonResult `json:"new_failures"`
+	// Fixed are failures and warnings found in the old report only.
+	Fixed []jsonResult `json:"fixed"`
+	// Unchanged are failures and warnings found in both reports.
+	Unchanged []jsonResult `json:"unchanged"`
+}
+
+// diffKey identifies a failure for the purpose of comparing reports.
+// The tag is used rather than the image, if available, since payload
+// image digests change from one release to another.
+type diffKey struct {
+	image, rpm, path, status string
+}
+
+func newDiffKey(jr *jsonResult) diffKey {
+	image := jr.Tag
+	if image == "" {
+		image = jr.Image
+	}
+	return diffKey{image: image, rpm: jr.RPM, path: jr.Path, status: jr.Status}
+}
+
+// failureSet returns a set of failures (and warnings) in the report,
+// and the list of those in the original order.
+func failureSet(report *jsonReport) (map[diffKey]bool, []jsonResult) {
+	set := make(map[diffKey]bool)
+	var list []jsonResult
+	for _, jr := range report.Results {
+		if jr.Skip || jr.Success {
+			continue
+		}
+		key := newDiffKey(&jr)
+		if set[key] {
+			continue
+		}
+		set[key] = true
+		list = append(list, jr)
+	}
+	return set, list
+}
+
+func diffReports(oldReport, newReport *jsonReport) *reportDiff {
+	diff := &reportDiff{
+		NewFailures: []jsonResult{},
+		Fixed:       []jsonResult{},
+		Unchanged:   []jsonResult{},
+	}
+	oldSet, oldList := failureSet(oldReport)
+	newSet, newList := failureSet(newReport)
+	for _, jr := range newList {
+		if oldSet[newDiffKey(&jr)] {
+			diff.Unchanged = append(diff.Unchanged, jr)
+		} else {
+			diff.NewFailures = append(diff.NewFailures, jr)
+		}
+	}
+	for _, jr := range oldList {
+		if !newSet[newDiffKey(&jr)] {
+			diff.Fixed = append(diff.Fixed, jr)
+		}
+	}
+	return diff
+}
+
+func readJSONReport(file string) (*jsonReport, error) {
+	data, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var report jsonReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: can't parse JSON report: %w", file, err)
+	}
+	report.Results = expandDuplicates(report.Results)
+	return &report, nil
+}
+
+// expandDuplicates returns the results, with those collapsed by --dedupe
+// expanded back into one result per path, so that a deduped report can be
+// compared with (or used as a baseline for) one which is not.
+func expandDuplicates(results []jsonResult) []jsonResult {
+	expanded := make([]jsonResult, 0, len(results))
+	for _, jr := range results {
+		dups := jr.DuplicatePaths
+		jr.DuplicatePaths = nil
+		expanded = append(expanded, jr)
+		for _, path := range dups {
+			jr.Path = path
+			expanded = append(expanded, jr)
+		}
+	}
+	return expanded
+}
+
+// DiffReports compares two JSON reports (as produced by --output-format json),
+// and prints new failures, fixed failures, and unchanged failures in a given
+// format. It returns the number of new failures and new warnings.
+func DiffReports(oldFile, newFile, format string) (newFailures, newWarnings int, err error) {
+	oldReport, err := readJSONReport(oldFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	newReport, err := readJSONReport(newFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	diff := diffReports(oldReport, newReport)
+	for _, jr := range diff.NewFailures {
+		if jr.Status == "warning" {
+			newWarnings++
+		} else {
+			newFailures++
+		}
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return newFailures, newWarnings, enc.Encode(diff)
+	}
+	for _, section := range []struct {
+		title string
+		list  []jsonResult
+	}{
+		{"New Failures", diff.NewFailures},
+		{"Fixed", diff.Fixed},
+		{"Unchanged Failures", diff.Unchanged},
+	} {
+		out, err := renderDiffList(section.list, format)
+		if err != nil {
+			return 0, 0, err
+		}
+		fmt.Printf("---- %s (%d)\n", section.title, len(section.list))
+		fmt.Println(out)
+	}
+	return newFailures, newWarnings, nil
+}
+
+func renderDiffList(list []jsonResult, format string) (string, error) {
+	tw := table.NewWriter()
+	tw.SuppressEmptyColumns()
+	tw.AppendHeader(table.Row{colTitleOperatorName, colTitleTagName, colTitleRPMName, colTitleExeName, colTitlePassedFailed, colTitleImage})
+	for _, jr := range list {
+		tw.AppendRow(table.Row{jr.Component, jr.Tag, jr.RPM, jr.Path, jr.Error, jr.Image})
+	}
+	tw.SetIndexColumn(1)
+	switch format {
+	case "table", "csv", "markdown", "html":
+		return renderTable(tw, format), nil
+	}
+	return "", fmt.Errorf("output format %q is not supported", format)
+}
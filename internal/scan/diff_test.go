@@ -0,0 +1,48 @@
+package scan
+
+import (
+	"testing"
+)
+
+func TestDiffReports(t *testing.T) {
+	oldReport := &jsonReport{Results: []jsonResult{
+		{Tag: "a", Image: "quay.io/a@sha256:1", Path: "/bin/ok", Status: "success", Success: true},
+		{Tag: "a", Image: "quay.io/a@sha256:1", Path: "/bin/fixed", Status: "failed"},
+		{Tag: "b", Image: "quay.io/b@sha256:1", Path: "/bin/same", Status: "failed"},
+		{Tag: "b", Image: "quay.io/b@sha256:1", Path: "/bin/warn", Status: "warning"},
+	}}
+	newReport := &jsonReport{Results: []jsonResult{
+		{Tag: "a", Image: "quay.io/a@sha256:2", Path: "/bin/ok", Status: "failed"},
+		{Tag: "a", Image: "quay.io/a@sha256:2", Path: "/bin/fixed", Status: "success", Success: true},
+		{Tag: "b", Image: "quay.io/b@sha256:2", Path: "/bin/same", Status: "failed"},
+		{Tag: "b", Image: "quay.io/b@sha256:2", Path: "/bin/warn", Status: "failed"},
+		{Tag: "c", Image: "quay.io/c@sha256:2", Path: "/bin/skip", Status: "success", Skip: true},
+	}}
+
+	diff := diffReports(oldReport, newReport)
+
+	paths := func(list []jsonResult) []string {
+		var res []string
+		for _, jr := range list {
+			res = append(res, jr.Path)
+		}
+		return res
+	}
+	check := func(name string, got []jsonResult, want ...string) {
+		t.Helper()
+		g := paths(got)
+		if len(g) != len(want) {
+			t.Errorf("%s: want %v, got %v", name, want, g)
+			return
+		}
+		for i := range want {
+			if g[i] != want[i] {
+				t.Errorf("%s: want %v, got %v", name, want, g)
+				return
+			}
+		}
+	}
+	check("new failures", diff.NewFailures, "/bin/ok", "/bin/warn")
+	check("fixed", diff.Fixed, "/bin/fixed", "/bin/warn")
+	check("unchanged", diff.Unchanged, "/bin/same")
+}
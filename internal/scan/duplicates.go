@@ -0,0 +1,176 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// duplicate is a binary which is byte-identical (has the same SHA-256
+// digest) in more than one image (see --report-duplicates).
+type duplicate struct {
+	SHA256 string `json:"sha256"`
+	// Paths are the paths of the binary in the images (usually just one).
+	Paths []string `json:"paths"`
+	// Status is the worst status of the binary scan results ("failed",
+	// "warning", or "success"), so a failure only needs to be fixed once.
+	Status string `json:"status"`
+	// Images are the images containing the binary.
+	Images []string `json:"images"`
+}
+
+// statusRank orders the result statuses from the best to the worst.
+var statusRank = map[string]int{"success": 0, "warning": 1, "failed": 2}
+
+// findDuplicates returns the binaries found in more than one image, with
+// the ones shared by most images first. The results of a node scan (with
+// no images) never have duplicates.
+func findDuplicates(results []*types.ScanResults) []duplicate {
+	type group struct {
+		paths, images map[string]bool
+		status        string
+	}
+	groups := make(map[string]*group)
+	for _, result := range results {
+		for _, res := range result.Items {
+			image := getImage(res)
+			if res.SHA256 == "" || image == "" {
+				continue
+			}
+			if res.Arch != "" {
+				image += " [" + res.Arch + "]"
+			}
+			g, ok := groups[res.SHA256]
+			if !ok {
+				g = &group{paths: make(map[string]bool), images: make(map[string]bool), status: "success"}
+				groups[res.SHA256] = g
+			}
+			g.paths[res.Path] = true
+			g.images[image] = true
+			if status := res.Status(); statusRank[status] > statusRank[g.status] {
+				g.status = status
+			}
+		}
+	}
+
+	var dups []duplicate
+	for digest, g := range groups {
+		if len(g.images) < 2 {
+			continue
+		}
+		dups = append(dups, duplicate{
+			SHA256: digest,
+			Paths:  sortedKeys(g.paths),
+			Status: g.status,
+			Images: sortedKeys(g.images),
+		})
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if len(dups[i].Images) != len(dups[j].Images) {
+			return len(dups[i].Images) > len(dups[j].Images)
+		}
+		return dups[i].SHA256 < dups[j].SHA256
+	})
+	return dups
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderDuplicates renders the duplicate binaries as a table in a given
+// format.
+func renderDuplicates(dups []duplicate, format string) string {
+	if len(dups) == 0 {
+		return "No duplicate binaries found."
+	}
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{colTitleSHA256, colTitleExeName, colTitlePassedFailed, "Images"})
+	for _, d := range dups {
+		tw.AppendRow(table.Row{
+			d.SHA256,
+			strings.Join(d.Paths, "\n"),
+			d.Status,
+			fmt.Sprintf("%d:\n%s", len(d.Images), strings.Join(d.Images, "\n")),
+		})
+	}
+	return renderTable(tw, format)
+}
+
+// duplicatesJSONWriter returns a function like writeJSON, which also
+// adds the duplicate binaries to the report.
+func duplicatesJSONWriter(dups []duplicate) func(io.Writer, []*types.ScanResults, *summary, *types.ReportMetadata) error {
+	return func(w io.Writer, results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) error {
+		report := newJSONReport(results, sum, meta)
+		if dups == nil {
+			dups = []duplicate{}
+		}
+		report.Duplicates = &dups
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+}
+
+// dedupeResults returns a copy of results, in which the results of the
+// same binary (by SHA-256 digest) found at several paths of the same image
+// (such as hard links, or copies of a shared library), with the same status
+// and error, are collapsed into one result for the first path (in sorted
+// order), listing the other paths in DuplicatePaths (see --dedupe). The
+// original results are not modified.
+func dedupeResults(results []*types.ScanResults) []*types.ScanResults {
+	deduped := make([]*types.ScanResults, 0, len(results))
+	for _, result := range results {
+		items := make([]*types.ScanResult, 0, len(result.Items))
+		// The index of the collapsed result in items, by key.
+		first := make(map[string]int)
+		for _, res := range result.Items {
+			if res.SHA256 == "" || res.Skip {
+				items = append(items, res)
+				continue
+			}
+			key := res.SHA256 + "\x00" + res.Status()
+			if res.Error != nil {
+				key += "\x00" + res.Error.Error.Error()
+			}
+			i, ok := first[key]
+			if !ok {
+				first[key] = len(items)
+				items = append(items, res)
+				continue
+			}
+			// Copy, rather than modify the original.
+			prev := items[i]
+			if res.Path < prev.Path {
+				c := *res
+				c.DuplicatePaths = append(prev.DuplicatePaths, prev.Path)
+				items[i] = &c
+				continue
+			}
+			if len(prev.DuplicatePaths) == 0 {
+				c := *prev
+				items[i] = &c
+			}
+			items[i].DuplicatePaths = append(items[i].DuplicatePaths, res.Path)
+		}
+		for _, res := range items {
+			sort.Strings(res.DuplicatePaths)
+		}
+		shown := types.NewScanResults().SetTag(result.Tag).SetTime(result.Start, result.End)
+		shown.Arch = result.Arch
+		shown.Items = items
+		deduped = append(deduped, shown)
+	}
+	return deduped
+}
@@ -0,0 +1,129 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	tag := func(image string) *v1.TagReference {
+		return &v1.TagReference{Name: image, From: &corev1.ObjectReference{Name: "quay.io/" + image}}
+	}
+	bin := func(image, path, digest string) *types.ScanResult {
+		return types.NewScanResult().SetTag(tag(image)).SetPath(path).SetSHA256(digest).Success()
+	}
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(bin("a", "/usr/bin/oc", "11")).
+			Append(bin("a", "/usr/bin/jq", "22")).
+			Append(bin("a", "/usr/bin/unique", "33")),
+		types.NewScanResults().
+			Append(bin("b", "/bin/oc", "11")).
+			Append(types.NewScanResult().SetTag(tag("b")).SetPath("/usr/bin/jq").SetSHA256("22").SetError(errors.New("bad"))),
+		types.NewScanResults().
+			Append(bin("c", "/usr/bin/oc", "11")).
+			// The same binary twice in one image is not a duplicate.
+			Append(bin("c", "/usr/bin/unique2", "44")).
+			Append(bin("c", "/usr/libexec/unique2", "44")),
+		// Node scan results have no image.
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/usr/bin/oc").SetSHA256("11").Success()),
+	}
+
+	want := []duplicate{
+		{SHA256: "11", Paths: []string{"/bin/oc", "/usr/bin/oc"}, Status: "success", Images: []string{"quay.io/a", "quay.io/b", "quay.io/c"}},
+		{SHA256: "22", Paths: []string{"/usr/bin/jq"}, Status: "failed", Images: []string{"quay.io/a", "quay.io/b"}},
+	}
+	assert.Equal(t, want, findDuplicates(results))
+	assert.Empty(t, findDuplicates(results[2:]))
+
+	render := func(cfg *types.Config, results []*types.ScanResults) string {
+		out, _ := renderTextReport(cfg, results, results, newSummary(results))
+		return out
+	}
+	out := render(&types.Config{OutputFormat: "csv", ReportDuplicates: true}, results)
+	assert.Contains(t, out, "---- Duplicate Binaries")
+	assert.Contains(t, out, "11,\"/bin/oc\n/usr/bin/oc\",success,\"3:\nquay.io/a\nquay.io/b\nquay.io/c\"")
+	out = render(&types.Config{OutputFormat: "csv", ReportDuplicates: true}, results[2:])
+	assert.Contains(t, out, "No duplicate binaries found.")
+	out = render(&types.Config{OutputFormat: "csv"}, results)
+	assert.NotContains(t, out, "Duplicate")
+
+	var buf bytes.Buffer
+	require.NoError(t, duplicatesJSONWriter(nil)(&buf, results, newSummary(results), nil))
+	var report map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.JSONEq(t, "[]", string(report["duplicates"]))
+}
+
+func TestDedupeResults(t *testing.T) {
+	fail := func(path, digest string, err error) *types.ScanResult {
+		return types.NewScanResult().SetPath(path).SetSHA256(digest).SetError(err)
+	}
+	errDyn := errors.New("not dynamically linked")
+	orig := []*types.ScanResults{
+		types.NewScanResults().
+			Append(fail("/usr/lib64/libfoo.so.1", "11", errDyn)).
+			Append(fail("/usr/lib/libfoo.so.1", "11", errDyn)).
+			Append(types.NewScanResult().SetPath("/usr/lib64/libfoo.so").SetSHA256("11").Success()).
+			Append(fail("/usr/lib64/libfoo.so.2", "11", errDyn)).
+			Append(fail("/usr/lib64/libbar.so", "11", errors.New("other"))).
+			Append(fail("/usr/bin/baz", "22", errDyn)).
+			// Results with no digest, and skipped files, are kept as is.
+			Append(types.NewScanResult().SetError(errors.New("openssl"))).
+			Append(types.NewScanResult().SetPath("/core").SetSHA256("33").Skipped()).
+			Append(types.NewScanResult().SetPath("/core2").SetSHA256("33").Skipped()),
+		// Not collapsed across images.
+		types.NewScanResults().SetArch("arm64").
+			Append(fail("/usr/bin/baz", "22", errDyn)),
+	}
+
+	results := filterResults(&types.Config{Dedupe: true}, orig)
+	require.Len(t, results, 2)
+	type row struct {
+		path string
+		dups []string
+	}
+	var got []row
+	for _, res := range results[0].Items {
+		got = append(got, row{res.Path, res.DuplicatePaths})
+	}
+	assert.Equal(t, []row{
+		{"/usr/lib/libfoo.so.1", []string{"/usr/lib64/libfoo.so.1", "/usr/lib64/libfoo.so.2"}},
+		{"/usr/lib64/libfoo.so", nil},
+		{"/usr/lib64/libbar.so", nil},
+		{"/usr/bin/baz", nil},
+		{"", nil},
+		{"/core", nil},
+		{"/core2", nil},
+	}, got)
+	assert.Equal(t, "arm64", results[1].Arch)
+	assert.Len(t, results[1].Items, 1)
+	// The original results are not modified.
+	assert.Len(t, orig[0].Items, 9)
+	for _, res := range orig[0].Items {
+		assert.Empty(t, res.DuplicatePaths, res.Path)
+	}
+	assert.Equal(t, "/usr/lib64/libfoo.so.1", orig[0].Items[0].Path)
+
+	// All the paths are in the report.
+	out, _ := renderTextReport(&types.Config{OutputFormat: "csv", Dedupe: true}, orig, results, newSummary(orig))
+	assert.Contains(t, out, "\"/usr/lib/libfoo.so.1\n/usr/lib64/libfoo.so.1\n/usr/lib64/libfoo.so.2\",failed")
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, results, nil, nil))
+	assert.Contains(t, buf.String(), `"duplicate_paths": [
+        "/usr/lib64/libfoo.so.1",
+        "/usr/lib64/libfoo.so.2"
+      ]`)
+	sarif := newSarifLog(results, nil, nil)
+	assert.Len(t, sarif.Runs[0].Results[0].Locations, 3)
+}
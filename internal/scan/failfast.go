@@ -0,0 +1,70 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// failFastKey is the context key for the fail-fast state (see WithFailFast).
+type failFastKey struct{}
+
+type failFast struct {
+	cancel context.CancelFunc
+	// known tells if the failure is a known one (such as listed in
+	// the baseline), which does not stop the scan.
+	known func(*types.ScanResult) bool
+}
+
+// failFastStopped is set once the scan is stopped by --fail-fast.
+var failFastStopped atomic.Bool
+
+// WithFailFast returns a copy of ctx which is canceled on the first failure
+// found by the scan, so that no new work is started (this implements
+// --fail-fast). Failures for which known returns true (if it is not nil)
+// are not counted. Operational errors are not counted, either.
+func WithFailFast(ctx context.Context, known func(*types.ScanResult) bool) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	return context.WithValue(ctx, failFastKey{}, &failFast{cancel: cancel, known: known}), cancel
+}
+
+// StoppedOnFailure tells if the scan was stopped early by --fail-fast.
+func StoppedOnFailure() bool {
+	return failFastStopped.Load()
+}
+
+// checkFailFast stops the scan (see WithFailFast) if res is a failure.
+func checkFailFast(ctx context.Context, res *types.ScanResult) {
+	ff, ok := ctx.Value(failFastKey{}).(*failFast)
+	if !ok || !res.IsLevel(types.Error) {
+		return
+	}
+	var opErr *OperationalError
+	if errors.As(res.Error.Error, &opErr) || (ff.known != nil && ff.known(res)) {
+		return
+	}
+	if failFastStopped.CompareAndSwap(false, true) {
+		klog.InfoS("failure found, stopping the scan (--fail-fast)", "image", getImage(res), "path", res.Path, "error", res.Error.Error)
+	}
+	ff.cancel()
+}
+
+// dropCanceled removes the results of scans canceled by --fail-fast,
+// as these are not real errors.
+func dropCanceled(results []*types.ScanResults) {
+	for _, result := range results {
+		n := 0
+		for _, res := range result.Items {
+			if res.Error != nil && errors.Is(res.Error.Error, context.Canceled) {
+				continue
+			}
+			result.Items[n] = res
+			n++
+		}
+		result.Items = result.Items[:n]
+	}
+}
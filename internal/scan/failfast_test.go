@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestFailFast(t *testing.T) {
+	defer failFastStopped.Store(false)
+
+	// No fail-fast mode.
+	checkFailFast(context.Background(), types.NewScanResult().SetPath("/a").SetValidationError(types.NewValidationError(types.ErrNotDynLinked)))
+	assert.False(t, StoppedOnFailure())
+
+	known := func(res *types.ScanResult) bool { return res.Path == "/known" }
+	ctx, cancel := WithFailFast(context.Background(), known)
+	defer cancel()
+
+	for _, res := range []*types.ScanResult{
+		types.NewScanResult().SetPath("/ok").Success(),
+		types.NewScanResult().SetPath("/warn").SetValidationError(types.NewValidationError(types.ErrNotDynLinked).SetWarning()),
+		types.NewScanResult().SetError(&OperationalError{context.DeadlineExceeded}),
+		types.NewScanResult().SetPath("/known").SetValidationError(types.NewValidationError(types.ErrNotDynLinked)),
+	} {
+		checkFailFast(ctx, res)
+		assert.NoError(t, ctx.Err(), res.Path)
+	}
+	assert.False(t, StoppedOnFailure())
+
+	checkFailFast(ctx, types.NewScanResult().SetPath("/bad").SetValidationError(types.NewValidationError(types.ErrNotDynLinked)))
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.True(t, StoppedOnFailure())
+
+	results := []*types.ScanResults{types.NewScanResults().
+		Append(types.NewScanResult().SetPath("/bad").SetValidationError(types.NewValidationError(types.ErrNotDynLinked))).
+		Append(types.NewScanResult().SetPath("/canceled").SetError(context.Canceled))}
+	dropCanceled(results)
+	assert.Len(t, results[0].Items, 1)
+	assert.Equal(t, "/bad", results[0].Items[0].Path)
+}
+
+func TestFailFastSeverity(t *testing.T) {
+	defer failFastStopped.Store(false)
+
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0o755))
+	for _, name := range []string{"a", "b"} {
+		file := filepath.Join(root, "usr/bin", name)
+		require.NoError(t, os.WriteFile(file, exe, 0o755))
+		require.NoError(t, os.Chmod(file, 0o755|os.ModeSetuid))
+	}
+
+	// --fail-fast --fail-on-severity=high, with medium severity failures.
+	cfg := &types.Config{Checks: []string{"setuid"}, FailFast: true, FailOnSeverity: types.SeverityHigh}
+	ctx, cancel := WithFailFast(context.Background(), func(res *types.ScanResult) bool {
+		return IsBelowSeverity(res, cfg.FailOnSeverity)
+	})
+	defer cancel()
+	results := rootfsScan(ctx, cfg, root)
+	assert.NoError(t, ctx.Err())
+	assert.False(t, StoppedOnFailure())
+
+	// The scan is complete, and the run passes.
+	files := types.NewScanResults()
+	for _, res := range results.Items {
+		if res.Path != "" { // Not the openssl info.
+			files.Append(res)
+		}
+	}
+	var failed []string
+	for _, res := range files.Items {
+		if res.IsLevel(types.Error) {
+			assert.Equal(t, types.SeverityMedium, res.Severity, res.Path)
+			failed = append(failed, res.Path)
+		}
+	}
+	assert.ElementsMatch(t, []string{"/usr/bin/a", "/usr/bin/b"}, failed)
+	assert.False(t, IsFailedAtSeverity([]*types.ScanResults{files}, cfg.FailOnSeverity))
+
+	// Failures at the threshold still stop the scan.
+	ctx, cancel = WithFailFast(context.Background(), func(res *types.ScanResult) bool {
+		return IsBelowSeverity(res, types.SeverityMedium)
+	})
+	defer cancel()
+	checkFailFast(ctx, files.Items[0])
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.True(t, StoppedOnFailure())
+}
+
+func TestIncompleteReport(t *testing.T) {
+	results := []*types.ScanResults{types.NewScanResults().
+		Append(types.NewScanResult().SetPath("/bad").SetValidationError(types.NewValidationError(types.ErrNotDynLinked)))}
+	sum := newSummary(results)
+	sum.Incomplete = "stopped on the first failure (--fail-fast)"
+
+	assert.Contains(t, renderSummary(sum, "csv"), "Incomplete\n1,0,1,0,0,0s,"+sum.Incomplete)
+	assert.Contains(t, renderSummary(sum, "table"), "INCOMPLETE: "+sum.Incomplete)
+
+	sarif := newSarifLog(results, sum, nil)
+	require.Len(t, sarif.Runs[0].Invocations, 1)
+	inv := sarif.Runs[0].Invocations[0]
+	assert.False(t, inv.ExecutionSuccessful)
+	require.Len(t, inv.ToolExecutionNotifications, 1)
+	assert.Contains(t, inv.ToolExecutionNotifications[0].Message.Text, sum.Incomplete)
+
+	junit := newJUnitTestSuites(results, sum, nil)
+	assert.Contains(t, junit.Suites[0].Properties, junitProperty{Name: "incomplete", Value: sum.Incomplete})
+
+	// Complete scans have no such notes.
+	sum.Incomplete = ""
+	assert.NotContains(t, renderSummary(sum, "csv"), "Incomplete")
+	assert.Empty(t, newSarifLog(results, sum, nil).Runs[0].Invocations)
+	assert.Empty(t, newJUnitTestSuites(results, sum, nil).Suites[0].Properties)
+}
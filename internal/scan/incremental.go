@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/cache"
+	"github.com/openshift/check-payload/internal/podman"
+	"github.com/openshift/check-payload/internal/types"
+	"github.com/openshift/check-payload/internal/validations"
+)
+
+// incremental is the state of an incremental image scan (see
+// cfg.PreviousImage). Only the files added or changed since the previous
+// image are scanned, and the previous results are used for the rest.
+type incremental struct {
+	// changed is a set of paths added or changed since the previous image.
+	changed map[string]bool
+	// prior are the previous image scan results, by path.
+	prior map[string][]jsonResult
+}
+
+// setupIncremental pulls the previous image, finds the files changed
+// between it and the image (already pulled as ref), and reads the previous
+// image results from cfg.PreviousReport.
+func setupIncremental(ctx context.Context, cfg *types.Config, ref string, pulls semaphore) (*incremental, error) {
+	report, err := readJSONReport(cfg.PreviousReport)
+	if err != nil {
+		return nil, err
+	}
+	if err := pulls.acquire(ctx); err != nil {
+		return nil, err
+	}
+	prevRef, err := pullImage(ctx, cfg, cfg.PreviousImage)
+	pulls.release()
+	if err != nil {
+		return nil, err
+	}
+	changed, err := podman.ImageDiff(ctx, prevRef, ref)
+	if err != nil {
+		return nil, err
+	}
+	return newIncremental(changed, report, cfg.PreviousImage)
+}
+
+// newIncremental creates the incremental scan state from the list of
+// changed files, and the previous report containing the results for
+// the previous image (either the same pull spec, or the same digest).
+func newIncremental(changed []string, report *jsonReport, prevImage string) (*incremental, error) {
+	inc := &incremental{
+		changed: make(map[string]bool, len(changed)),
+		prior:   make(map[string][]jsonResult),
+	}
+	for _, path := range changed {
+		inc.changed[path] = true
+	}
+	digest := cache.DigestFromRef(prevImage)
+	for _, jr := range report.Results {
+		if jr.Image != prevImage && (digest == "" || cache.DigestFromRef(jr.Image) != digest) {
+			continue
+		}
+		if jr.Path == "" {
+			// Not a per-file result.
+			continue
+		}
+		inc.prior[jr.Path] = append(inc.prior[jr.Path], jr)
+	}
+	if len(inc.prior) == 0 {
+		return nil, fmt.Errorf("no results for image %s in the previous report", prevImage)
+	}
+	klog.InfoS("incremental scan", "previous_image", prevImage, "changed_paths", len(inc.changed), "previous_results", len(inc.prior))
+	return inc, nil
+}
+
+// carryForward returns the previous results for the file, if it has not
+// changed since the previous image, and was scanned then. It is safe to
+// call for nil inc, in which case the file is to be scanned.
+func (inc *incremental) carryForward(path string) ([]*types.ScanResult, bool) {
+	if inc == nil || inc.changed[path] {
+		return nil, false
+	}
+	prior, ok := inc.prior[path]
+	if !ok {
+		return nil, false
+	}
+	res := make([]*types.ScanResult, 0, len(prior))
+	for i := range prior {
+		res = append(res, prior[i].scanResult())
+	}
+	return res, true
+}
+
+// isPriorValid tells if the previous results of the file (unchanged since
+// the previous image) still hold. As the outcomes of the checks depending
+// on other files in the image (see validations.Validation.NoCache) may
+// have changed, those checks are rerun, and only the previous successes
+// are used if they pass. Otherwise, as it is not known which check a
+// previous failure is from, the file is to be scanned again.
+func isPriorValid(ctx context.Context, cfg *types.Config, mountPath, innerPath string, prior []*types.ScanResult, disabledChecks []string, errIgnoreLists []types.ErrIgnoreList) bool {
+	for _, res := range prior {
+		if !res.IsSuccess() {
+			return false
+		}
+	}
+	disabled := append([]string{}, disabledChecks...)
+	for _, v := range validations.Validations() {
+		if !v.NoCache {
+			disabled = append(disabled, v.Name)
+		}
+	}
+	return scanBinary(ctx, cfg, mountPath, innerPath, disabled, errIgnoreLists...).IsSuccess()
+}
@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestIncremental(t *testing.T) {
+	const (
+		prev  = "quay.io/foo@sha256:1111"
+		other = "quay.io/bar@sha256:2222"
+	)
+	report := &jsonReport{Results: []jsonResult{
+		{Image: prev, Status: "success", Success: true},
+		{Image: prev, Path: "/usr/bin/same", Status: "failed", Error: "go binary has no tags", ErrorName: "ErrGoNoTags"},
+		{Image: prev, Path: "/usr/bin/changed", Status: "success", Success: true},
+		{Image: other, Path: "/usr/bin/other", Status: "success", Success: true},
+	}}
+
+	_, err := newIncremental(nil, report, "quay.io/baz@sha256:3333")
+	assert.Error(t, err)
+
+	// The same digest, but a different pull spec.
+	inc, err := newIncremental([]string{"/usr/bin", "/usr/bin/changed"}, report, "registry.example.com/foo@sha256:1111")
+	require.NoError(t, err)
+
+	res, ok := inc.carryForward("/usr/bin/same")
+	require.True(t, ok)
+	require.Len(t, res, 1)
+	assert.Equal(t, "failed", res[0].Status())
+	assert.Equal(t, "/usr/bin/same", res[0].Path)
+
+	_, ok = inc.carryForward("/usr/bin/changed")
+	assert.False(t, ok)
+	_, ok = inc.carryForward("/usr/bin/other")
+	assert.False(t, ok)
+
+	// A full scan.
+	inc = nil
+	_, ok = inc.carryForward("/usr/bin/same")
+	assert.False(t, ok)
+}
+
+func TestIsPriorValid(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	file := filepath.Join(root, "true")
+	require.NoError(t, os.WriteFile(file, exe, 0o755))
+	success := []*types.ScanResult{types.NewScanResult().SetPath("/true").Success()}
+	failed := []*types.ScanResult{types.NewScanResult().SetPath("/true").SetError(types.ErrNotDynLinked)}
+
+	// The setuid check depends on the file mode, not only on its contents.
+	cfg := &types.Config{Checks: []string{"setuid"}}
+	assert.True(t, isPriorValid(context.Background(), cfg, root, "/true", success, nil, nil))
+	// A previous failure might be of a check which passes now.
+	assert.False(t, isPriorValid(context.Background(), cfg, root, "/true", failed, nil, nil))
+
+	mode := 0o755 | os.ModeSetuid
+	require.NoError(t, os.Chmod(file, mode))
+	if fi, err := os.Stat(file); err != nil || fi.Mode() != mode {
+		t.Skipf("can't set file mode %v", mode)
+	}
+	assert.False(t, isPriorValid(context.Background(), cfg, root, "/true", success, nil, nil))
+	// Unless the check is disabled.
+	assert.True(t, isPriorValid(context.Background(), cfg, root, "/true", success, []string{"setuid"}, nil))
+}
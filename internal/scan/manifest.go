@@ -0,0 +1,207 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+)
+
+// manifestExts are the extensions of the manifest files read from
+// a directory.
+var manifestExts = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// Parts of Kubernetes objects needed to find the images they use.
+type (
+	manifestContainer struct {
+		Name  string `json:"name"`
+		Image string `json:"image"`
+	}
+	manifestPodSpec struct {
+		Containers          []manifestContainer `json:"containers"`
+		InitContainers      []manifestContainer `json:"initContainers"`
+		EphemeralContainers []manifestContainer `json:"ephemeralContainers"`
+	}
+	manifestPodTemplate struct {
+		Spec manifestPodSpec `json:"spec"`
+	}
+	manifestHeader struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	manifestObject struct {
+		Spec struct {
+			manifestPodSpec                     // Pod.
+			Template        manifestPodTemplate `json:"template"` // Deployment, Job, etc.
+			JobTemplate     struct {
+				Spec struct {
+					Template manifestPodTemplate `json:"template"`
+				} `json:"spec"`
+			} `json:"jobTemplate"` // CronJob.
+		} `json:"spec"`
+		Template manifestPodTemplate `json:"template"` // PodTemplate.
+	}
+	manifestList struct {
+		Items []json.RawMessage `json:"items"`
+	}
+)
+
+// templateKinds are the kinds of workload resources having a pod
+// template in spec.template.
+var templateKinds = map[string]bool{
+	"DaemonSet":             true,
+	"Deployment":            true,
+	"DeploymentConfig":      true,
+	"Job":                   true,
+	"ReplicaSet":            true,
+	"ReplicationController": true,
+	"StatefulSet":           true,
+}
+
+// ReadManifestImages reads Kubernetes manifests (YAML or JSON, possibly
+// with multiple documents) from a file, or all *.yaml, *.yml, and *.json
+// files in a directory (recursively), and returns the images of all
+// containers (including init and ephemeral ones) of the workload resources,
+// with no duplicates, in the order they are found. Other resources are
+// skipped.
+func ReadManifestImages(path string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Files given explicitly are read regardless of the extension.
+		if p == path || manifestExts[filepath.Ext(p)] {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		found, err := readManifestFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, image := range found {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%s: no container images found", path)
+	}
+	return images, nil
+}
+
+func readManifestFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseManifests(f)
+}
+
+// parseManifests returns the images of the workload resources found in
+// a stream of YAML or JSON documents.
+func parseManifests(r io.Reader) ([]string, error) {
+	var images []string
+	dec := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for n := 1; ; n++ {
+		var data json.RawMessage
+		if err := dec.Decode(&data); err != nil {
+			if errors.Is(err, io.EOF) {
+				return images, nil
+			}
+			return nil, fmt.Errorf("document %d: %w", n, err)
+		}
+		if len(data) == 0 { // An empty document.
+			continue
+		}
+		found, err := manifestImages(data)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", n, err)
+		}
+		images = append(images, found...)
+	}
+}
+
+// manifestImages returns the container images of the workload resource
+// (or of all the workload resources in a list).
+func manifestImages(data json.RawMessage) ([]string, error) {
+	// Other resources may have a spec of a different structure,
+	// so only the kind is decoded first.
+	var hdr manifestHeader
+	if err := json.Unmarshal(data, &hdr); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(hdr.Kind, "List") {
+		var list manifestList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		var images []string
+		for _, item := range list.Items {
+			found, err := manifestImages(item)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, found...)
+		}
+		return images, nil
+	}
+	if hdr.Kind != "Pod" && hdr.Kind != "PodTemplate" && hdr.Kind != "CronJob" && !templateKinds[hdr.Kind] {
+		if hdr.Kind != "" {
+			klog.V(1).InfoS("skipping non-workload resource", "kind", hdr.Kind, "name", hdr.Metadata.Name)
+		}
+		return nil, nil
+	}
+
+	var obj manifestObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", hdr.Kind, hdr.Metadata.Name, err)
+	}
+	var spec *manifestPodSpec
+	switch hdr.Kind {
+	case "Pod":
+		spec = &obj.Spec.manifestPodSpec
+	case "PodTemplate":
+		spec = &obj.Template.Spec
+	case "CronJob":
+		spec = &obj.Spec.JobTemplate.Spec.Template.Spec
+	default:
+		spec = &obj.Spec.Template.Spec
+	}
+
+	var images []string
+	for _, list := range [][]manifestContainer{spec.InitContainers, spec.Containers, spec.EphemeralContainers} {
+		for _, c := range list {
+			if c.Image == "" {
+				// Such as a DeploymentConfig with the image set by a trigger.
+				klog.Warningf("%s %s: container %q has no image, skipping", hdr.Kind, hdr.Metadata.Name, c.Name)
+				continue
+			}
+			images = append(images, c.Image)
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,135 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 2
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: quay.io/app/init:1
+      containers:
+      - name: app
+        image: quay.io/app/app:1
+      - name: sidecar
+        image: quay.io/app/sidecar:1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  spec: not a pod spec
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  template: 42
+---
+# An empty document.
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cron
+spec:
+  schedule: "@daily"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: job
+            image: quay.io/app/job:1
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pod
+  spec:
+    containers:
+    - name: app
+      image: quay.io/app/app:1
+    ephemeralContainers:
+    - name: debug
+      image: quay.io/app/debug:1
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: svc
+`
+
+func TestParseManifests(t *testing.T) {
+	images, err := parseManifests(strings.NewReader(testManifest))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"quay.io/app/init:1",
+		"quay.io/app/app:1",
+		"quay.io/app/sidecar:1",
+		"quay.io/app/job:1",
+		"quay.io/app/app:1",
+		"quay.io/app/debug:1",
+	}, images)
+
+	images, err = parseManifests(strings.NewReader(`{"kind": "StatefulSet", "spec": {"template": {"spec": {"containers": [{"name": "db", "image": "quay.io/db:1"}]}}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"quay.io/db:1"}, images)
+
+	_, err = parseManifests(strings.NewReader("kind: Pod\nspec:\n  containers: 42\n"))
+	assert.ErrorContains(t, err, "document 1: Pod")
+	_, err = parseManifests(strings.NewReader("kind: Pod\n---\n: bad\n"))
+	assert.ErrorContains(t, err, "document 2")
+}
+
+func TestReadManifestImages(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		require.NoError(t, os.WriteFile(file, []byte(data), 0o644))
+	}
+	write("a/app.yaml", testManifest)
+	write("b/db.json", `{"kind": "StatefulSet", "spec": {"template": {"spec": {"containers": [{"name": "db", "image": "quay.io/db:1"}]}}}}`)
+	write("README.md", "kind: Pod\n")
+
+	images, err := ReadManifestImages(dir)
+	require.NoError(t, err)
+	// Duplicates are removed.
+	assert.Equal(t, []string{
+		"quay.io/app/init:1",
+		"quay.io/app/app:1",
+		"quay.io/app/sidecar:1",
+		"quay.io/app/job:1",
+		"quay.io/app/debug:1",
+		"quay.io/db:1",
+	}, images)
+
+	images, err = ReadManifestImages(filepath.Join(dir, "b", "db.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"quay.io/db:1"}, images)
+
+	write("c/empty.yml", "kind: Service\n")
+	_, err = ReadManifestImages(filepath.Join(dir, "c"))
+	assert.ErrorContains(t, err, "no container images found")
+	_, err = ReadManifestImages(filepath.Join(dir, "nonexistent"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package scan
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/openshift/oc/pkg/cli/admin/release"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// releaseTagRe matches the destination tags of release payload images
+// mirrored by oc-mirror (such as "4.14.1-x86_64-cluster-version-operator"),
+// the last group being the payload tag name.
+var releaseTagRe = regexp.MustCompile(`^\d+\.\d+\.\d+[^-]*-(?:x86_64|aarch64|ppc64le|s390x|multi)-(.+)$`)
+
+// ReadMappingFile reads the oc-mirror mapping file (with "source=destination"
+// lines), and returns the payload made of the destination images.
+func ReadMappingFile(filename string) (*release.ReleaseInfo, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tags, err := parseMapping(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &release.ReleaseInfo{
+		References: &v1.ImageStream{Spec: v1.ImageStreamSpec{Tags: tags}},
+	}, nil
+}
+
+// parseMapping parses the oc-mirror mapping. Empty lines and lines
+// starting with # are ignored.
+func parseMapping(r io.Reader) ([]v1.TagReference, error) {
+	var tags []v1.TagReference
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		src, dst, ok := strings.Cut(line, "=")
+		src, dst = trimTransport(src), trimTransport(dst)
+		if !ok || src == "" || dst == "" {
+			return nil, fmt.Errorf("line %d: want source=destination, got %q", n, line)
+		}
+		if seen[dst] {
+			continue
+		}
+		seen[dst] = true
+		tags = append(tags, v1.TagReference{
+			Name: mappingTagName(dst),
+			From: &corev1.ObjectReference{Kind: "DockerImage", Name: dst},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("no images found")
+	}
+	return tags, nil
+}
+
+// trimTransport removes the transport prefix (such as "docker://"),
+// which oc-mirror may add to image references.
+func trimTransport(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if _, after, ok := strings.Cut(ref, "://"); ok {
+		return after
+	}
+	return ref
+}
+
+// mappingTagName returns the tag name to use for the mirrored image
+// reference: the payload tag name for release images, or the repository
+// name otherwise.
+func mappingTagName(ref string) string {
+	repo := ref
+	if i := strings.IndexByte(repo, '@'); i != -1 {
+		repo = repo[:i]
+	}
+	name := path.Base(repo)
+	if i := strings.LastIndexByte(name, ':'); i != -1 {
+		if m := releaseTagRe.FindStringSubmatch(name[i+1:]); m != nil {
+			return m[1]
+		}
+		name = name[:i]
+	}
+	return name
+}
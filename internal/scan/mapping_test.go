@@ -0,0 +1,48 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMapping(t *testing.T) {
+	tags, err := parseMapping(strings.NewReader(`# oc-mirror mapping
+quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1=mirror.local:5000/openshift/release:4.14.1-x86_64-cluster-version-operator
+docker://registry.redhat.io/ocs4/mcg-rhel8@sha256:2=docker://mirror.local:5000/ocs4/mcg-rhel8:3a4b5c
+
+registry.redhat.io/ubi8/ubi@sha256:3=mirror.local:5000/ubi8/ubi@sha256:3
+registry.redhat.io/ubi8/ubi@sha256:3=mirror.local:5000/ubi8/ubi@sha256:3
+`))
+	require.NoError(t, err)
+
+	var names, images []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+		images = append(images, tag.From.Name)
+	}
+	assert.Equal(t, []string{"cluster-version-operator", "mcg-rhel8", "ubi"}, names)
+	assert.Equal(t, []string{
+		"mirror.local:5000/openshift/release:4.14.1-x86_64-cluster-version-operator",
+		"mirror.local:5000/ocs4/mcg-rhel8:3a4b5c",
+		"mirror.local:5000/ubi8/ubi@sha256:3",
+	}, images)
+
+	for _, bad := range []string{"", "# comment only\n", "quay.io/foo\n", "quay.io/foo=\n"} {
+		_, err := parseMapping(strings.NewReader(bad))
+		assert.Error(t, err, "%q", bad)
+	}
+}
+
+func TestReadMappingFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mapping.txt")
+	require.NoError(t, os.WriteFile(file, []byte("quay.io/foo@sha256:1=mirror.local/foo:1\n"), 0o644))
+	payload, err := ReadMappingFile(file)
+	require.NoError(t, err)
+	require.Len(t, payload.References.Spec.Tags, 1)
+	assert.Equal(t, "mirror.local/foo:1", payload.References.Spec.Tags[0].From.Name)
+}
@@ -0,0 +1,122 @@
+package scan
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// Scan phases, reported by the check_payload_phase metric.
+const (
+	PhaseStarting = "starting" // Reading the config, checking dependencies.
+	PhasePayload  = "payload"  // Getting the release payload information.
+	PhaseScan     = "scan"     // Pulling and scanning images, or scanning the node.
+	PhaseReport   = "report"   // Printing the results.
+	PhaseDone     = "done"
+)
+
+var phases = []string{PhaseStarting, PhasePayload, PhaseScan, PhaseReport, PhaseDone}
+
+var (
+	metricBinaries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "check_payload_binaries_scanned_total",
+		Help: "Number of binaries scanned.",
+	})
+	metricImages = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "check_payload_images_scanned_total",
+		Help: "Number of images scanned.",
+	})
+	metricFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "check_payload_failures_total",
+		Help: "Number of failed scan results (including operational errors, such as failed image pulls).",
+	})
+	metricWarnings = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "check_payload_warnings_total",
+		Help: "Number of scan results with warnings.",
+	})
+	metricPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "check_payload_phase",
+		Help: "Current scan phase (1 for the current phase, 0 for others).",
+	}, []string{"phase"})
+	metricDuration = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "check_payload_scan_duration_seconds",
+		Help: "Time since the scan started (or the total scan time, once it is done).",
+	}, scanDuration)
+
+	metricsRegistry = prometheus.NewRegistry()
+)
+
+// scanTime is the scan start and end time, set by SetPhase.
+var scanTime struct {
+	sync.Mutex
+	start, end time.Time
+}
+
+func init() {
+	metricsRegistry.MustRegister(metricBinaries, metricImages, metricFailures, metricWarnings, metricPhase, metricDuration)
+	for _, phase := range phases {
+		metricPhase.WithLabelValues(phase)
+	}
+}
+
+// MetricsHandler returns an HTTP handler serving the scan metrics in the
+// Prometheus format (see --metrics-addr).
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// SetPhase sets the current scan phase (one of Phase* constants). The scan
+// duration is measured from PhaseStarting to PhaseDone.
+func SetPhase(phase string) {
+	for _, p := range phases {
+		v := 0.0
+		if p == phase {
+			v = 1
+		}
+		metricPhase.WithLabelValues(p).Set(v)
+	}
+
+	scanTime.Lock()
+	defer scanTime.Unlock()
+	switch phase {
+	case PhaseStarting:
+		scanTime.start, scanTime.end = time.Now(), time.Time{}
+	case PhaseDone:
+		scanTime.end = time.Now()
+	}
+}
+
+// scanDuration returns the value of check_payload_scan_duration_seconds.
+func scanDuration() float64 {
+	scanTime.Lock()
+	defer scanTime.Unlock()
+	switch {
+	case scanTime.start.IsZero():
+		return 0
+	case scanTime.end.IsZero():
+		return time.Since(scanTime.start).Seconds()
+	}
+	return scanTime.end.Sub(scanTime.start).Seconds()
+}
+
+// countBinary counts a binary scanned, for the progress reporting and
+// the metrics.
+func countBinary() {
+	binariesScanned.Add(1)
+	metricBinaries.Inc()
+}
+
+// countResult counts a failed or warning result in the metrics.
+func countResult(res *types.ScanResult) {
+	switch {
+	case res.IsLevel(types.Error):
+		metricFailures.Inc()
+	case res.IsLevel(types.Warning):
+		metricWarnings.Inc()
+	}
+}
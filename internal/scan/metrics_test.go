@@ -0,0 +1,60 @@
+package scan
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// scrapeMetrics returns the metric values served by MetricsHandler,
+// by the metric name (with labels, if any).
+func scrapeMetrics(t *testing.T) map[string]float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	metrics := make(map[string]float64)
+	for _, m := range regexp.MustCompile(`(?m)^(check_payload_\S+) (\S+)$`).FindAllStringSubmatch(string(body), -1) {
+		v, err := strconv.ParseFloat(m[2], 64)
+		require.NoError(t, err)
+		metrics[m[1]] = v
+	}
+	return metrics
+}
+
+func TestMetrics(t *testing.T) {
+	before := scrapeMetrics(t)
+
+	SetPhase(PhaseStarting)
+	SetPhase(PhaseScan)
+	countBinary()
+	countBinary()
+	countResult(types.NewScanResult().Success())
+	countResult(types.NewScanResult().SetError(errors.New("fail")))
+	countResult(types.NewScanResult().SetValidationError(types.NewValidationError(errors.New("warn")).SetWarning()))
+	imageError(&types.Config{}, nil, errors.New("pull failed"))
+
+	after := scrapeMetrics(t)
+	delta := func(name string) float64 { return after[name] - before[name] }
+	assert.Equal(t, 2.0, delta("check_payload_binaries_scanned_total"))
+	assert.Equal(t, 2.0, delta("check_payload_failures_total"))
+	assert.Equal(t, 1.0, delta("check_payload_warnings_total"))
+	assert.Equal(t, 1.0, after[`check_payload_phase{phase="scan"}`])
+	assert.Equal(t, 0.0, after[`check_payload_phase{phase="starting"}`])
+	assert.Contains(t, after, "check_payload_scan_duration_seconds")
+
+	SetPhase(PhaseDone)
+	d1 := scrapeMetrics(t)["check_payload_scan_duration_seconds"]
+	d2 := scrapeMetrics(t)["check_payload_scan_duration_seconds"]
+	assert.Equal(t, d1, d2, "duration must not change once the scan is done")
+}
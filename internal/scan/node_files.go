@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/rpm"
+	"github.com/openshift/check-payload/internal/types"
+	"github.com/openshift/check-payload/internal/validations"
+)
+
+// volatileDirs are not walked by the node file checks, as these are
+// usually pseudo or temporary file systems.
+var volatileDirs = map[string]bool{
+	"/dev":  true,
+	"/proc": true,
+	"/run":  true,
+	"/sys":  true,
+}
+
+// nodeFileChecks runs the file system level checks of a node scan
+// (world-writable and rpm-orphan, on all files under root, except for the
+// filtered out ones, and fips-module), if enabled via --checks, adding the
+// failures to results.
+func nodeFileChecks(ctx context.Context, cfg *types.Config, root string, results *types.ScanResults) {
+	worldWritable := validations.IsCheckEnabled(cfg, "world-writable")
+	orphan := validations.IsCheckEnabled(cfg, "rpm-orphan")
+	fipsModule := validations.IsCheckEnabled(cfg, "fips-module")
+	if cfg.DryRun || (!worldWritable && !orphan && !fipsModule) {
+		return
+	}
+
+	// appendResult adds a scan result (see also cfg.OnResult).
+	appendResult := func(res *types.ScanResult) {
+		results.Append(res)
+		onResult(cfg, res)
+	}
+
+	var owned map[string]string
+	if orphan {
+		var err error
+		owned, err = rpm.GetAllFiles(ctx, root)
+		if err != nil {
+			appendResult(types.NewScanResult().SetError(&OperationalError{err}))
+			return
+		}
+	}
+
+	klog.Info("checking node files")
+	add := func(check, innerPath string, err error) {
+		res := types.NewScanResult().SetPath(innerPath).SetRPM(owned[innerPath])
+		if rule := cfg.ErrIgnores.Match(innerPath, err); rule != "" && !cfg.RefuseException(innerPath, err, rule) {
+			klog.V(1).InfoS("error ignored", "path", innerPath, "error", err, "rule", rule)
+			appendResult(res.Success().AddException(rule))
+			return
+		}
+		if !cfg.Quiet {
+			klog.InfoS("scanning node failed", "path", innerPath, "error", err, "status", "failed")
+		}
+		appendResult(res.SetValidationError(types.NewValidationError(err)).SetSeverity(validations.CheckSeverity(cfg, check)))
+		checkFailFast(ctx, res)
+	}
+	if fipsModule {
+		for _, issue := range fipsModuleIssues(root) {
+			if !errors.Is(issue.err, types.ErrFIPSModuleIntegrity) {
+				appendResult(types.NewScanResult().SetPath(issue.path).SetError(&OperationalError{issue.err}))
+				continue
+			}
+			add("fips-module", issue.path, issue.err)
+		}
+	}
+	if !worldWritable && !orphan {
+		return
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			// Time limit exceeded.
+			return err
+		}
+		// Make sure the path is absolute, to match rpm file lists.
+		innerPath := filepath.Join("/", stripMountPath(root, path))
+		if d.IsDir() {
+			if volatileDirs[innerPath] || !cfg.IsDirIncluded(innerPath) || cfg.IgnoreDirPrefix(innerPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !cfg.IsIncluded(innerPath) || cfg.IgnoreFile(innerPath) || cfg.IgnoreDirPrefix(innerPath) {
+			return nil
+		}
+		if orphan {
+			if _, ok := owned[innerPath]; !ok {
+				add("rpm-orphan", innerPath, types.ErrRPMOrphan)
+			}
+		}
+		if worldWritable && d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if fi.Mode().Perm()&0o002 != 0 {
+				add("world-writable", innerPath, fmt.Errorf("%w (mode %v)", types.ErrWorldWritable, fi.Mode()))
+			}
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		appendResult(types.NewScanResult().SetError(&OperationalError{err}))
+	}
+}
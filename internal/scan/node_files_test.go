@@ -0,0 +1,62 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestNodeFileChecksWorldWritable(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"etc", "proc", "var/log"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	for file, mode := range map[string]os.FileMode{
+		"etc/ok":      0o644,
+		"etc/bad":     0o666,
+		"proc/bad":    0o666,
+		"var/log/bad": 0o777,
+	} {
+		path := filepath.Join(root, file)
+		require.NoError(t, os.WriteFile(path, nil, mode))
+		// Not affected by umask.
+		require.NoError(t, os.Chmod(path, mode))
+	}
+
+	var streamed []*types.ScanResult
+	cfg := &types.Config{Checks: []string{"world-writable"}, OnResult: func(res *types.ScanResult) {
+		streamed = append(streamed, res)
+	}}
+	cfg.ErrIgnores = types.ErrIgnoreList{{
+		Error: types.KnownError{Err: types.ErrWorldWritable, Str: "ErrWorldWritable"},
+		Dirs:  []string{"/var/log"},
+	}}
+	results := types.NewScanResults()
+	nodeFileChecks(context.Background(), cfg, root, results)
+
+	var failed, ignored []string
+	for _, res := range results.Items {
+		if res.IsSuccess() {
+			ignored = append(ignored, res.Path)
+			continue
+		}
+		assert.True(t, errors.Is(res.Error.Error, types.ErrWorldWritable), res.Error.Error)
+		failed = append(failed, res.Path)
+	}
+	assert.Equal(t, []string{"/etc/bad"}, failed)
+	assert.Equal(t, []string{"/var/log/bad"}, ignored)
+	// All the results are passed to cfg.OnResult.
+	assert.Equal(t, results.Items, streamed)
+
+	// Not run unless selected.
+	results = types.NewScanResults()
+	nodeFileChecks(context.Background(), &types.Config{}, root, results)
+	assert.Empty(t, results.Items)
+}
@@ -0,0 +1,286 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// fipsModuleKey is the HMAC-SHA256 key (in hex) of the openssl 3 FIPS
+// provider integrity check (FIPS_KEY_STRING, the same upstream and in RHEL).
+const fipsModuleKey = "f4556650ac31d35461610bac4ed81b1a181b2d8a43ea2854cbae22ca74560813"
+
+// fipscheckKey is the HMAC-SHA256 key of the fipscheck .hmac files, used
+// by openssl 1.1 in RHEL 8 (where libcrypto itself is the FIPS module).
+const fipscheckKey = "orboDeJITITejsirpADONivirpUkvarP"
+
+// fipsInstallStatus is the install-status value of fipsmodule.cnf, which
+// install-mac is the MAC of.
+const fipsInstallStatus = "INSTALL_SELF_TEST_KATS_RUN"
+
+var (
+	// fipsModuleGlobs are the locations of the openssl 3 FIPS provider.
+	fipsModuleGlobs = []string{
+		"/usr/lib*/ossl-modules/fips.so",
+		"/usr/lib/*/ossl-modules/fips.so",
+		"/usr/local/lib*/ossl-modules/fips.so",
+	}
+	// fipsModuleConfigs are the locations of fipsmodule.cnf (created by
+	// openssl fipsinstall), the first one found is used.
+	fipsModuleConfigs = []string{
+		"/etc/pki/tls/fipsmodule.cnf",
+		"/etc/ssl/fipsmodule.cnf",
+		"/usr/lib/ssl/fipsmodule.cnf",
+		"/usr/local/ssl/fipsmodule.cnf",
+	}
+	// fipscheckGlobs are the locations of the openssl 1.1 fipscheck files.
+	fipscheckGlobs = []string{
+		"/usr/lib*/.libcrypto.so.*.hmac",
+		"/usr/lib*/.libssl.so.*.hmac",
+	}
+)
+
+// fipsModuleIssue is a problem found by the fips-module check. The error
+// wraps types.ErrFIPSModuleIntegrity, unless it is an operational one.
+type fipsModuleIssue struct {
+	path string // The file the issue is about.
+	err  error
+}
+
+// fipsModuleIssues checks the integrity metadata of the openssl FIPS
+// modules installed under root: that of the openssl 3 FIPS provider
+// (fips.so), which is either embedded into it (RHEL), or is in
+// fipsmodule.cnf (upstream), and the fipscheck .hmac files of openssl 1.1
+// libraries. The MACs are verified against the files. The modules are
+// only found in the standard locations, and a system with no FIPS module
+// has no issues.
+func fipsModuleIssues(root string) []fipsModuleIssue {
+	var issues []fipsModuleIssue
+	add := func(innerPath string, err error) {
+		issues = append(issues, fipsModuleIssue{path: innerPath, err: err})
+	}
+	var seen inodeSet
+	for _, module := range globRoot(root, fipsModuleGlobs) {
+		target, fi, err := statRoot(root, module)
+		if err != nil {
+			add(module, err)
+			continue
+		}
+		if !seen.add(fi) {
+			continue
+		}
+		if err := checkFIPSProvider(root, target); err != nil {
+			add(target, err)
+		}
+	}
+	for _, hmacFile := range globRoot(root, fipscheckGlobs) {
+		if innerPath, err := checkFipscheckFile(root, hmacFile); err != nil {
+			add(innerPath, err)
+		}
+	}
+	return issues
+}
+
+// globRoot returns the (sorted, unique) paths relative to root matching
+// any of the patterns.
+func globRoot(root string, patterns []string) []string {
+	var paths []string
+	found := make(map[string]bool)
+	for _, pattern := range patterns {
+		// The only possible error is a bad pattern.
+		matches, _ := filepath.Glob(filepath.Join(root, pattern))
+		for _, match := range matches {
+			innerPath := filepath.Join("/", stripMountPath(root, match))
+			if !found[innerPath] {
+				found[innerPath] = true
+				paths = append(paths, innerPath)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// statRoot resolves the symlinks in innerPath (see resolveSymlinks), and
+// returns the resolved path, and the file info of the regular file it
+// points to.
+func statRoot(root, innerPath string) (string, os.FileInfo, error) {
+	target, err := resolveSymlinks(root, innerPath)
+	if err != nil {
+		return "", nil, err
+	}
+	fi, err := os.Stat(filepath.Join(root, target))
+	if err != nil {
+		return "", nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", nil, fmt.Errorf("%s: not a regular file", target)
+	}
+	return target, fi, nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data.
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// parseMAC parses the HMAC-SHA256 value in hex, optionally with colons
+// between the bytes (as in fipsmodule.cnf).
+func parseMAC(s string) ([]byte, error) {
+	mac, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != sha256.Size {
+		return nil, fmt.Errorf("%d bytes long, want %d", len(mac), sha256.Size)
+	}
+	return mac, nil
+}
+
+// checkFIPSProvider checks the integrity metadata of the openssl 3 FIPS
+// provider: the MAC embedded into its .rodata1 section (RHEL), which is
+// calculated with the section zeroed, or, if there is none, module-mac in
+// fipsmodule.cnf (upstream), which is calculated over the whole file.
+func checkFIPSProvider(root, module string) error {
+	data, err := os.ReadFile(filepath.Join(root, module))
+	if err != nil {
+		return err
+	}
+	key, _ := hex.DecodeString(fipsModuleKey)
+
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: not an ELF file: %v", types.ErrFIPSModuleIntegrity, err)
+	}
+	if sec := f.Section(".rodata1"); sec != nil && sec.Type != elf.SHT_NOBITS && sec.Size == sha256.Size && sec.Offset+sec.Size <= uint64(len(data)) {
+		embedded := data[sec.Offset : sec.Offset+sec.Size]
+		if bytes.Equal(embedded, make([]byte, sha256.Size)) {
+			return fmt.Errorf("%w: the embedded module MAC is not set", types.ErrFIPSModuleIntegrity)
+		}
+		zeroed := make([]byte, len(data))
+		copy(zeroed, data)
+		copy(zeroed[sec.Offset:], make([]byte, sha256.Size))
+		if !hmac.Equal(embedded, hmacSHA256(key, zeroed)) {
+			return fmt.Errorf("%w: the embedded module MAC does not match the module", types.ErrFIPSModuleIntegrity)
+		}
+		return nil
+	}
+
+	config, settings, err := readFIPSModuleConfig(root)
+	if err != nil {
+		return err
+	}
+	if config == "" {
+		return fmt.Errorf("%w: no embedded module MAC, and no fipsmodule.cnf found", types.ErrFIPSModuleIntegrity)
+	}
+	value, ok := settings["module-mac"]
+	if !ok {
+		return fmt.Errorf("%w: no module-mac in %s", types.ErrFIPSModuleIntegrity, config)
+	}
+	mac, err := parseMAC(value)
+	if err != nil {
+		return fmt.Errorf("%w: malformed module-mac in %s: %v", types.ErrFIPSModuleIntegrity, config, err)
+	}
+	if !hmac.Equal(mac, hmacSHA256(key, data)) {
+		return fmt.Errorf("%w: module-mac in %s does not match the module", types.ErrFIPSModuleIntegrity, config)
+	}
+	// The install status is optional (see openssl fipsinstall -self_test_onload).
+	if status, ok := settings["install-status"]; ok {
+		if status != fipsInstallStatus {
+			return fmt.Errorf("%w: unexpected install-status %q in %s", types.ErrFIPSModuleIntegrity, status, config)
+		}
+		mac, err := parseMAC(settings["install-mac"])
+		if err != nil {
+			return fmt.Errorf("%w: malformed install-mac in %s: %v", types.ErrFIPSModuleIntegrity, config, err)
+		}
+		if !hmac.Equal(mac, hmacSHA256(key, []byte(fipsInstallStatus))) {
+			return fmt.Errorf("%w: install-mac in %s does not match install-status", types.ErrFIPSModuleIntegrity, config)
+		}
+	}
+	return nil
+}
+
+// readFIPSModuleConfig reads the first fipsmodule.cnf found under root,
+// and returns its path, and the settings (of all sections, as the file
+// only has one). If there is no such file, the path is empty.
+func readFIPSModuleConfig(root string) (string, map[string]string, error) {
+	for _, config := range fipsModuleConfigs {
+		target, err := resolveSymlinks(root, config)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(root, target))
+		if err != nil {
+			return "", nil, err
+		}
+		settings := make(map[string]string)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || line[0] == '#' || line[0] == '[' {
+				continue
+			}
+			if key, value, ok := strings.Cut(line, "="); ok {
+				settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		return config, settings, scanner.Err()
+	}
+	return "", nil, nil
+}
+
+// checkFipscheckFile checks the fipscheck file (such as
+// /usr/lib64/.libcrypto.so.1.1.hmac) against the library it is for (such
+// as /usr/lib64/libcrypto.so.1.1), and returns the path the error, if any,
+// is about (the file, or the library, if the MAC does not match it).
+func checkFipscheckFile(root, hmacFile string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, hmacFile))
+	if err != nil {
+		return hmacFile, err
+	}
+	var mac []byte
+	if fields := strings.Fields(string(data)); len(fields) > 0 {
+		mac, err = parseMAC(fields[0])
+	} else {
+		err = errors.New("empty file")
+	}
+	if err != nil {
+		return hmacFile, fmt.Errorf("%w: malformed hmac file: %v", types.ErrFIPSModuleIntegrity, err)
+	}
+
+	dir, name := path.Split(hmacFile)
+	lib := path.Join(dir, strings.TrimSuffix(strings.TrimPrefix(name, "."), ".hmac"))
+	target, _, err := statRoot(root, lib)
+	if errors.Is(err, fs.ErrNotExist) {
+		return hmacFile, fmt.Errorf("%w: %s (the library the hmac file is for) not found", types.ErrFIPSModuleIntegrity, lib)
+	}
+	if err != nil {
+		return hmacFile, err
+	}
+	libData, err := os.ReadFile(filepath.Join(root, target))
+	if err != nil {
+		return target, err
+	}
+	if !hmac.Equal(mac, hmacSHA256([]byte(fipscheckKey), libData)) {
+		return target, fmt.Errorf("%w: hmac in %s does not match the library", types.ErrFIPSModuleIntegrity, hmacFile)
+	}
+	return "", nil
+}
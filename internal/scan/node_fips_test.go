@@ -0,0 +1,200 @@
+package scan
+
+import (
+	"context"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// writeRootFiles writes the files (by path relative to root) under root,
+// creating the directories as needed. A value starting with "->" makes a
+// symlink.
+func writeRootFiles(t *testing.T, root string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		if target := string(data); strings.HasPrefix(target, "->") {
+			require.NoError(t, os.Symlink(target[2:], path))
+			continue
+		}
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+	}
+}
+
+// fipsModuleMAC returns the module-mac of the module, as in fipsmodule.cnf.
+func fipsModuleMAC(data []byte) string {
+	key, _ := hex.DecodeString(fipsModuleKey)
+	var b strings.Builder
+	for i, c := range hmacSHA256(key, data) {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, "%02X", c)
+	}
+	return b.String()
+}
+
+// checkFIPSIssues checks that fipsModuleIssues finds the issues with the
+// given paths and error message substrings.
+func checkFIPSIssues(t *testing.T, root string, want map[string]string) {
+	t.Helper()
+	got := make(map[string]string)
+	for _, issue := range fipsModuleIssues(root) {
+		assert.True(t, errors.Is(issue.err, types.ErrFIPSModuleIntegrity), issue.err)
+		got[issue.path] = issue.err.Error()
+	}
+	assert.Len(t, got, len(want), got)
+	for path, msg := range want {
+		assert.Contains(t, got[path], msg, path)
+	}
+}
+
+func TestFIPSModuleConfig(t *testing.T) {
+	module, err := os.ReadFile("/bin/true") // An ELF file with no .rodata1.
+	if err != nil {
+		t.Skip(err)
+	}
+	key, _ := hex.DecodeString(fipsModuleKey)
+	installMAC := hex.EncodeToString(hmacSHA256(key, []byte(fipsInstallStatus)))
+	config := func(moduleMAC string, extra ...string) []byte {
+		return []byte(strings.Join(append([]string{"# comment", "[fips_sect]", "activate = 1", "module-mac = " + moduleMAC}, extra...), "\n"))
+	}
+
+	for _, tc := range []struct {
+		name   string
+		config []byte
+		msg    string // Empty for no issues.
+	}{
+		{"valid", config(fipsModuleMAC(module), "install-status = "+fipsInstallStatus, "install-mac = "+installMAC), ""},
+		{"no install status", config(fipsModuleMAC(module)), ""},
+		{"no config", nil, "no embedded module MAC, and no fipsmodule.cnf found"},
+		{"no module-mac", []byte("[fips_sect]\nactivate = 1\n"), "no module-mac in /etc/pki/tls/fipsmodule.cnf"},
+		{"malformed", config("01:02:XX"), "malformed module-mac"},
+		{"short", config("01:02:03"), "malformed module-mac"},
+		{"mismatch", config(fipsModuleMAC(append(module, 0))), "module-mac in /etc/pki/tls/fipsmodule.cnf does not match the module"},
+		{"bad install-mac", config(fipsModuleMAC(module), "install-status = "+fipsInstallStatus, "install-mac = "+installMAC[2:]+"00"), "install-mac in /etc/pki/tls/fipsmodule.cnf does not match"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			files := map[string][]byte{"usr/lib64/ossl-modules/fips.so": module}
+			if tc.config != nil {
+				// The config is found through the symlink.
+				files["etc/ssl/fipsmodule.cnf"] = tc.config
+				files["etc/pki/tls/fipsmodule.cnf"] = []byte("->../../ssl/fipsmodule.cnf")
+			}
+			writeRootFiles(t, root, files)
+			want := map[string]string{}
+			if tc.msg != "" {
+				want["/usr/lib64/ossl-modules/fips.so"] = tc.msg
+			}
+			checkFIPSIssues(t, root, want)
+		})
+	}
+}
+
+func TestFIPSModuleEmbedded(t *testing.T) {
+	gcc, err := exec.LookPath("gcc")
+	if err != nil {
+		t.Skip("gcc not found")
+	}
+	// The same as the module MAC placeholder in RHEL openssl.
+	dir := t.TempDir()
+	src := filepath.Join(dir, "fips.c")
+	require.NoError(t, os.WriteFile(src, []byte(`static const unsigned char __attribute__((used, section(".rodata1"))) fips_hmac_container[32] = {0};
+int fips_hmac(void) { return fips_hmac_container[0]; }
+`), 0o644))
+	so := filepath.Join(dir, "fips.so")
+	if out, err := exec.Command(gcc, "-shared", "-fPIC", "-o", so, src).CombinedOutput(); err != nil {
+		t.Skipf("gcc: %v: %s", err, out)
+	}
+	placeholder, err := os.ReadFile(so)
+	require.NoError(t, err)
+	f, err := elf.Open(so)
+	require.NoError(t, err)
+	offset := f.Section(".rodata1").Offset
+	f.Close()
+
+	// Like objcopy --update-section .rodata1=<mac>.
+	key, _ := hex.DecodeString(fipsModuleKey)
+	module := append([]byte{}, placeholder...)
+	copy(module[offset:], hmacSHA256(key, placeholder))
+	tampered := append([]byte{}, module...)
+	tampered[len(tampered)-1] ^= 1
+
+	for name, tc := range map[string]struct {
+		module []byte
+		msg    string
+	}{
+		"valid":       {module, ""},
+		"placeholder": {placeholder, "the embedded module MAC is not set"},
+		"tampered":    {tampered, "the embedded module MAC does not match the module"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			// The same module through a /usr/lib64 symlink is only checked once.
+			writeRootFiles(t, root, map[string][]byte{
+				"usr/lib/ossl-modules/fips.so": tc.module,
+				"usr/lib64":                    []byte("->lib"),
+			})
+			want := map[string]string{}
+			if tc.msg != "" {
+				want["/usr/lib/ossl-modules/fips.so"] = tc.msg
+			}
+			checkFIPSIssues(t, root, want)
+		})
+	}
+}
+
+func TestFIPSModuleFipscheck(t *testing.T) {
+	lib := []byte("libcrypto")
+	mac := hex.EncodeToString(hmacSHA256([]byte(fipscheckKey), lib))
+	root := t.TempDir()
+	writeRootFiles(t, root, map[string][]byte{
+		"usr/lib64/libcrypto.so.1.1.1k":    lib,
+		"usr/lib64/libcrypto.so.1.1":       []byte("->libcrypto.so.1.1.1k"),
+		"usr/lib64/.libcrypto.so.1.1.hmac": []byte(mac + "\n"),
+		"usr/lib64/libssl.so.1.1":          []byte("libssl"),
+		"usr/lib64/.libssl.so.1.1.hmac":    []byte(mac + "\n"),
+		"usr/lib64/.libssl.so.3.hmac":      []byte(mac),
+		"usr/lib/.libcrypto.so.3.hmac":     []byte("not hex\n"),
+	})
+	checkFIPSIssues(t, root, map[string]string{
+		"/usr/lib64/libssl.so.1.1":      "hmac in /usr/lib64/.libssl.so.1.1.hmac does not match the library",
+		"/usr/lib64/.libssl.so.3.hmac":  "/usr/lib64/libssl.so.3 (the library the hmac file is for) not found",
+		"/usr/lib/.libcrypto.so.3.hmac": "malformed hmac file",
+	})
+}
+
+func TestNodeFileChecksFIPSModule(t *testing.T) {
+	root := t.TempDir()
+	writeRootFiles(t, root, map[string][]byte{
+		"usr/lib64/libcrypto.so.1.1":       []byte("libcrypto"),
+		"usr/lib64/.libcrypto.so.1.1.hmac": []byte(strings.Repeat("00", 32)),
+	})
+
+	results := types.NewScanResults()
+	nodeFileChecks(context.Background(), &types.Config{Checks: []string{"fips-module"}}, root, results)
+	require.Len(t, results.Items, 1)
+	res := results.Items[0]
+	assert.Equal(t, "/usr/lib64/libcrypto.so.1.1", res.Path)
+	assert.True(t, errors.Is(res.Error.Error, types.ErrFIPSModuleIntegrity), res.Error.Error)
+	assert.Equal(t, types.SeverityHigh, res.Severity)
+
+	// Not run unless selected.
+	results = types.NewScanResults()
+	nodeFileChecks(context.Background(), &types.Config{}, root, results)
+	assert.Empty(t, results.Items)
+}
@@ -2,72 +2,349 @@ package scan
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/check-payload/internal/rpm"
+	"github.com/openshift/check-payload/internal/tracing"
 	"github.com/openshift/check-payload/internal/types"
 	"github.com/openshift/check-payload/internal/validations"
 )
 
-func RunNodeScan(ctx context.Context, cfg *types.Config, root string) []*types.ScanResults {
+// skipReasonUnchanged is the reason files not modified since
+// cfg.ModifiedSince are skipped.
+const skipReasonUnchanged = "unchanged"
+
+// skipReasonSymlink is the reason symlinks are skipped (unless
+// cfg.FollowSymlinks is set).
+const skipReasonSymlink = "symlink"
+
+// modifiedSinceLayouts are the time formats accepted by ParseModifiedSince.
+var modifiedSinceLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// ParseModifiedSince parses the --modified-since value, which is either
+// an RFC 3339 timestamp (such as "2024-01-02T15:04:05Z"), a local time
+// without a time zone (such as "2024-01-02T15:04:05"), or a local date
+// (such as "2024-01-02").
+func ParseModifiedSince(value string) (time.Time, error) {
+	for _, layout := range modifiedSinceLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q (want RFC 3339 timestamp, such as 2024-01-02T15:04:05Z, or a date, such as 2024-01-02)", value)
+}
+
+// isUnmodified tells if the file was not modified since cfg.ModifiedSince
+// (if set), so it need not be scanned.
+func isUnmodified(cfg *types.Config, fi os.FileInfo) bool {
+	return !cfg.ModifiedSince.IsZero() && fi.ModTime().Before(cfg.ModifiedSince)
+}
+
+// RunNodeScan scans the files of all rpm packages installed under root,
+// or, unless cfg.UseRPMScan is set, all files found under root.
+func RunNodeScan(ctx context.Context, cfg *types.Config, root string) (runs []*types.ScanResults) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "scan node", tracing.String(tracing.AttrRoot, root))
+	defer func() { endSpan(span, runs) }()
+	SetPhase(PhaseScan)
 	if !cfg.UseRPMScan {
 		klog.Info("scanning a directory tree")
-		return []*types.ScanResults{walkDirScan(ctx, cfg, nil, nil, root)}
+		progress := startProgress(cfg, "")
+		defer progress.Stop()
+		results := walkDirScan(ctx, cfg, nil, nil, root, nil, cfg.Parallelism)
+		nodeFileChecks(ctx, cfg, root, results)
+		return []*types.ScanResults{results.SetTime(start, time.Now())}
 	}
 	klog.Info("scanning node")
-	return []*types.ScanResults{rpmRootScan(ctx, cfg, root)}
+	progress := startProgress(cfg, "rpms")
+	defer progress.Stop()
+	results := rpmRootScan(ctx, cfg, root, progress, cfg.Parallelism)
+	nodeFileChecks(ctx, cfg, root, results)
+	return []*types.ScanResults{results.SetTime(start, time.Now())}
 }
 
-func rpmRootScan(ctx context.Context, cfg *types.Config, root string) *types.ScanResults {
+// rpmRootScan scans files from all rpm packages installed under root,
+// using up to parallelism workers. The progress, if not nil, is updated
+// as rpms are scanned.
+func rpmRootScan(ctx context.Context, cfg *types.Config, root string, progress *progress, parallelism int) *types.ScanResults {
 	results := types.NewScanResults()
 	rpms, err := rpm.GetAllRPMs(ctx, root)
 	if err != nil {
-		results.Append(types.NewScanResult().SetError(err))
-		return results
+		return newResults(cfg, types.NewScanResult().SetError(&OperationalError{err}))
+	}
+
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	tx := make(chan rpm.Info, parallelism)
+	rx := make(chan *types.ScanResult, parallelism)
+	var wgThreads sync.WaitGroup
+	var wgRx sync.WaitGroup
+	var seen *inodeSet
+	if cfg.FollowSymlinks {
+		seen = &inodeSet{}
+	}
+
+	wgThreads.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func() {
+			for pkg := range tx {
+				rpmScan(ctx, cfg, root, pkg, seen, rx)
+				progress.Done()
+			}
+			wgThreads.Done()
+		}()
+	}
+
+	wgRx.Add(1)
+	go func() {
+		for res := range rx {
+			results.Append(res)
+			onResult(cfg, res)
+		}
+		wgRx.Done()
+	}()
+
+	// Filter out excluded rpms.
+	n := 0
+	for _, pkg := range rpms {
+		if pattern, ok := cfg.IgnoreRPM(pkg.Name); ok {
+			klog.V(1).InfoS("excluding rpm", "rpm", pkg.Name, "pattern", pattern)
+			continue
+		}
+		rpms[n] = pkg
+		n++
 	}
+	rpms = rpms[:n]
+	progress.SetTotal(len(rpms))
+
+rpms:
 	for _, pkg := range rpms {
-		files, err := rpm.GetFilesFromRPM(ctx, root, pkg.NVRA)
+		select {
+		case tx <- pkg:
+		case <-ctx.Done():
+			break rpms
+		}
+	}
+
+	close(tx)
+	wgThreads.Wait()
+	close(rx)
+	wgRx.Wait()
+
+	return results
+}
+
+// rpmScan scans all files from a given rpm package, sending the results to rx.
+// With cfg.FollowSymlinks, the symlinks are resolved, and the files already
+// in seen are skipped.
+func rpmScan(ctx context.Context, cfg *types.Config, root string, pkg rpm.Info, seen *inodeSet, rx chan<- *types.ScanResult) {
+	files, err := rpm.GetFilesFromRPM(ctx, root, pkg.NVRA)
+	if err != nil {
+		rx <- types.NewScanResult().SetRPM(pkg.Name).SetError(&OperationalError{err})
+		return
+	}
+	for _, innerPath := range files {
+		if ctx.Err() != nil {
+			// Time limit exceeded.
+			return
+		}
+		if !cfg.IsIncluded(innerPath) {
+			continue
+		}
+		if cfg.IgnoreFile(innerPath) || cfg.IgnoreDirPrefix(innerPath) || cfg.IgnoreFileByRpm(innerPath, pkg.Name) {
+			continue
+		}
+		path := filepath.Join(root, innerPath)
+		fileInfo, err := os.Lstat(path)
 		if err != nil {
-			res := types.NewScanResult().SetRPM(pkg.Name).SetError(err)
-			results.Append(res)
+			// some files are stripped from an rhcos image
 			continue
 		}
-		for _, innerPath := range files {
-			if cfg.IgnoreFile(innerPath) || cfg.IgnoreDirPrefix(innerPath) || cfg.IgnoreFileByRpm(innerPath, pkg.Name) {
-				continue
+		if fileInfo.Mode()&fs.ModeSymlink != 0 && !cfg.FollowSymlinks {
+			if !cfg.DryRun {
+				if res := symlinkResult(root, innerPath); res != nil {
+					rx <- res.SetRPM(pkg.Name)
+				}
 			}
-			path := filepath.Join(root, innerPath)
-			fileInfo, err := os.Lstat(path)
+			continue
+		}
+		if fileInfo.Mode()&fs.ModeSymlink != 0 {
+			target, err := resolveSymlinks(root, innerPath)
 			if err != nil {
-				// some files are stripped from an rhcos image
+				if !errors.Is(err, fs.ErrNotExist) {
+					klog.Warningf("skipping symlink: %v", err)
+				}
 				continue
 			}
-			if m := fileInfo.Mode(); !m.IsRegular() || m.Perm()&0o111 == 0 {
-				// Skip all non-regular files (directories, symlinks),
-				// and regular files that has no x bit set.
+			// The target is not from the rpm, but may still be filtered out.
+			if !cfg.IsIncluded(target) || cfg.IgnoreFile(target) || cfg.IgnoreDirPrefix(target) {
 				continue
 			}
-			klog.V(1).InfoS("scanning path", "path", innerPath)
-			res := validations.ScanBinary(ctx, root, innerPath, cfg.RPMIgnores, cfg.ErrIgnores)
-			if res.Skip {
-				// Do not add skipped binaries to results.
+			klog.V(1).InfoS("following symlink", "path", innerPath, "target", target)
+			innerPath = target
+			if fileInfo, err = os.Lstat(filepath.Join(root, target)); err != nil {
 				continue
 			}
-			if res.IsSuccess() {
-				klog.V(1).InfoS("scanning node success", "path", innerPath, "status", "success")
-			} else {
-				status := res.Status()
-				klog.InfoS("scanning node "+status,
-					"rpm", res.RPM,
-					"path", innerPath,
-					"error", res.Error.Error,
-					"status", status)
+		}
+		if !isScanned(fileInfo, innerPath) {
+			continue
+		}
+		if !seen.add(fileInfo) {
+			// Already scanned (via a symlink, or a hard link).
+			continue
+		}
+		if isUnmodified(cfg, fileInfo) {
+			if !cfg.DryRun {
+				rx <- types.NewScanResult().SetPath(innerPath).SetRPM(pkg.Name).SkippedBecause(skipReasonUnchanged)
+			}
+			continue
+		}
+		if cfg.DryRun {
+			rx <- types.NewScanResult().SetPath(innerPath).SetRPM(pkg.Name)
+			continue
+		}
+		if !cfg.Quiet {
+			klog.V(1).InfoS("scanning path", "path", innerPath)
+		}
+		countBinary()
+		res := scanBinary(ctx, cfg, root, innerPath, nil, cfg.ErrIgnores)
+		if res.Skip && res.SkipReason == "" {
+			// Do not add skipped non-binaries to results, but keep the
+			// files skipped for a reason (such as core dumps).
+			continue
+		}
+		switch {
+		case cfg.Quiet, res.Skip:
+		case res.IsSuccess():
+			klog.V(1).InfoS("scanning node success", "path", innerPath, "status", "success")
+		default:
+			status := res.Status()
+			klog.InfoS("scanning node "+status,
+				"rpm", res.RPM,
+				"path", innerPath,
+				"error", res.Error.Error,
+				"status", status)
+		}
+		checkFailFast(ctx, res)
+		rx <- res
+	}
+}
+
+// isScanned tells if the file is to be scanned. All non-regular files
+// (directories, symlinks), and regular files that has no x bit set
+// (except python extension modules) are skipped.
+func isScanned(fi os.FileInfo, innerPath string) bool {
+	m := fi.Mode()
+	return m.IsRegular() && (m.Perm()&0o111 != 0 || validations.IsPythonExtensionName(innerPath))
+}
+
+// symlinkResult returns the skip result of the symlink, with the path it
+// resolves to, or nil if the target is not a file which would be scanned
+// (such as a directory), or can't be resolved.
+func symlinkResult(root, innerPath string) *types.ScanResult {
+	target, err := resolveSymlinks(root, innerPath)
+	if err != nil {
+		klog.V(1).InfoS("can't resolve symlink", "path", innerPath, "error", err)
+		return nil
+	}
+	fi, err := os.Lstat(filepath.Join(root, target))
+	if err != nil || !isScanned(fi, target) {
+		return nil
+	}
+	klog.V(1).InfoS("skipping symlink", "path", innerPath, "target", target)
+	return types.NewScanResult().SetPath(innerPath).SkippedBecause(skipReasonSymlink).SetSymlinkTarget(target)
+}
+
+// maxSymlinks is the maximum number of symlinks followed by
+// resolveSymlinks (the same as in Linux), to detect loops.
+const maxSymlinks = 40
+
+// resolveSymlinks resolves all symlinks in innerPath, a path under root,
+// as if root was the root directory (so absolute symlinks are relative to
+// root), and returns the resolved path. It is an error if a symlink points
+// outside of root, or there are too many symlinks (such as a loop).
+func resolveSymlinks(root, innerPath string) (string, error) {
+	var resolved string // Either empty (for root), or starting with "/".
+	rest := innerPath
+	for links := 0; rest != ""; {
+		var name string
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			name, rest = rest[:i], rest[i+1:]
+		} else {
+			name, rest = rest, ""
+		}
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "" {
+				return "", fmt.Errorf("%s: points outside of %s", innerPath, root)
 			}
-			results.Append(res)
+			resolved = resolved[:strings.LastIndexByte(resolved, '/')]
+			continue
+		}
+
+		next := resolved + "/" + name
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("%s: %w", innerPath, syscall.ELOOP)
 		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = ""
+		}
+		rest = target + "/" + rest
 	}
-	return results
+	if resolved == "" {
+		return "/", nil
+	}
+	return resolved, nil
+}
+
+// inodeSet is a set of files, identified by the device and inode numbers,
+// safe for concurrent use. A nil set is always empty.
+type inodeSet struct {
+	mu    sync.Mutex
+	files map[[2]uint64]bool
+}
+
+// add adds the file to the set, and tells if it was not there.
+func (s *inodeSet) add(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if s == nil || !ok {
+		return true
+	}
+	key := [2]uint64{uint64(st.Dev), uint64(st.Ino)} //nolint:unconvert // Not uint64 on all platforms.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files[key] {
+		return false
+	}
+	if s.files == nil {
+		s.files = make(map[[2]uint64]bool)
+	}
+	s.files[key] = true
+	return true
 }
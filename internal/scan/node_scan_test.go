@@ -0,0 +1,107 @@
+package scan
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSymlinks(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/libexec/foo"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/libexec/foo/foo"), nil, 0o755))
+	for link, target := range map[string]string{
+		"usr/bin/abs":      "/usr/libexec/foo/foo",
+		"usr/bin/rel":      "../libexec/foo/foo",
+		"usr/bin/chain":    "rel",
+		"usr/bin/dir":      "../libexec/foo",
+		"usr/bin/loop":     "loop2",
+		"usr/bin/loop2":    "loop",
+		"usr/bin/outside":  "../../../etc/passwd",
+		"usr/bin/dangling": "/nonexistent",
+		"lib":              "usr/libexec",
+	} {
+		require.NoError(t, os.Symlink(target, filepath.Join(root, link)))
+	}
+
+	for path, want := range map[string]string{
+		"/usr/bin/abs":     "/usr/libexec/foo/foo",
+		"/usr/bin/rel":     "/usr/libexec/foo/foo",
+		"/usr/bin/chain":   "/usr/libexec/foo/foo",
+		"/usr/bin/dir/foo": "/usr/libexec/foo/foo",
+		"/lib/foo/foo":     "/usr/libexec/foo/foo",
+		"/usr/bin/dir":     "/usr/libexec/foo",
+		"/usr/bin/../bin":  "/usr/bin",
+	} {
+		got, err := resolveSymlinks(root, path)
+		if assert.NoError(t, err, path) {
+			assert.Equal(t, want, got, path)
+		}
+	}
+
+	_, err := resolveSymlinks(root, "/usr/bin/loop")
+	assert.True(t, errors.Is(err, syscall.ELOOP), err)
+	_, err = resolveSymlinks(root, "/usr/bin/outside")
+	assert.ErrorContains(t, err, "points outside of")
+	_, err = resolveSymlinks(root, "/usr/bin/dangling")
+	assert.True(t, errors.Is(err, fs.ErrNotExist), err)
+}
+
+func TestSymlinkResult(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/libexec"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/libexec/foo"), nil, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/libexec/data"), nil, 0o644))
+	for link, target := range map[string]string{
+		"usr/bin/foo":      "/usr/libexec/foo",
+		"usr/bin/data":     "../libexec/data",
+		"usr/bin/dir":      "../libexec",
+		"usr/bin/dangling": "/nonexistent",
+	} {
+		require.NoError(t, os.Symlink(target, filepath.Join(root, link)))
+	}
+
+	res := symlinkResult(root, "/usr/bin/foo")
+	require.NotNil(t, res)
+	assert.True(t, res.Skip)
+	assert.Equal(t, "/usr/bin/foo", res.Path)
+	assert.Equal(t, "symlink", res.SkipReason)
+	assert.Equal(t, "/usr/libexec/foo", res.SymlinkTarget)
+	assert.Equal(t, "symlink -> /usr/libexec/foo", skipNote(res, "\n"))
+
+	// Only the symlinks to files which would be scanned are reported.
+	for _, path := range []string{"/usr/bin/data", "/usr/bin/dir", "/usr/bin/dangling"} {
+		assert.Nil(t, symlinkResult(root, path), path)
+	}
+}
+
+func TestInodeSet(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o755))
+	require.NoError(t, os.Link(file, filepath.Join(dir, "link")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), nil, 0o755))
+	stat := func(name string) os.FileInfo {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return fi
+	}
+
+	var seen inodeSet
+	assert.True(t, seen.add(stat("file")))
+	assert.False(t, seen.add(stat("link")), "hard link to the same file")
+	assert.True(t, seen.add(stat("other")))
+
+	// A nil set is never updated.
+	var none *inodeSet
+	assert.True(t, none.add(stat("file")))
+	assert.True(t, none.add(stat("file")))
+}
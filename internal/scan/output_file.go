@@ -0,0 +1,74 @@
+package scan
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/multierr"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// gzipFile is a file written via a gzip writer.
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+// Close flushes and closes the gzip writer, then closes the file.
+func (g *gzipFile) Close() error {
+	err := g.Writer.Close()
+	return multierr.Append(err, g.f.Close())
+}
+
+// isCompressed tells if the output file is to be gzip-compressed (with
+// --compress, or if the file name ends with .gz).
+func isCompressed(cfg *types.Config) bool {
+	return cfg.Compress || strings.HasSuffix(cfg.OutputFile, ".gz")
+}
+
+// createOutputFile creates cfg.OutputFile, which is transparently
+// gzip-compressed if needed (see isCompressed). The caller must close it.
+func createOutputFile(cfg *types.Config) (io.WriteCloser, error) {
+	f, err := os.OpenFile(cfg.OutputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o777)
+	if err != nil {
+		return nil, err
+	}
+	if !isCompressed(cfg) {
+		return f, nil
+	}
+	return &gzipFile{Writer: gzip.NewWriter(f), f: f}, nil
+}
+
+// writeOutputFile writes data to cfg.OutputFile (see createOutputFile).
+func writeOutputFile(cfg *types.Config, data []byte) error {
+	w, err := createOutputFile(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	// Close even if the write failed, so the file is not leaked.
+	return multierr.Append(err, w.Close())
+}
+
+// readFile is like os.ReadFile, but also decompresses the file
+// contents, if it is gzip-compressed (such as a report written
+// with --compress).
+func readFile(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) { // Gzip magic.
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
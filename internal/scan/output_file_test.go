@@ -0,0 +1,40 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestWriteOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte(`{"results": []}`)
+	for _, tc := range []struct {
+		cfg        types.Config
+		compressed bool
+	}{
+		{cfg: types.Config{OutputFile: filepath.Join(dir, "report.json")}},
+		{cfg: types.Config{OutputFile: filepath.Join(dir, "report.json.gz")}, compressed: true},
+		{cfg: types.Config{OutputFile: filepath.Join(dir, "report.z"), Compress: true}, compressed: true},
+	} {
+		require.NoError(t, writeOutputFile(&tc.cfg, data))
+		raw, err := os.ReadFile(tc.cfg.OutputFile)
+		require.NoError(t, err)
+		if tc.compressed {
+			assert.NotEqual(t, data, raw, tc.cfg.OutputFile)
+		} else {
+			assert.Equal(t, data, raw, tc.cfg.OutputFile)
+		}
+
+		got, err := readFile(tc.cfg.OutputFile)
+		require.NoError(t, err)
+		assert.Equal(t, data, got, tc.cfg.OutputFile)
+		_, err = readJSONReport(tc.cfg.OutputFile)
+		assert.NoError(t, err, tc.cfg.OutputFile)
+	}
+}
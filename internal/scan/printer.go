@@ -1,15 +1,22 @@
 package scan
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/check-payload/internal/types"
+	"github.com/openshift/check-payload/internal/validations"
 )
 
 const (
@@ -19,54 +26,348 @@ const (
 	colTitleExeName      = "Executable Name"
 	colTitlePassedFailed = "Status"
 	colTitleImage        = "Image"
+	colTitleSHA256       = "SHA256"
+	colTitleException    = "Exception"
+	colTitleArch         = "Arch"
+	colTitleLevel        = "Level"
 )
 
 func PrintResults(cfg *types.Config, results []*types.ScanResults) {
-	var failureReport, warningReport, successReport string
+	if cfg.DryRun {
+		printDryRun(results)
+		return
+	}
+	if cfg.ReportUnusedExceptions {
+		// Printed after everything else.
+		defer printUnusedExceptions(cfg)
+	}
+	incomplete := StoppedOnFailure()
+	if incomplete {
+		dropCanceled(results)
+	}
+	// The summary is for all results, including those not shown.
+	sum := newSummary(results)
+	if incomplete {
+		sum.Incomplete = "stopped on the first failure (--fail-fast)"
+		klog.Warning("the scan is incomplete: ", sum.Incomplete)
+	}
+	if n := sampledOut.Load(); n > 0 {
+		sum.Sampled = fmt.Sprintf("only the largest files of each image were scanned (--sample-largest %d), %d other files were not", cfg.SampleLargest, n)
+		klog.Warning("the scan is sampled: ", sum.Sampled)
+	}
+	if cfg.SummaryOnly {
+		printSummary(cfg, sum)
+		return
+	}
+	shown := filterResults(cfg, results)
+	switch cfg.OutputFormat {
+	case "json":
+		if cfg.ReportDuplicates {
+			printDocument(cfg, shown, sum, duplicatesJSONWriter(findDuplicates(results)))
+			break
+		}
+		printDocument(cfg, shown, sum, writeJSON)
+	case "sarif":
+		printDocument(cfg, shown, sum, writeSarif)
+	case "junit":
+		printDocument(cfg, shown, sum, writeJUnit)
+	case "csv":
+		printCSVReport(cfg, results, shown, sum)
+	case "html":
+		if cfg.HTMLTemplate != nil {
+			printDocument(cfg, shown, sum, htmlTemplateWriter(cfg.HTMLTemplate))
+			break
+		}
+		printReport(cfg, results, shown, sum)
+	default:
+		printReport(cfg, results, shown, sum)
+	}
+	if cfg.OutputDir != "" {
+		if err := writeOutputDir(cfg, results); err != nil {
+			klog.Errorf("could not write reports to %s: %v", cfg.OutputDir, err)
+		}
+	}
+
+	if cfg.PrintExceptions {
+		displayExceptions(results)
+	}
+}
+
+// printDocument prints a machine-readable report generated by write
+// to stdout, and to cfg.OutputFile, if set.
+func printDocument(cfg *types.Config, results []*types.ScanResults, sum *summary, write func(io.Writer, []*types.ScanResults, *summary, *types.ReportMetadata) error) {
+	var buf bytes.Buffer
+	if err := write(&buf, results, sum, cfg.Metadata); err != nil {
+		klog.Errorf("could not generate %s report: %v", cfg.OutputFormat, err)
+		return
+	}
+	fmt.Print(buf.String())
+
+	if cfg.OutputFile != "" {
+		if err := writeOutputFile(cfg, buf.Bytes()); err != nil {
+			klog.Errorf("could not write file: %v", err)
+		}
+	}
+}
+
+// printDryRun prints the list of files which would be scanned,
+// and their number.
+func printDryRun(results []*types.ScanResults) {
+	n := 0
+	for _, result := range results {
+		for _, res := range result.Items {
+			if !res.IsSuccess() {
+				// Operational error, such as failed pull.
+				fmt.Printf("error: %s %v\n", getImage(res), res.Error.GetError())
+				continue
+			}
+			if image := getImage(res); image != "" {
+				fmt.Print(image, " ")
+			}
+			fmt.Println(res.Path)
+			n++
+		}
+	}
+	fmt.Printf("---- Dry run: %d files to scan\n", n)
+}
+
+// printSummary only prints the summary, in a given format,
+// to stdout, and to cfg.OutputFile, if set.
+func printSummary(cfg *types.Config, sum *summary) {
+	var out string
+	if cfg.OutputFormat == "json" {
+		data, err := json.MarshalIndent(struct {
+			Metadata *types.ReportMetadata `json:"metadata,omitempty"`
+			Summary  *summary              `json:"summary"`
+		}{cfg.Metadata, sum}, "", "  ")
+		if err != nil { // Should never happen.
+			klog.Errorf("could not generate summary: %v", err)
+			return
+		}
+		out = string(data)
+	} else {
+		out = renderMetadata(cfg.Metadata, cfg.OutputFormat) + renderSummary(sum, cfg.OutputFormat)
+	}
+	fmt.Println(out)
+
+	if cfg.OutputFile != "" {
+		if err := writeOutputFile(cfg, []byte(out+"\n")); err != nil {
+			klog.Errorf("could not write file: %v", err)
+		}
+	}
+}
+
+// renderSummary renders the summary as a table in a given format.
+func renderSummary(sum *summary, format string) string {
+	tw := table.NewWriter()
+	row := func(s *summary) table.Row {
+		duration := time.Duration(s.Duration * float64(time.Second)).Round(time.Second)
+		row := table.Row{s.Total, s.Passed, s.Failed, s.Warnings, s.Skipped, duration}
+		if format == "csv" {
+			row = append(row, sum.Incomplete)
+		}
+		return row
+	}
+	header := table.Row{"Total", "Passed", "Failed", "Warnings", "Skipped", "Duration"}
+	if format == "csv" {
+		// A caption is not a valid csv row, so the incomplete scan
+		// reason is a column (which is omitted, if empty).
+		header = append(header, "Incomplete")
+		tw.SuppressEmptyColumns()
+	}
+	if len(sum.Arches) == 0 {
+		tw.AppendHeader(header)
+		tw.AppendRow(row(sum))
+	} else {
+		// Per-arch breakdown, followed by the overall numbers.
+		tw.AppendHeader(append(table.Row{colTitleArch}, header...))
+		for _, a := range sum.Arches {
+			tw.AppendRow(append(table.Row{a.Arch}, row(a.summary)...))
+		}
+		tw.AppendRow(append(table.Row{"all"}, row(sum)...))
+	}
+	var captions []string
+	if sum.Incomplete != "" && format != "csv" {
+		captions = append(captions, "INCOMPLETE: "+sum.Incomplete)
+	}
+	if sum.Sampled != "" {
+		captions = append(captions, "SAMPLED: "+sum.Sampled)
+	}
+	tw.SetCaption(strings.Join(captions, "\n"))
+	return renderTable(tw, format)
+}
+
+// slowestImagesCount is the number of images shown in the
+// slowest images report.
+const slowestImagesCount = 10
+
+// renderSlowestImages renders the slowest images as a table in a given format.
+func renderSlowestImages(images []*types.ScanResults, format string) string {
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{colTitleTagName, colTitleImage, colTitleArch, "Duration"})
+	for _, result := range images {
+		tag, image := "", ""
+		if result.Tag != nil {
+			tag = result.Tag.Name
+			if result.Tag.From != nil {
+				image = result.Tag.From.Name
+			}
+		}
+		tw.AppendRow(table.Row{tag, image, result.Arch, result.Duration().Round(time.Second)})
+	}
+	tw.SuppressEmptyColumns()
+	return renderTable(tw, format)
+}
+
+// renderTable renders the table in a given format (table by default).
+func renderTable(tw table.Writer, format string) string {
+	switch format {
+	case "csv":
+		return tw.RenderCSV()
+	case "markdown":
+		return tw.RenderMarkdown()
+	case "html":
+		return tw.RenderHTML()
+	}
+	return tw.Render()
+}
+
+// printReport prints the shown results as a table (or csv etc.), and
+// the status of the run according to all results.
+func printReport(cfg *types.Config, results, shown []*types.ScanResults, sum *summary) {
+	out, combinedReport := renderTextReport(cfg, results, shown, sum)
+	fmt.Print(out)
+
+	if cfg.OutputFile != "" {
+		if cfg.Color {
+			// Colors are for the terminal only.
+			noColor := *cfg
+			noColor.Color = false
+			_, combinedReport = renderTextReport(&noColor, results, shown, sum)
+		}
+		if err := writeOutputFile(cfg, []byte(combinedReport)); err != nil {
+			klog.Errorf("could not write file: %v", err)
+		}
+	}
+}
+
+// renderTextReport renders the report printed by printReport, both in
+// the form printed to stdout, and the one written to a file.
+func renderTextReport(cfg *types.Config, results, shown []*types.ScanResults, sum *summary) (string, string) {
+	failureReport, warningReport, successReport := generateReport(shown, cfg)
+	tables := map[string]string{"failed": failureReport, "warning": warningReport, "success": successReport}
+
+	var out, combinedReport strings.Builder
+	_ = writeTextReport(&out, &combinedReport, cfg, results, sum, func(w io.Writer, status string) error {
+		_, err := io.WriteString(w, tables[status])
+		return err
+	})
+	return out.String(), combinedReport.String()
+}
 
-	var combinedReport string
+// writeTextReport writes the report printed by printReport, both in the
+// form printed to stdout (to out), and the one written to a file (to file).
+// The tables of results of a given status ("failed", "warning", or
+// "success") are written by writeTable, the same for both.
+func writeTextReport(out, file io.Writer, cfg *types.Config, results []*types.ScanResults, sum *summary, writeTable func(w io.Writer, status string) error) error {
+	var err error
+	write := func(w io.Writer, s string) {
+		if err == nil {
+			_, err = io.WriteString(w, s)
+		}
+	}
+	both := io.MultiWriter(out, file)
+	writeResults := func(status string) {
+		if err == nil {
+			err = writeTable(both, status)
+		}
+	}
+	// section writes a section with a title, and the contents written by body.
+	section := func(title string, body func()) {
+		write(out, "---- "+title+"\n")
+		write(file, "\n\n ---- "+title+"\n")
+		body()
+		write(out, "\n")
+	}
+	content := func(s string) func() {
+		return func() { write(both, s) }
+	}
 
-	failureReport, warningReport, successReport = generateReport(results, cfg)
+	if metadata := renderMetadata(cfg.Metadata, cfg.OutputFormat); metadata != "" {
+		write(both, metadata)
+	}
 
 	isWarnings := IsWarnings(results)
 	isFailed := IsFailed(results)
-	if isFailed {
-		fmt.Println("---- Failure Report")
-		fmt.Println(failureReport)
-		combinedReport = failureReport
+	// With --only-failures (or --only-warnings), hide the other reports.
+	showFailures := cfg.OnlyFailures || !cfg.OnlyWarnings
+	showWarnings := cfg.OnlyWarnings || !cfg.OnlyFailures
+	showSuccesses := cfg.Verbose && !cfg.OnlyFailures && !cfg.OnlyWarnings
+	if isFailed && showFailures {
+		// The file starts with the failures, with no title.
+		write(out, "---- Failure Report\n")
+		writeResults("failed")
+		write(out, "\n")
 	}
 
-	if isWarnings {
-		fmt.Println("---- Warning Report")
-		fmt.Println(warningReport)
-		combinedReport += "\n\n ---- Warning Report\n" + warningReport
+	if isWarnings && showWarnings {
+		section("Warning Report", func() { writeResults("warning") })
 	}
 
-	if cfg.Verbose {
-		fmt.Println("---- Success Report")
-		fmt.Println(successReport)
-		combinedReport += "\n\n ---- Success Report\n" + successReport
+	if showSuccesses {
+		section("Success Report", func() { writeResults("success") })
 	}
 
 	if !isFailed && isWarnings {
-		combinedReport += "\n\n ---- Successful run with warnings\n"
-		fmt.Println("---- Successful run with warnings")
+		write(file, "\n\n ---- Successful run with warnings\n")
+		write(out, "---- Successful run with warnings\n")
 	}
 
 	if !isFailed && !isWarnings {
-		combinedReport += "\n\n ---- Successful run\n"
-		fmt.Println("---- Successful run")
+		write(file, "\n\n ---- Successful run\n")
+		write(out, "---- Successful run\n")
 	}
 
-	if cfg.OutputFile != "" {
-		if err := os.WriteFile(cfg.OutputFile, []byte(combinedReport), 0o777); err != nil {
-			klog.Errorf("could not write file: %v", err)
-		}
+	if images := slowestImages(results, slowestImagesCount); len(images) > 1 {
+		section("Slowest Images", content(renderSlowestImages(images, cfg.OutputFormat)))
 	}
 
-	if cfg.PrintExceptions {
-		displayExceptions(results)
+	if cfg.ReportDuplicates {
+		section("Duplicate Binaries", content(renderDuplicates(findDuplicates(results), cfg.OutputFormat)))
+	}
+
+	section("Summary", content(renderSummary(sum, cfg.OutputFormat)))
+	return err
+}
+
+// PrintValidations prints the list of all registered validations
+// in a given format.
+func PrintValidations(format string) error {
+	list := validations.Validations()
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Name", "Kind", "Severity", "Description"})
+	for _, v := range list {
+		tw.AppendRow(table.Row{v.Name, v.Kind, v.Severity.String(), v.Description})
 	}
+	switch format {
+	case "table":
+		fmt.Println(tw.Render())
+	case "csv":
+		fmt.Println(tw.RenderCSV())
+	case "markdown":
+		fmt.Println(tw.RenderMarkdown())
+	case "html":
+		fmt.Println(tw.RenderHTML())
+	default:
+		return fmt.Errorf("output format %q is not supported", format)
+	}
+	return nil
 }
 
 func getFilterPrefix(res *types.ScanResult) string {
@@ -82,6 +383,31 @@ func getFilterPrefix(res *types.ScanResult) string {
 	return ""
 }
 
+// printUnusedExceptions prints the config exceptions which have not
+// matched any file during the scan. If the report is a document (such as
+// json) written to stdout, they are logged instead, not to break it.
+func printUnusedExceptions(cfg *types.Config) {
+	if cfg.ResumeFile != "" {
+		klog.Warning("exceptions only used by images scanned before resuming are reported as unused")
+	}
+	unused := cfg.UnusedExceptions()
+	if isDocumentToStdout(cfg) {
+		klog.InfoS("unused exceptions", "count", len(unused))
+		for _, u := range unused {
+			klog.InfoS("unused exception", "rule", u)
+		}
+		return
+	}
+	if len(unused) == 0 {
+		fmt.Println("No unused exceptions.")
+		return
+	}
+	fmt.Printf("Unused exceptions (%d):\n", len(unused))
+	for _, u := range unused {
+		fmt.Println("  " + u)
+	}
+}
+
 func displayExceptions(results []*types.ScanResults) {
 	// Per-prefix map of per-error map of files to be excluded.
 	exceptions := make(map[string]map[string]mapset.Set[string])
@@ -132,7 +458,7 @@ func displayExceptions(results []*types.ScanResults) {
 }
 
 func generateReport(results []*types.ScanResults, cfg *types.Config) (string, string, string) {
-	ftw, wtw, stw := renderReport(results)
+	ftw, wtw, stw := renderReport(cfg, results)
 	return generateOutputString(cfg, ftw, wtw, stw)
 }
 
@@ -184,44 +510,175 @@ func getImage(res *types.ScanResult) string {
 	return ""
 }
 
-func renderReport(results []*types.ScanResults) (failures table.Writer, warnings table.Writer, successes table.Writer) {
+// resultPaths returns the path of the result, followed by the other paths
+// of the same binary, if collapsed into the result (see --dedupe), joined
+// by sep.
+func resultPaths(res *types.ScanResult, sep string) string {
+	return strings.Join(append([]string{res.Path}, res.DuplicatePaths...), sep)
+}
+
+// skipNote returns the config rules which suppressed the errors found in
+// the binary (or filtered it out), or the reason it was skipped, joined
+// by sep.
+func skipNote(res *types.ScanResult, sep string) string {
+	notes := res.Exceptions
+	if res.SkipReason != "" {
+		reason := res.SkipReason
+		if res.SymlinkTarget != "" {
+			reason += " -> " + res.SymlinkTarget
+		}
+		notes = append([]string{reason}, notes...)
+	}
+	return strings.Join(notes, sep)
+}
+
+// statusSymbols are prepended to the result status in the table report.
+var statusSymbols = map[string]string{
+	"failed":  "✗",
+	"warning": "⚠",
+}
+
+// statusColors are the colors of the result status, used for the html
+// report (as CSS classes), and for the colored table report.
+var statusColors = map[string]text.Colors{
+	"failed":  {text.FgRed},
+	"warning": {text.FgYellow},
+	"success": {text.FgGreen},
+}
+
+// statusLabel returns the result status to show in the report
+// of a given format.
+func statusLabel(res *types.ScanResult, format string) string {
+	status := res.Status()
+	if format == "table" {
+		if symbol, ok := statusSymbols[status]; ok {
+			return symbol + " " + status
+		}
+	}
+	return status
+}
+
+// isArchShown tells if the results have the Arch column, i.e. if all the
+// image architectures are scanned (see --all-arches), or if the results
+// are of more than one architecture.
+func isArchShown(cfg *types.Config, results []*types.ScanResults) bool {
+	if cfg.AllArches {
+		return true
+	}
+	arch := ""
+	for _, result := range results {
+		for _, res := range result.Items {
+			if res.Arch == "" {
+				continue
+			}
+			if arch != "" && res.Arch != arch {
+				return true
+			}
+			arch = res.Arch
+		}
+	}
+	return false
+}
+
+// resultHeaders returns the table headers of the failures (and warnings),
+// and of the successes, in the report of a given format, with the Arch
+// column if arch is set (see isArchShown).
+func resultHeaders(format string, arch bool) (failures, successes table.Row) {
+	failures = table.Row{colTitleOperatorName, colTitleTagName, colTitleRPMName, colTitleExeName, colTitleLevel, colTitlePassedFailed, colTitleImage}
+	successes = table.Row{colTitleOperatorName, colTitleTagName, colTitleExeName, colTitleImage}
+	if arch {
+		failures = append(failures, colTitleArch)
+		successes = append(successes, colTitleArch)
+	}
+	successes = append(successes, colTitleException)
+	// The digests are only useful for machine processing.
+	if format == "csv" {
+		failures = append(failures, colTitleSHA256)
+		successes = append(successes, colTitleSHA256)
+	}
+	return failures, successes
+}
+
+// resultRow returns the table row of the result in the report of a given
+// format (see resultHeaders).
+func resultRow(res *types.ScanResult, format string, arch bool) table.Row {
+	component := getComponent(res)
+	tag := getTag(res)
+	image := getImage(res)
+
+	var row table.Row
+	if res.IsLevel(types.Error) || res.IsLevel(types.Warning) {
+		row = table.Row{component, tag, res.RPM, resultPaths(res, "\n"), statusLabel(res, format), res.Error.GetError(), image}
+		if arch {
+			row = append(row, res.Arch)
+		}
+	} else {
+		row = table.Row{component, tag, resultPaths(res, "\n"), image}
+		if arch {
+			row = append(row, res.Arch)
+		}
+		row = append(row, skipNote(res, "\n"))
+	}
+	if format == "csv" {
+		row = append(row, res.SHA256)
+	}
+	return row
+}
+
+func renderReport(cfg *types.Config, results []*types.ScanResults) (failures table.Writer, warnings table.Writer, successes table.Writer) {
 	var failureTableRows, warningTableRows, successTableRows []table.Row
 
-	failureRowHeader := table.Row{colTitleOperatorName, colTitleTagName, colTitleRPMName, colTitleExeName, colTitlePassedFailed, colTitleImage}
-	successRowHeader := table.Row{colTitleOperatorName, colTitleTagName, colTitleExeName, colTitleImage}
+	arch := isArchShown(cfg, results)
+	failureRowHeader, successRowHeader := resultHeaders(cfg.OutputFormat, arch)
 
 	for _, result := range results {
 		for _, res := range result.Items {
-			component := getComponent(res)
-			tag := getTag(res)
-			image := getImage(res)
-
-			if res.IsLevel(types.Error) {
-				failureTableRows = append(failureTableRows, table.Row{component, tag, res.RPM, res.Path, res.Error.GetError(), image})
-			} else if res.IsLevel(types.Warning) {
-				warningTableRows = append(warningTableRows, table.Row{component, tag, res.RPM, res.Path, res.Error.GetError(), image})
-			} else {
-				successTableRows = append(successTableRows, table.Row{component, tag, res.Path, image})
+			row := resultRow(res, cfg.OutputFormat, arch)
+			switch {
+			case res.IsLevel(types.Error):
+				failureTableRows = append(failureTableRows, row)
+			case res.IsLevel(types.Warning):
+				warningTableRows = append(warningTableRows, row)
+			default:
+				successTableRows = append(successTableRows, row)
 			}
 		}
 	}
 
+	// Color the status in the html report (as a CSS class),
+	// or in the table report, if enabled.
+	color := cfg.OutputFormat == "html" || (cfg.OutputFormat == "table" && cfg.Color)
+	colorLevel := func(tw table.Writer, status string) {
+		if color {
+			tw.SetColumnConfigs([]table.ColumnConfig{{Name: colTitleLevel, Colors: statusColors[status]}})
+		}
+	}
+
 	ftw := table.NewWriter()
 	ftw.SuppressEmptyColumns()
 	ftw.AppendHeader(failureRowHeader)
 	ftw.AppendRows(failureTableRows)
 	ftw.SetIndexColumn(1)
+	ftw.SetHTMLCSSClass(table.DefaultHTMLCSSClass + " check-payload-failed")
+	colorLevel(ftw, "failed")
 
 	wtw := table.NewWriter()
 	wtw.SuppressEmptyColumns()
 	wtw.AppendHeader(failureRowHeader)
 	wtw.AppendRows(warningTableRows)
 	wtw.SetIndexColumn(1)
+	wtw.SetHTMLCSSClass(table.DefaultHTMLCSSClass + " check-payload-warning")
+	colorLevel(wtw, "warning")
 
 	stw := table.NewWriter()
 	stw.SuppressEmptyColumns()
 	stw.AppendHeader(successRowHeader)
 	stw.AppendRows(successTableRows)
 	stw.SetIndexColumn(1)
+	stw.SetHTMLCSSClass(table.DefaultHTMLCSSClass + " check-payload-success")
+	if cfg.OutputFormat == "table" && cfg.Color {
+		// There is no status column, so color the file paths.
+		stw.SetColumnConfigs([]table.ColumnConfig{{Name: colTitleExeName, Colors: statusColors["success"]}})
+	}
 	return ftw, wtw, stw
 }
@@ -0,0 +1,159 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/multierr"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// printCSVReport prints the report in the csv format, like printReport,
+// but writes the results as the rows are generated (to stdout, and to
+// cfg.OutputFile, if set), rather than rendering the whole report in memory
+// first, which may take a lot of memory for large scans.
+func printCSVReport(cfg *types.Config, results, shown []*types.ScanResults, sum *summary) {
+	out := bufio.NewWriter(os.Stdout)
+	var file io.Writer = io.Discard
+	if cfg.OutputFile != "" {
+		f, err := createOutputFile(cfg)
+		if err != nil {
+			klog.Errorf("could not write file: %v", err)
+		} else {
+			of := &csvOutputFile{w: bufio.NewWriter(f), f: f}
+			defer func() {
+				if err := of.Close(); err != nil {
+					klog.Errorf("could not write file: %v", err)
+				}
+			}()
+			file = of
+		}
+	}
+
+	err := writeTextReport(out, file, cfg, results, sum, func(w io.Writer, status string) error {
+		return writeCSVResults(w, shown, status, isArchShown(cfg, shown))
+	})
+	if err = multierr.Append(err, out.Flush()); err != nil {
+		klog.Errorf("could not print the report: %v", err)
+	}
+}
+
+// csvOutputFile is the output file of the csv report. An error writing it
+// is only returned by Close, so it does not stop printing the report.
+type csvOutputFile struct {
+	w   *bufio.Writer
+	f   io.WriteCloser
+	err error
+}
+
+func (o *csvOutputFile) Write(p []byte) (int, error) {
+	if o.err == nil {
+		_, o.err = o.w.Write(p)
+	}
+	return len(p), nil
+}
+
+func (o *csvOutputFile) Close() error {
+	err := o.err
+	if err == nil {
+		err = o.w.Flush()
+	}
+	return multierr.Append(err, o.f.Close())
+}
+
+// writeCSVResults writes the table of the results of a given status
+// ("failed", "warning", or "success") in the csv format, with the same
+// columns as in renderReport: the ones empty in all rows are omitted.
+// Like in table.Writer.RenderCSV, the rows are separated (rather than
+// terminated) by newlines, but the values are quoted as in RFC 4180.
+//
+// The results are walked twice (first to find the empty columns), so the
+// rows are never all kept in memory. The Arch column is only written if
+// arch is set (see isArchShown).
+func writeCSVResults(w io.Writer, results []*types.ScanResults, status string, arch bool) error {
+	header, successHeader := resultHeaders("csv", arch)
+	if status == "success" {
+		header = successHeader
+	}
+	walk := func(fn func(row []string) error) error {
+		for _, result := range results {
+			for _, res := range result.Items {
+				if resultStatus(res) != status {
+					continue
+				}
+				row := make([]string, 0, len(header))
+				for _, v := range resultRow(res, "csv", arch) {
+					row = append(row, fmt.Sprint(v))
+				}
+				if err := fn(row); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	used := make([]bool, len(header))
+	_ = walk(func(row []string) error {
+		for i, v := range row {
+			used[i] = used[i] || v != ""
+		}
+		return nil
+	})
+
+	var (
+		buf   bytes.Buffer
+		enc   = csv.NewWriter(&buf)
+		first = true
+	)
+	writeRow := func(row []string) error {
+		var values []string
+		for i, v := range row {
+			if used[i] {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		buf.Reset()
+		if !first {
+			buf.WriteByte('\n')
+		}
+		first = false
+		if err := enc.Write(values); err != nil {
+			return err
+		}
+		enc.Flush()
+		// Strip the row terminator.
+		_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+		return err
+	}
+
+	headerRow := make([]string, len(header))
+	for i, v := range header {
+		headerRow[i] = fmt.Sprint(v)
+	}
+	if err := writeRow(headerRow); err != nil {
+		return err
+	}
+	return walk(writeRow)
+}
+
+// resultStatus returns the status of the result, as used to put it into
+// one of the tables by renderReport.
+func resultStatus(res *types.ScanResult) string {
+	switch {
+	case res.IsLevel(types.Error):
+		return "failed"
+	case res.IsLevel(types.Warning):
+		return "warning"
+	}
+	return "success"
+}
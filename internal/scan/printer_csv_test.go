@@ -0,0 +1,83 @@
+package scan
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func csvTestResults() []*types.ScanResults {
+	tag := &v1.TagReference{Name: "tag", From: &corev1.ObjectReference{Name: "quay.io/foo@sha256:1"}}
+	return []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/fail").SetTag(tag).SetError(errors.New("bad"))).
+			Append(types.NewScanResult().SetPath("/warn").SetValidationError(types.NewValidationError(errors.New("meh")).SetWarning())).
+			Append(types.NewScanResult().SetPath("/ok").SetTag(tag).Success()),
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/ok2").Success()),
+	}
+}
+
+func TestWriteCSVResults(t *testing.T) {
+	results := csvTestResults()
+	results[0].Items[2].SHA256 = "abc"
+	results[0].Items[2].Exceptions = []string{"rule 1", "rule 2"}
+	for _, cfg := range []*types.Config{
+		{OutputFormat: "csv"},
+		{OutputFormat: "csv", Verbose: true},
+		{OutputFormat: "csv", Verbose: true, OnlyWarnings: true},
+	} {
+		wantOut, wantFile := renderTextReport(cfg, results, results, newSummary(results))
+
+		var out, file strings.Builder
+		err := writeTextReport(&out, &file, cfg, results, newSummary(results), func(w io.Writer, status string) error {
+			return writeCSVResults(w, results, status, isArchShown(cfg, results))
+		})
+		require.NoError(t, err)
+		assert.Equal(t, wantOut, out.String())
+		assert.Equal(t, wantFile, file.String())
+	}
+}
+
+func TestWriteCSVResultsQuoting(t *testing.T) {
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/fail").SetError(errors.New(`missing "a", "b"`))),
+	}
+	var out strings.Builder
+	require.NoError(t, writeCSVResults(&out, results, "failed", false))
+	assert.Equal(t, "Executable Name,Level,Status\n"+`/fail,failed,"missing ""a"", ""b"""`, out.String())
+
+	out.Reset()
+	require.NoError(t, writeCSVResults(&out, results, "success", false))
+	assert.Empty(t, out.String())
+}
+
+func TestPrintCSVReportFile(t *testing.T) {
+	results := csvTestResults()
+	for _, compress := range []bool{false, true} {
+		cfg := &types.Config{OutputFormat: "csv", OutputFile: filepath.Join(t.TempDir(), "report.csv"), Compress: compress}
+		printCSVReport(cfg, results, results, newSummary(results))
+
+		data, err := readFile(cfg.OutputFile)
+		require.NoError(t, err)
+		_, want := renderTextReport(cfg, results, results, newSummary(results))
+		assert.Equal(t, want, string(data))
+	}
+
+	// A file which can not be written does not stop printing.
+	cfg := &types.Config{OutputFormat: "csv", OutputFile: filepath.Join(t.TempDir(), "nonexistent", "report.csv")}
+	printCSVReport(cfg, results, results, newSummary(results))
+	_, err := os.Stat(cfg.OutputFile)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,150 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// unsafeFileChars are characters replaced in file names made of image refs.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// formatExt maps output formats to report file name extensions.
+var formatExt = map[string]string{
+	"json":     ".json",
+	"sarif":    ".sarif",
+	"junit":    ".xml",
+	"csv":      ".csv",
+	"markdown": ".md",
+	"html":     ".html",
+	"table":    ".txt",
+}
+
+// indexEntry describes a per-image report file in the index.
+type indexEntry struct {
+	Tag   string `json:"tag,omitempty"`
+	Image string `json:"image,omitempty"`
+	Arch  string `json:"arch,omitempty"`
+	// File is the report file name, relative to the output directory.
+	File    string   `json:"file"`
+	Summary *summary `json:"summary"`
+}
+
+// reportFileName returns the report file name for the image scan results.
+func reportFileName(result *types.ScanResults, ext string) string {
+	name := "results"
+	if result.Tag != nil && result.Tag.From != nil && result.Tag.From.Name != "" {
+		name = result.Tag.From.Name
+	} else if result.Tag != nil && result.Tag.Name != "" {
+		name = result.Tag.Name
+	}
+	if result.Arch != "" {
+		name += "-" + result.Arch
+	}
+	return unsafeFileChars.ReplaceAllString(name, "_") + ext
+}
+
+// writeOutputDir writes a separate report for every image (in
+// cfg.OutputFormat) to cfg.OutputDir, and an index of those.
+func writeOutputDir(cfg *types.Config, results []*types.ScanResults) error {
+	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+		return err
+	}
+	ext, ok := formatExt[cfg.OutputFormat]
+	if !ok {
+		ext = ".txt"
+	}
+
+	var index []indexEntry
+	used := make(map[string]bool)
+	for _, result := range results {
+		one := []*types.ScanResults{result}
+		sum := newSummary(one)
+		shown := filterResults(cfg, one)
+
+		var buf bytes.Buffer
+		switch cfg.OutputFormat {
+		case "json":
+			if err := writeJSON(&buf, shown, sum, cfg.Metadata); err != nil {
+				return err
+			}
+		case "sarif":
+			if err := writeSarif(&buf, shown, sum, cfg.Metadata); err != nil {
+				return err
+			}
+		case "junit":
+			if err := writeJUnit(&buf, shown, sum, cfg.Metadata); err != nil {
+				return err
+			}
+		case "html":
+			if cfg.HTMLTemplate != nil {
+				if err := htmlTemplateWriter(cfg.HTMLTemplate)(&buf, shown, sum, cfg.Metadata); err != nil {
+					return err
+				}
+				break
+			}
+			_, text := renderTextReport(cfg, one, shown, sum)
+			buf.WriteString(text)
+		default:
+			_, text := renderTextReport(cfg, one, shown, sum)
+			buf.WriteString(text)
+		}
+
+		// Make the name unique, in case the same image is scanned twice.
+		name := reportFileName(result, ext)
+		base := strings.TrimSuffix(name, ext)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		used[name] = true
+		if err := os.WriteFile(filepath.Join(cfg.OutputDir, name), buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+
+		entry := indexEntry{File: name, Arch: result.Arch, Summary: sum}
+		if result.Tag != nil {
+			entry.Tag = result.Tag.Name
+			if result.Tag.From != nil {
+				entry.Image = result.Tag.From.Name
+			}
+		}
+		index = append(index, entry)
+	}
+
+	return writeIndex(cfg, index, ext)
+}
+
+// writeIndex writes the index of per-image reports, as a JSON array
+// (for json and sarif formats), or as a table in cfg.OutputFormat.
+func writeIndex(cfg *types.Config, index []indexEntry, ext string) error {
+	var data []byte
+	switch cfg.OutputFormat {
+	case "json", "sarif", "junit":
+		if index == nil {
+			index = []indexEntry{}
+		}
+		var err error
+		if data, err = json.MarshalIndent(index, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		ext = ".json"
+	default:
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{colTitleTagName, colTitleImage, colTitleArch, "File", "Total", "Passed", "Failed", "Warnings", "Skipped"})
+		for _, e := range index {
+			tw.AppendRow(table.Row{e.Tag, e.Image, e.Arch, e.File, e.Summary.Total, e.Summary.Passed, e.Summary.Failed, e.Summary.Warnings, e.Summary.Skipped})
+		}
+		tw.SuppressEmptyColumns()
+		data = []byte(renderTable(tw, cfg.OutputFormat) + "\n")
+	}
+	return os.WriteFile(filepath.Join(cfg.OutputDir, "index"+ext), data, 0o644)
+}
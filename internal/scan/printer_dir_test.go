@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestWriteOutputDir(t *testing.T) {
+	newResults := func(image string, err error) *types.ScanResults {
+		tag := &v1.TagReference{From: &corev1.ObjectReference{Name: image}}
+		res := types.NewScanResult().SetPath("/bin/foo").SetTag(tag)
+		if err != nil {
+			res.SetError(err)
+		}
+		results := types.NewScanResults().Append(res)
+		results.Tag = tag
+		return results
+	}
+	results := []*types.ScanResults{
+		newResults("quay.io/foo@sha256:1", types.ErrNotDynLinked),
+		newResults("quay.io/bar:latest", nil),
+		newResults("quay.io/bar:latest", nil),
+		newResults("quay.io/bar:latest", nil),
+	}
+
+	dir := t.TempDir()
+	cfg := &types.Config{OutputDir: dir, OutputFormat: "json"}
+	require.NoError(t, writeOutputDir(cfg, results))
+
+	var report jsonReport
+	data, err := os.ReadFile(filepath.Join(dir, "quay.io_foo_sha256_1.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, "failed", report.Results[0].Status)
+
+	var index []indexEntry
+	data, err = os.ReadFile(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &index))
+	require.Len(t, index, 4)
+	assert.Equal(t, "quay.io_bar_latest.json", index[1].File)
+	assert.Equal(t, "quay.io_bar_latest-2.json", index[2].File)
+	assert.Equal(t, "quay.io_bar_latest-3.json", index[3].File)
+	assert.Equal(t, 1, index[0].Summary.Failed)
+	assert.Equal(t, 1, index[1].Summary.Passed)
+
+	// Text formats.
+	dir = t.TempDir()
+	cfg = &types.Config{OutputDir: dir, OutputFormat: "csv"}
+	require.NoError(t, writeOutputDir(cfg, results[:1]))
+	assert.FileExists(t, filepath.Join(dir, "quay.io_foo_sha256_1.csv"))
+	assert.FileExists(t, filepath.Join(dir, "index.csv"))
+}
@@ -0,0 +1,38 @@
+package scan
+
+import (
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// htmlTemplateFuncs are the functions available to custom HTML templates,
+// in addition to the text/template builtins.
+var htmlTemplateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// LoadHTMLTemplate parses the custom HTML report template (see
+// --html-template) from file.
+func LoadHTMLTemplate(file string) (*template.Template, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(filepath.Base(file)).Funcs(htmlTemplateFuncs).Parse(string(data))
+}
+
+// htmlTemplateWriter returns a function to write the HTML report using
+// a custom template, which is executed with the same data as the JSON
+// report (see jsonReport).
+func htmlTemplateWriter(tmpl *template.Template) func(io.Writer, []*types.ScanResults, *summary, *types.ReportMetadata) error {
+	return func(w io.Writer, results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) error {
+		return tmpl.Execute(w, newJSONReport(results, sum, meta))
+	}
+}
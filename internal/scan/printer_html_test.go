@@ -0,0 +1,47 @@
+package scan
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestHTMLTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.tmpl")
+	tmplText := `<h1>{{ .Summary.Failed }} of {{ .Summary.Total }} failed</h1>
+{{- range .Results }}{{ if eq .Status "failed" }}
+<p class="{{ .Status }}">{{ .Path }}: {{ .Error }}</p>
+{{- end }}{{ end }}
+{{ upper "done" }}`
+	require.NoError(t, os.WriteFile(file, []byte(tmplText), 0o644))
+	tmpl, err := LoadHTMLTemplate(file)
+	require.NoError(t, err)
+
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/ok")).
+			Append(types.NewScanResult().SetPath("/bad").SetError(errors.New("<not fips>"))),
+	}
+	var buf bytes.Buffer
+	require.NoError(t, htmlTemplateWriter(tmpl)(&buf, results, newSummary(results), nil))
+	assert.Equal(t, `<h1>1 of 2 failed</h1>
+<p class="failed">/bad: &lt;not fips&gt;</p>
+DONE`, buf.String())
+}
+
+func TestLoadHTMLTemplateError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bad.tmpl")
+	require.NoError(t, os.WriteFile(file, []byte("{{ .Results "), 0o644))
+	_, err := LoadHTMLTemplate(file)
+	assert.Error(t, err)
+
+	_, err = LoadHTMLTemplate(filepath.Join(t.TempDir(), "missing.tmpl"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package scan
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// jsonReport is the top-level object of the JSON report
+// (--output-format json).
+type jsonReport struct {
+	// Metadata describes how the report was produced (see --metadata).
+	Metadata *types.ReportMetadata `json:"metadata,omitempty"`
+	// Results is a flat list of all scan results. It is never null.
+	Results []jsonResult `json:"results"`
+	// Summary is a number of results by status, including those
+	// not shown due to --only-failures or --only-warnings.
+	Summary *summary `json:"summary,omitempty"`
+	// Images is a list of images scanned, with the scan timing.
+	Images []jsonImage `json:"images,omitempty"`
+	// Duplicates are binaries found in more than one image, only
+	// set with --report-duplicates (to an empty list if none found).
+	Duplicates *[]duplicate `json:"duplicates,omitempty"`
+}
+
+// jsonImage is a JSON representation of an image scan timing.
+type jsonImage struct {
+	Tag      string    `json:"tag,omitempty"`
+	Image    string    `json:"image"`
+	Arch     string    `json:"arch,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration_seconds"`
+}
+
+// jsonResult is a JSON representation of a single scan result.
+// The field names are part of the JSON report schema, do not change them.
+type jsonResult struct {
+	Component string `json:"component,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Image     string `json:"image,omitempty"`
+	RPM       string `json:"rpm,omitempty"`
+	Path      string `json:"path,omitempty"`
+	// DuplicatePaths are the other paths of the same binary (see --dedupe).
+	DuplicatePaths []string `json:"duplicate_paths,omitempty"`
+	// Status is one of "success", "failed", or "warning".
+	Status string `json:"status"`
+	// Error is the error message, only set for failed and warning results.
+	Error string `json:"error,omitempty"`
+	// ErrorName is the name of a well-known error (such as
+	// "ErrNotDynLinked"), which can be used in config exceptions.
+	ErrorName string `json:"error_name,omitempty"`
+	Success   bool   `json:"success"`
+	Skip      bool   `json:"skip"`
+	// SkipReason tells why the file was skipped (such as "core dump"),
+	// if it is not evident.
+	SkipReason string `json:"skip_reason,omitempty"`
+	// SymlinkTarget is the path a skipped symlink resolves to.
+	SymlinkTarget string `json:"symlink_target,omitempty"`
+	// Kind is the binary kind ("go", "exe", or "pyext").
+	Kind string `json:"kind,omitempty"`
+	// SHA256 is a hex-encoded digest of the scanned binary.
+	SHA256 string `json:"sha256,omitempty"`
+	// Exceptions are the config rules which suppressed the errors
+	// found in the binary.
+	Exceptions []string `json:"exceptions,omitempty"`
+	// GoBuildInfo is only set for go binaries.
+	GoBuildInfo *types.GoBuildInfo `json:"go_build_info,omitempty"`
+	// Arch is the image architecture, only set for --all-arches scans.
+	Arch string `json:"arch,omitempty"`
+	// Severity is the severity of the failed check ("info", "low",
+	// "medium", or "high"), only set for failed and warning results.
+	Severity types.Severity `json:"severity,omitempty"`
+}
+
+func newJSONResult(res *types.ScanResult) jsonResult {
+	jr := jsonResult{
+		Component:      getComponent(res),
+		Tag:            getTag(res),
+		Image:          getImage(res),
+		RPM:            res.RPM,
+		Path:           res.Path,
+		DuplicatePaths: res.DuplicatePaths,
+		Status:         res.Status(),
+		Success:        res.IsSuccess(),
+		Skip:           res.Skip,
+		SkipReason:     res.SkipReason,
+		SymlinkTarget:  res.SymlinkTarget,
+		Kind:           res.Kind,
+		SHA256:         res.SHA256,
+		Exceptions:     res.Exceptions,
+		GoBuildInfo:    res.GoBuildInfo,
+		Arch:           res.Arch,
+		Severity:       res.Severity,
+	}
+	if res.Error != nil && res.Error.Error != nil {
+		jr.Error = res.Error.Error.Error()
+		jr.ErrorName = types.KnownErrorName(res.Error.Error)
+	}
+	return jr
+}
+
+func newJSONReport(results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) *jsonReport {
+	report := &jsonReport{Metadata: meta, Results: []jsonResult{}, Summary: sum}
+	for _, result := range results {
+		if result.Tag != nil && result.Tag.From != nil && result.Duration() > 0 {
+			report.Images = append(report.Images, jsonImage{
+				Tag:      result.Tag.Name,
+				Image:    result.Tag.From.Name,
+				Arch:     result.Arch,
+				Start:    result.Start,
+				End:      result.End,
+				Duration: result.Duration().Seconds(),
+			})
+		}
+		for _, res := range result.Items {
+			report.Results = append(report.Results, newJSONResult(res))
+		}
+	}
+	return report
+}
+
+func writeJSON(w io.Writer, results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONReport(results, sum, meta))
+}
@@ -0,0 +1,171 @@
+package scan
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// JUnit XML, as understood by Jenkins, GitLab, and other CI systems. There
+// is no formal schema; this follows the de facto one, see
+// https://github.com/testmoapp/junitxml.
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	// SystemOut is used for warnings, which JUnit has no notion of.
+	SystemOut string `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// junitSuiteName returns the test suite name for the image scan
+// results: the payload tag name, the image, or "results" (for scans
+// other than payload and image ones).
+func junitSuiteName(result *types.ScanResults) string {
+	name := "results"
+	if result.Tag != nil && result.Tag.Name != "" {
+		name = result.Tag.Name
+	} else if result.Tag != nil && result.Tag.From != nil && result.Tag.From.Name != "" {
+		name = result.Tag.From.Name
+	}
+	if result.Arch != "" {
+		name += " [" + result.Arch + "]"
+	}
+	return name
+}
+
+func newJUnitTestCase(res *types.ScanResult, suite string) junitTestCase {
+	tc := junitTestCase{Name: res.Path, ClassName: suite}
+	if component := getComponent(res); component != "" {
+		tc.ClassName = component + "." + suite
+	}
+	if tc.Name == "" {
+		// Operational error, such as failed pull.
+		tc.Name = getImage(res)
+	}
+	if res.RPM != "" {
+		tc.Name = res.RPM + ":" + tc.Name
+	}
+	switch {
+	case res.Skip:
+		tc.Skipped = &junitSkipped{Message: skipNote(res, "; ")}
+	case res.IsLevel(types.Warning):
+		tc.SystemOut = "warning: " + res.Error.GetError().Error()
+	case !res.IsSuccess():
+		err := res.Error.GetError()
+		tc.Failure = &junitFailure{
+			Message: err.Error(),
+			Type:    types.KnownErrorName(err),
+			Text:    resultPaths(res, ", ") + ": " + err.Error(),
+		}
+	}
+	return tc
+}
+
+// junitProperties returns the test suite properties: the image, its
+// architecture, the reason the scan is incomplete (if it is), and the
+// report metadata (see --metadata).
+func junitProperties(result *types.ScanResults, sum *summary, meta *types.ReportMetadata) []junitProperty {
+	var props []junitProperty
+	if result.Tag != nil && result.Tag.From != nil && result.Tag.From.Name != "" {
+		props = append(props, junitProperty{Name: "image", Value: result.Tag.From.Name})
+	}
+	if result.Arch != "" {
+		props = append(props, junitProperty{Name: "arch", Value: result.Arch})
+	}
+	if sum != nil && sum.Incomplete != "" {
+		props = append(props, junitProperty{Name: "incomplete", Value: sum.Incomplete})
+	}
+	if meta != nil {
+		for _, f := range metadataFields(meta) {
+			props = append(props, junitProperty{Name: "check-payload:" + f[0], Value: f[1]})
+		}
+	}
+	return props
+}
+
+func newJUnitTestSuites(results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) *junitTestSuites {
+	suites := &junitTestSuites{Name: "check-payload", Suites: []junitTestSuite{}}
+	if sum != nil {
+		suites.Time = sum.Duration
+	}
+	for _, result := range results {
+		suite := junitTestSuite{
+			Name:  junitSuiteName(result),
+			Time:  result.Duration().Seconds(),
+			Cases: []junitTestCase{},
+		}
+		if !result.Start.IsZero() {
+			suite.Timestamp = result.Start.UTC().Format(time.RFC3339)
+		}
+		suite.Properties = junitProperties(result, sum, meta)
+		for _, res := range result.Items {
+			tc := newJUnitTestCase(res, suite.Name)
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+	return suites
+}
+
+func writeJUnit(w io.Writer, results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(newJUnitTestSuites(results, sum, meta)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	tag := &v1.TagReference{Name: "foo", From: &corev1.ObjectReference{Name: "quay.io/foo@sha256:1234"}}
+	image := types.NewScanResults()
+	image.Tag = tag
+	image.
+		Append(types.NewScanResult().SetTag(tag).SetPath("/ok")).
+		Append(types.NewScanResult().SetTag(tag).SetPath("/bad").SetError(fmt.Errorf("%w: x", types.ErrNotDynLinked))).
+		Append(types.NewScanResult().SetTag(tag).SetPath("/warn").SetValidationError(types.NewValidationError(types.ErrLibcryptoMissing).SetWarning())).
+		Append(types.NewScanResult().SetTag(tag).SetPath("/skip").Skipped().AddException("[rpm.foo] filter_files")).
+		SetArch("arm64")
+	node := types.NewScanResults().Append(types.NewScanResult().SetRPM("bar").SetPath("/usr/bin/bar"))
+	results := []*types.ScanResults{image, node}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJUnit(&buf, results, newSummary(results), nil))
+
+	var got junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, 5, got.Tests)
+	assert.Equal(t, 1, got.Failures)
+	assert.Equal(t, 1, got.Skipped)
+	require.Len(t, got.Suites, 2)
+
+	suite := got.Suites[0]
+	assert.Equal(t, "foo [arm64]", suite.Name)
+	assert.Equal(t, []junitProperty{{"image", "quay.io/foo@sha256:1234"}, {"arch", "arm64"}}, suite.Properties)
+	require.Len(t, suite.Cases, 4)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Equal(t, &junitFailure{
+		Message: "executable is not dynamically linked: x",
+		Type:    "ErrNotDynLinked",
+		Text:    "/bad: executable is not dynamically linked: x",
+	}, suite.Cases[1].Failure)
+	assert.Nil(t, suite.Cases[2].Failure)
+	assert.Contains(t, suite.Cases[2].SystemOut, "warning: ")
+	assert.Equal(t, &junitSkipped{Message: "[rpm.foo] filter_files"}, suite.Cases[3].Skipped)
+
+	suite = got.Suites[1]
+	assert.Equal(t, "results", suite.Name)
+	assert.Equal(t, []junitTestCase{{Name: "bar:/usr/bin/bar", ClassName: "results"}}, suite.Cases)
+}
@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// metadataFields returns the report metadata as key-value pairs,
+// in the display order.
+func metadataFields(m *types.ReportMetadata) [][2]string {
+	fields := [][2]string{
+		{"version", m.Version},
+		{"config_file", m.ConfigFile},
+	}
+	if m.ConfigForVersion != "" {
+		fields = append(fields, [2]string{"config_for_version", m.ConfigForVersion})
+	}
+	fields = append(fields, [2]string{"command", m.Command})
+	if len(m.Flags) > 0 {
+		fields = append(fields, [2]string{"flags", strings.Join(m.Flags, " ")})
+	}
+	if m.Target != "" {
+		fields = append(fields, [2]string{"target", m.Target})
+	}
+	return append(fields, [2]string{"time", m.Time.UTC().Format(time.RFC3339)})
+}
+
+// commandLine returns the command line recorded in the metadata.
+func commandLine(m *types.ReportMetadata) string {
+	return strings.Join(append([]string{m.Command}, m.Flags...), " ")
+}
+
+// renderMetadata renders the report metadata header for a text report in
+// a given format: a comment block for csv and markdown, <meta> elements
+// for html, and a table otherwise. It returns an empty string if m is nil.
+func renderMetadata(m *types.ReportMetadata, format string) string {
+	if m == nil {
+		return ""
+	}
+	var out strings.Builder
+	switch format {
+	case "csv":
+		for _, f := range metadataFields(m) {
+			fmt.Fprintf(&out, "# %s: %s\n", f[0], oneLine(f[1]))
+		}
+	case "markdown":
+		out.WriteString("<!--\n")
+		for _, f := range metadataFields(m) {
+			// Make sure the comment is not terminated early.
+			fmt.Fprintf(&out, "%s: %s\n", f[0], strings.ReplaceAll(oneLine(f[1]), "-->", "-- >"))
+		}
+		out.WriteString("-->\n")
+	case "html":
+		for _, f := range metadataFields(m) {
+			fmt.Fprintf(&out, "<meta name=\"check-payload:%s\" content=\"%s\">\n", f[0], html.EscapeString(f[1]))
+		}
+	default:
+		tw := table.NewWriter()
+		for _, f := range metadataFields(m) {
+			tw.AppendRow(table.Row{f[0], f[1]})
+		}
+		fmt.Fprintln(&out, "---- Metadata")
+		fmt.Fprintln(&out, tw.Render())
+	}
+	return out.String()
+}
+
+// oneLine replaces newlines in s with spaces.
+func oneLine(s string) string {
+	return strings.ReplaceAll(s, "\n", " ")
+}
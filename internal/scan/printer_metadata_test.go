@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestRenderMetadata(t *testing.T) {
+	meta := &types.ReportMetadata{
+		Version:    "abc123",
+		ConfigFile: "embedded",
+		Command:    "check-payload scan payload",
+		Flags:      []string{"--url=quay.io/release:4.14", "--components=a&b"},
+		Target:     "quay.io/release:4.14",
+		Time:       time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	assert.Empty(t, renderMetadata(nil, "csv"))
+	assert.Equal(t, `# version: abc123
+# config_file: embedded
+# command: check-payload scan payload
+# flags: --url=quay.io/release:4.14 --components=a&b
+# target: quay.io/release:4.14
+# time: 2023-09-01T12:00:00Z
+`, renderMetadata(meta, "csv"))
+
+	md := renderMetadata(meta, "markdown")
+	assert.Contains(t, md, "<!--\nversion: abc123\n")
+	assert.Contains(t, md, "\n-->\n")
+
+	html := renderMetadata(meta, "html")
+	assert.Contains(t, html, `<meta name="check-payload:version" content="abc123">`)
+	assert.Contains(t, html, `<meta name="check-payload:flags" content="--url=quay.io/release:4.14 --components=a&amp;b">`)
+
+	table := renderMetadata(meta, "table")
+	assert.Contains(t, table, "---- Metadata\n")
+	assert.Contains(t, table, "config_file")
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, nil, &summary{}, meta))
+	var report jsonReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, meta, report.Metadata)
+
+	log := newSarifLog(nil, nil, meta)
+	assert.Equal(t, "abc123", log.Runs[0].Tool.Driver.Version)
+	require.Len(t, log.Runs[0].Invocations, 1)
+	assert.Equal(t, "check-payload scan payload --url=quay.io/release:4.14 --components=a&b", log.Runs[0].Invocations[0].CommandLine)
+	assert.True(t, log.Runs[0].Invocations[0].ExecutionSuccessful)
+}
@@ -0,0 +1,225 @@
+package scan
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// Minimal subset of SARIF 2.1.0 schema, see
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations,omitempty"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifInvocation struct {
+	CommandLine                string              `json:"commandLine,omitempty"`
+	StartTimeUTC               *time.Time          `json:"startTimeUtc,omitempty"`
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+	Properties                 map[string]string   `json:"properties,omitempty"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRuleID derives a SARIF rule ID from the error, using the well-known
+// error name, for example, ErrNotDynLinked becomes "not-dyn-linked".
+func sarifRuleID(err error) string {
+	name := strings.TrimPrefix(types.KnownErrorName(err), "Err")
+	if name == "" {
+		return "other"
+	}
+	var id strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				id.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		id.WriteRune(r)
+	}
+	return id.String()
+}
+
+// sarifLevel maps the result status onto a SARIF level.
+func sarifLevel(res *types.ScanResult) string {
+	if res.IsLevel(types.Warning) {
+		return "warning"
+	}
+	return "error"
+}
+
+// sarifLocations returns the locations of the result: its path, and the
+// other paths of the same binary (see --dedupe).
+func sarifLocations(res *types.ScanResult) []sarifLocation {
+	locations := make([]sarifLocation, 0, 1+len(res.DuplicatePaths))
+	for _, path := range append([]string{res.Path}, res.DuplicatePaths...) {
+		locations = append(locations, sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{
+					// SARIF URIs are relative to the scanned root.
+					URI: strings.TrimPrefix(path, "/"),
+				},
+			},
+		})
+	}
+	return locations
+}
+
+func sarifProperties(res *types.ScanResult) map[string]string {
+	props := make(map[string]string)
+	for k, v := range map[string]string{
+		"component": getComponent(res),
+		"tag":       getTag(res),
+		"image":     getImage(res),
+		"arch":      res.Arch,
+		"rpm":       res.RPM,
+		"status":    res.Status(),
+	} {
+		if v != "" {
+			props[k] = v
+		}
+	}
+	return props
+}
+
+func newSarifLog(results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "check-payload",
+			InformationURI: "https://github.com/openshift/check-payload",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	if meta != nil {
+		run.Tool.Driver.Version = meta.Version
+		props := make(map[string]string)
+		for _, f := range metadataFields(meta) {
+			props[f[0]] = f[1]
+		}
+		start := meta.Time.UTC()
+		run.Invocations = []sarifInvocation{{
+			CommandLine:         commandLine(meta),
+			StartTimeUTC:        &start,
+			ExecutionSuccessful: !IsOperationalFailure(results),
+			Properties:          props,
+		}}
+	}
+	if sum != nil && sum.Incomplete != "" {
+		// The scan was stopped early, so it was not successful.
+		if run.Invocations == nil {
+			run.Invocations = []sarifInvocation{{}}
+		}
+		inv := &run.Invocations[0]
+		inv.ExecutionSuccessful = false
+		inv.ToolExecutionNotifications = append(inv.ToolExecutionNotifications, sarifNotification{
+			Level:   "error",
+			Message: sarifMessage{Text: "the scan is incomplete: " + sum.Incomplete},
+		})
+	}
+	rules := make(map[string]bool)
+
+	for _, result := range results {
+		for _, res := range result.Items {
+			// Only report failures and warnings.
+			if res.Skip || res.IsSuccess() {
+				continue
+			}
+			err := res.Error.GetError()
+			id := sarifRuleID(err)
+			if !rules[id] {
+				rules[id] = true
+				desc := err.Error()
+				if known, ok := types.KnownErrors[types.KnownErrorName(err)]; ok {
+					desc = known.Error()
+				}
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:               id,
+					ShortDescription: sarifMessage{Text: desc},
+				})
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:     id,
+				Level:      sarifLevel(res),
+				Message:    sarifMessage{Text: err.Error()},
+				Locations:  sarifLocations(res),
+				Properties: sarifProperties(res),
+			})
+		}
+	}
+
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}
+}
+
+func writeSarif(w io.Writer, results []*types.ScanResults, sum *summary, meta *types.ReportMetadata) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newSarifLog(results, sum, meta))
+}
@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestReportStatusLevel(t *testing.T) {
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/fail").SetError(errors.New("bad"))).
+			Append(types.NewScanResult().SetPath("/warn").SetValidationError(types.NewValidationError(errors.New("meh")).SetWarning())),
+	}
+
+	failures, warnings, _ := generateReport(results, &types.Config{OutputFormat: "csv"})
+	assert.Contains(t, failures, "Level")
+	assert.Contains(t, failures, "/fail,failed,bad")
+	assert.Contains(t, warnings, "/warn,warning,meh")
+
+	failures, warnings, _ = generateReport(results, &types.Config{OutputFormat: "table"})
+	assert.Contains(t, failures, "✗ failed")
+	assert.Contains(t, warnings, "⚠ warning")
+	assert.NotContains(t, failures+warnings, "\x1b[", "no colors without --color")
+
+	failures, _, _ = generateReport(results, &types.Config{OutputFormat: "table", Color: true})
+	assert.Contains(t, failures, "\x1b[31m ✗ failed")
+
+	results[0].Append(types.NewScanResult().SetPath("/ok").Success())
+	_, _, successes := generateReport(results, &types.Config{OutputFormat: "table", Color: true})
+	assert.Contains(t, successes, "\x1b[32m /ok")
+
+	failures, warnings, _ = generateReport(results, &types.Config{OutputFormat: "html"})
+	assert.Contains(t, failures, `<table class="go-pretty-table check-payload-failed">`)
+	assert.Contains(t, failures, `<td class="fg-red">failed</td>`)
+	assert.Contains(t, warnings, `<table class="go-pretty-table check-payload-warning">`)
+	assert.Contains(t, warnings, `<td class="fg-yellow">warning</td>`)
+	assert.False(t, strings.Contains(warnings, "fg-red"))
+}
+
+func TestPrintReportColorFile(t *testing.T) {
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/fail").SetError(errors.New("bad"))),
+	}
+	cfg := &types.Config{OutputFormat: "table", Color: true, OutputFile: filepath.Join(t.TempDir(), "report.txt")}
+	printReport(cfg, results, results, newSummary(results))
+
+	data, err := os.ReadFile(cfg.OutputFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "✗ failed")
+	assert.NotContains(t, string(data), "\x1b[", "no colors in the file")
+}
+
+func TestReportArchColumn(t *testing.T) {
+	failed := func(arch string) *types.ScanResults {
+		return types.NewScanResults().Append(types.NewScanResult().SetPath("/fail").SetError(errors.New("boom"))).SetArch(arch)
+	}
+	cases := []struct {
+		name    string
+		cfg     *types.Config
+		results []*types.ScanResults
+		want    bool
+	}{
+		{name: "no arch", cfg: &types.Config{}, results: []*types.ScanResults{failed("")}},
+		{name: "single arch", cfg: &types.Config{}, results: []*types.ScanResults{failed("amd64"), failed("amd64")}},
+		{name: "all arches", cfg: &types.Config{AllArches: true}, results: []*types.ScanResults{failed("amd64")}, want: true},
+		{name: "many arches", cfg: &types.Config{}, results: []*types.ScanResults{failed("amd64"), failed("arm64")}, want: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.OutputFormat = "csv"
+			assert.Equal(t, tc.want, isArchShown(tc.cfg, tc.results))
+			failures, _, _ := renderReport(tc.cfg, tc.results)
+			assert.Equal(t, tc.want, strings.Contains(failures.RenderCSV(), colTitleArch))
+		})
+	}
+}
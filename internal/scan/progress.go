@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// binariesScanned is a number of binaries scanned so far.
+// Used for progress reporting only.
+var binariesScanned atomic.Int64
+
+// progress periodically logs the scan progress.
+type progress struct {
+	what  string       // What is counted, e.g. "images" or "rpms".
+	total atomic.Int64 // Total number of items, or 0 if unknown.
+	done  atomic.Int64
+	start time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startProgress starts periodic progress reporting, if enabled.
+// The returned progress is never nil, so it is safe to call its methods
+// even if reporting is disabled.
+func startProgress(cfg *types.Config, what string) *progress {
+	p := &progress{
+		what:  what,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+	}
+	binariesScanned.Store(0)
+
+	interval := cfg.ProgressInterval
+	if interval <= 0 || isDocumentToStdout(cfg) {
+		return p
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.log()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// isDocumentToStdout tells if a machine-readable report is to be printed
+// to stdout, in which case any extra output is undesirable.
+func isDocumentToStdout(cfg *types.Config) bool {
+	switch cfg.OutputFormat {
+	case "json", "sarif", "junit":
+		return cfg.OutputFile == ""
+	}
+	return false
+}
+
+func (p *progress) log() {
+	var kv []interface{}
+	if p.what != "" {
+		done := strconv.FormatInt(p.done.Load(), 10)
+		if total := p.total.Load(); total > 0 {
+			done += "/" + strconv.FormatInt(total, 10)
+		}
+		kv = append(kv, p.what, done)
+	}
+	kv = append(kv,
+		"binaries", binariesScanned.Load(),
+		"elapsed", time.Since(p.start).Round(time.Second).String())
+	klog.InfoS("scan progress", kv...)
+}
+
+// Done marks one item as done. It is a no-op for nil p.
+func (p *progress) Done() {
+	if p != nil {
+		p.done.Add(1)
+	}
+}
+
+// SetTotal sets the total number of items. It is a no-op for nil p.
+func (p *progress) SetTotal(total int) {
+	if p != nil {
+		p.total.Store(int64(total))
+	}
+}
+
+// Stop stops progress reporting.
+func (p *progress) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
@@ -0,0 +1,231 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/openshift/api/image/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// checkpoint is a single line of the resume state file.
+type checkpoint struct {
+	Image   string       `json:"image"`
+	Results []jsonResult `json:"results"`
+}
+
+// resumeState keeps track of images already scanned, and saves the
+// scan results to a state file (in newline-delimited JSON format)
+// after every image scanned.
+type resumeState struct {
+	file  string
+	saved map[string][]*types.ScanResults // Per-image results from the state file.
+}
+
+// loadResumeState reads the state file, if it exists.
+func loadResumeState(file string) (*resumeState, error) {
+	s := &resumeState{
+		file:  file,
+		saved: make(map[string][]*types.ScanResults),
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	var valid []byte // The well-formed lines.
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var cp checkpoint
+		if err := json.Unmarshal(line, &cp); err != nil {
+			klog.Warningf("resume: skipping malformed line in %s: %v", file, err)
+			continue
+		}
+		s.saved[cp.Image] = splitByArch(cp.Image, cp.Results)
+		valid = append(valid, line...)
+		valid = append(valid, '\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("resume: can't read %s: %w", file, err)
+	}
+	if !bytes.Equal(data, valid) {
+		// Drop the malformed lines (such as the last one, half-written
+		// when the run was killed), as new lines are appended to the file.
+		if err := writeFileAtomic(file, valid); err != nil {
+			return nil, err
+		}
+	}
+	klog.Infof("resume: %d images already scanned according to %s", len(s.saved), file)
+
+	return s, nil
+}
+
+// splitByArch converts the saved results of the image back to the scan
+// results, one per architecture (see --all-arches), in order of appearance.
+func splitByArch(image string, saved []jsonResult) []*types.ScanResults {
+	tag := &v1.TagReference{From: &corev1.ObjectReference{Name: image}}
+	for i := range saved {
+		if saved[i].Tag != "" {
+			tag.Name = saved[i].Tag
+			break
+		}
+	}
+	var runs []*types.ScanResults
+	byArch := make(map[string]*types.ScanResults)
+	for i := range saved {
+		arch := saved[i].Arch
+		results, ok := byArch[arch]
+		if !ok {
+			results = types.NewScanResults().SetTag(tag)
+			results.Arch = arch
+			byArch[arch] = results
+			runs = append(runs, results)
+		}
+		results.Append(saved[i].scanResult())
+	}
+	if runs == nil {
+		runs = []*types.ScanResults{types.NewScanResults().SetTag(tag)}
+	}
+	return runs
+}
+
+// Saved returns the results for the image (one per architecture
+// scanned), if it was scanned before. It is safe to call for nil s.
+func (s *resumeState) Saved(image string) ([]*types.ScanResults, bool) {
+	if s == nil {
+		return nil, false
+	}
+	res, ok := s.saved[image]
+	return res, ok
+}
+
+// Save appends the image scan results (one per architecture scanned)
+// to the state file. The results of an image not scanned completely
+// (see isCompleteScan) are not saved, so that it is scanned again.
+func (s *resumeState) Save(image string, runs []*types.ScanResults) error {
+	if !isCompleteScan(runs) {
+		klog.V(1).InfoS("resume: not saving incomplete image scan", "image", image)
+		return nil
+	}
+	cp := checkpoint{Image: image, Results: []jsonResult{}}
+	for _, results := range runs {
+		for _, res := range results.Items {
+			cp.Results = append(cp.Results, newJSONResult(res))
+		}
+	}
+	line, err := json.Marshal(&cp)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(s.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isCompleteScan tells if the image was scanned completely, i.e. the
+// scan was not canceled or timed out, and there were no operational
+// errors (such as a failed pull), which might be transient.
+func isCompleteScan(runs []*types.ScanResults) bool {
+	for _, results := range runs {
+		for _, res := range results.Items {
+			if res.Error == nil {
+				continue
+			}
+			var opErr *OperationalError
+			if errors.Is(res.Error.Error, context.Canceled) || errors.Is(res.Error.Error, context.DeadlineExceeded) || errors.As(res.Error.Error, &opErr) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// writeFileAtomic writes data to a temporary file, then renames it to file.
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// savedError is an error restored from a saved report.
+type savedError struct {
+	msg string
+	err error // Well-known error, or nil.
+}
+
+func (e *savedError) Error() string {
+	return e.msg
+}
+
+func (e *savedError) Unwrap() error {
+	return e.err
+}
+
+// scanResult converts jr back to the scan result.
+func (jr *jsonResult) scanResult() *types.ScanResult {
+	res := types.NewScanResult().SetPath(jr.Path).SetRPM(jr.RPM).SetKind(jr.Kind).SetSHA256(jr.SHA256).SetGoBuildInfo(jr.GoBuildInfo).SetArch(jr.Arch)
+	if jr.Tag != "" || jr.Image != "" {
+		res.SetTag(&v1.TagReference{
+			Name: jr.Tag,
+			From: &corev1.ObjectReference{Name: jr.Image},
+		})
+	}
+	if jr.Component != "" {
+		res.SetComponent(&types.OpenshiftComponent{Component: jr.Component})
+	}
+	if jr.Skip {
+		res.SkippedBecause(jr.SkipReason).SetSymlinkTarget(jr.SymlinkTarget)
+	}
+	for _, rule := range jr.Exceptions {
+		res.AddException(rule)
+	}
+	if jr.Status == "failed" || jr.Status == "warning" {
+		res.SetError(&savedError{msg: jr.Error, err: types.KnownErrors[jr.ErrorName]})
+		if jr.Status == "warning" {
+			res.Error.SetWarning()
+		}
+		res.SetSeverity(jr.Severity)
+	}
+	return res
+}
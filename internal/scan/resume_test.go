@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestResumeStateSave(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state.ndjson")
+	s, err := loadResumeState(file)
+	require.NoError(t, err)
+
+	done := types.NewScanResults().Append(types.NewScanResult().SetPath("/bin/ok").Success())
+	canceled := types.NewScanResults().
+		Append(types.NewScanResult().SetPath("/bin/ok").Success()).
+		Append(types.NewScanResult().SetPath("/bin/cut").SetError(fmt.Errorf("scan: %w", context.Canceled)))
+	pullFailed := types.NewScanResults().Append(types.NewScanResult().SetError(&OperationalError{fmt.Errorf("pull failed")}))
+	require.NoError(t, s.Save("done", []*types.ScanResults{done}))
+	require.NoError(t, s.Save("canceled", []*types.ScanResults{canceled}))
+	require.NoError(t, s.Save("pull-failed", []*types.ScanResults{pullFailed}))
+
+	// Simulate a line half-written when the run was killed.
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"image": "killed", "resu`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err = loadResumeState(file)
+	require.NoError(t, err)
+	_, ok := s.Saved("done")
+	assert.True(t, ok)
+	for _, image := range []string{"canceled", "pull-failed", "killed"} {
+		_, ok := s.Saved(image)
+		assert.False(t, ok, image)
+	}
+
+	// New lines are appended after the dropped half-written one.
+	require.NoError(t, s.Save("next", []*types.ScanResults{done}))
+	s, err = loadResumeState(file)
+	require.NoError(t, err)
+	assert.Len(t, s.saved, 2)
+}
+
+func TestResumeStateTag(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state.ndjson")
+	s, err := loadResumeState(file)
+	require.NoError(t, err)
+
+	image := "quay.io/foo@sha256:1"
+	tag := &v1.TagReference{Name: "foo", From: &corev1.ObjectReference{Name: image}}
+	runs := []*types.ScanResults{
+		types.NewScanResults().SetTag(tag).SetArch("amd64").
+			Append(types.NewScanResult().SetPath("/bin/ok").SetTag(tag).SetArch("amd64").Success()),
+		types.NewScanResults().SetTag(tag).SetArch("arm64").
+			Append(types.NewScanResult().SetPath("/bin/ok").SetTag(tag).SetArch("arm64").Success()),
+	}
+	require.NoError(t, s.Save(image, runs))
+	require.NoError(t, s.Save("empty", []*types.ScanResults{types.NewScanResults()}))
+
+	// The restored results are written to the same --output-dir files.
+	s, err = loadResumeState(file)
+	require.NoError(t, err)
+	saved, ok := s.Saved(image)
+	require.True(t, ok)
+	require.Len(t, saved, 2)
+	for i, results := range saved {
+		require.NotNil(t, results.Tag)
+		assert.Equal(t, "foo", results.Tag.Name)
+		assert.Equal(t, image, results.Tag.From.Name)
+		assert.Equal(t, reportFileName(runs[i], ".json"), reportFileName(results, ".json"))
+	}
+	saved, ok = s.Saved("empty")
+	require.True(t, ok)
+	require.Len(t, saved, 1)
+	assert.Equal(t, "empty.json", reportFileName(saved[0], ".json"))
+}
@@ -0,0 +1,95 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/podman"
+)
+
+const (
+	pullRetryBaseDelay = 2 * time.Second
+	pullRetryMaxDelay  = 1 * time.Minute
+)
+
+var (
+	// Substrings of podman pull errors which mean retrying is pointless.
+	permanentPullErrors = []string{
+		"unauthorized",
+		"authentication required",
+		"denied",
+		"manifest unknown",
+		"name unknown",
+		"not found",
+		"invalid reference",
+	}
+	// Substrings of podman pull errors which are likely to go away on retry.
+	transientPullErrors = []string{
+		"timeout",
+		"timed out",
+		"connection reset",
+		"connection refused",
+		"broken pipe",
+		"unexpected eof",
+		"no such host",
+		"temporary failure",
+		"too many requests",
+		"500 internal server error",
+		"502 bad gateway",
+		"503 service unavailable",
+		"504 gateway timeout",
+	}
+)
+
+// isTransientPullError tells if the pull error is likely transient
+// (network, timeout, or a server error).
+func isTransientPullError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range permanentPullErrors {
+		if strings.Contains(msg, s) {
+			return false
+		}
+	}
+	for _, s := range transientPullErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// pullRetryDelay returns the delay before the retry attempt (starting from 1),
+// which is exponential, with up to 50% of jitter added.
+func pullRetryDelay(attempt int) time.Duration {
+	delay := pullRetryBaseDelay << (attempt - 1)
+	if delay > pullRetryMaxDelay || delay <= 0 {
+		delay = pullRetryMaxDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/2)+1)) //nolint:gosec // No need for crypto/rand here.
+}
+
+// pullWithRetry pulls the image, retrying up to retries times
+// in case of transient errors.
+func pullWithRetry(ctx context.Context, image string, opts *podman.PullOptions, retries int) error {
+	for attempt := 0; ; attempt++ {
+		err := podman.Pull(ctx, image, opts)
+		if err == nil || ctx.Err() != nil || !isTransientPullError(err) {
+			return err
+		}
+		if attempt >= retries {
+			return fmt.Errorf("pull failed after %d retries: %w", retries, err)
+		}
+		delay := pullRetryDelay(attempt + 1)
+		klog.V(1).InfoS("retrying pull", "image", image, "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
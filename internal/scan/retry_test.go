@@ -0,0 +1,39 @@
+package scan
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsTransientPullError(t *testing.T) {
+	cases := []struct {
+		msg       string
+		transient bool
+	}{
+		{"dial tcp 1.2.3.4:443: i/o timeout", true},
+		{"reading manifest: received unexpected HTTP status: 503 Service Unavailable", true},
+		{"read tcp: connection reset by peer", true},
+		{"unable to retrieve auth token: invalid username/password: unauthorized", false},
+		{"reading manifest sha256:123: manifest unknown", false},
+		{"repository quay.io/a/b not found: 404 Not Found", false},
+		{"some other error", false},
+	}
+	for _, tc := range cases {
+		if got := isTransientPullError(errors.New(tc.msg)); got != tc.transient {
+			t.Errorf("%q: want %v, got %v", tc.msg, tc.transient, got)
+		}
+	}
+}
+
+func TestPullRetryDelay(t *testing.T) {
+	for attempt := 1; attempt < 100; attempt++ {
+		lo := pullRetryBaseDelay << (attempt - 1)
+		if lo > pullRetryMaxDelay || lo <= 0 {
+			lo = pullRetryMaxDelay
+		}
+		hi := lo + lo/2
+		if d := pullRetryDelay(attempt); d < lo || d > hi {
+			t.Errorf("attempt %d: want delay in [%v, %v], got %v", attempt, lo, hi, d)
+		}
+	}
+}
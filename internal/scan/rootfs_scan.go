@@ -0,0 +1,60 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "github.com/openshift/api/image/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/tracing"
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// RunRootfsScan scans an image root filesystem already extracted to the
+// directory (by some other tool), the same way as a pulled image is
+// scanned (using a directory tree walk), but with no pull or extraction.
+// The directory is reported as the image.
+func RunRootfsScan(ctx context.Context, cfg *types.Config, dir string) (runs []*types.ScanResults) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "scan rootfs", tracing.String(tracing.AttrRoot, dir))
+	defer func() { endSpan(span, runs) }()
+	SetPhase(PhaseScan)
+
+	results := rootfsScan(ctx, cfg, dir)
+	return []*types.ScanResults{results.SetTime(start, time.Now())}
+}
+
+func rootfsScan(ctx context.Context, cfg *types.Config, dir string) *types.ScanResults {
+	// An absolute and clean path (with no trailing slash), so that the
+	// file paths found by the walk are correctly made relative to it.
+	root, err := filepath.Abs(dir)
+	if err == nil {
+		err = checkRootfs(root)
+	}
+	tag := &v1.TagReference{From: &corev1.ObjectReference{Name: root}}
+	if err != nil {
+		return imageError(cfg, tag, err).SetTag(tag)
+	}
+	klog.InfoS("scanning image rootfs", "root", root)
+
+	progress := startProgress(cfg, "")
+	defer progress.Stop()
+	return walkDirScan(ctx, cfg, tag, nil, root, nil, cfg.Parallelism).SetTag(tag)
+}
+
+// checkRootfs checks that the rootfs directory exists.
+func checkRootfs(root string) error {
+	fi, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: not a directory", root)
+	}
+	return nil
+}
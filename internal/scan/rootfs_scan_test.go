@@ -0,0 +1,44 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestRootfsScan(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/bin/true"), exe, 0o755))
+
+	cfg := &types.Config{Checks: []string{"dyn-linked"}}
+	// A trailing slash does not affect the paths.
+	results := rootfsScan(context.Background(), cfg, root+"/")
+	require.NotNil(t, results.Tag)
+	assert.Equal(t, root, results.Tag.From.Name)
+	var paths []string
+	for _, res := range results.Items {
+		assert.Equal(t, root, getImage(res))
+		if res.Path != "" { // Not the openssl info.
+			assert.True(t, res.IsSuccess(), res.Path)
+			paths = append(paths, res.Path)
+		}
+	}
+	assert.Equal(t, []string{"/usr/bin/true"}, paths)
+
+	for _, dir := range []string{filepath.Join(root, "nonexistent"), filepath.Join(root, "usr/bin/true")} {
+		results = rootfsScan(context.Background(), cfg, dir)
+		require.Len(t, results.Items, 1)
+		assert.True(t, IsOperationalFailure([]*types.ScanResults{results}), dir)
+	}
+}
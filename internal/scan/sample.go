@@ -0,0 +1,25 @@
+package scan
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// sampledOut is the number of files not scanned because of --sample-largest.
+var sampledOut atomic.Int64
+
+// sampleLargest returns up to n largest files (the largest first, or
+// by path, for files of the same size), and counts the files left out.
+func sampleLargest(files []walkFile, n int) []walkFile {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].size != files[j].size {
+			return files[i].size > files[j].size
+		}
+		return files[i].innerPath < files[j].innerPath
+	})
+	if len(files) > n {
+		sampledOut.Add(int64(len(files) - n))
+		files = files[:n]
+	}
+	return files
+}
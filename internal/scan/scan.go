@@ -1,17 +1,27 @@
 package scan
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/openshift/check-payload/internal/cache"
 	"github.com/openshift/check-payload/internal/podman"
+	"github.com/openshift/check-payload/internal/proc"
+	"github.com/openshift/check-payload/internal/tracing"
 	"github.com/openshift/check-payload/internal/types"
 	"github.com/openshift/check-payload/internal/validations"
 
@@ -27,8 +37,10 @@ type Request struct {
 }
 
 type Result struct {
-	Tag     *v1.TagReference
-	Results *types.ScanResults
+	Tag *v1.TagReference
+	// Results are the image scan results, one per architecture
+	// scanned (see --all-arches).
+	Results []*types.ScanResults
 }
 
 func ValidateApplicationDependencies(apps []string) error {
@@ -43,66 +55,186 @@ func ValidateApplicationDependencies(apps []string) error {
 	return multiErr
 }
 
-func RunOperatorScan(ctx context.Context, cfg *types.Config) []*types.ScanResults {
-	tag := &v1.TagReference{
-		From: &corev1.ObjectReference{
-			Name: cfg.ContainerImage,
-		},
+// RunOperatorScan pulls and scans the container images given by
+// cfg.ContainerImages (or cfg.ContainerImage), or the image archive
+// cfg.FromArchive. Errors are reported in the results.
+func RunOperatorScan(ctx context.Context, cfg *types.Config) (runs []*types.ScanResults) {
+	images := cfg.ContainerImages
+	if cfg.FromArchive != "" {
+		images = []string{cfg.FromArchive}
+	} else if len(images) == 0 {
+		images = []string{cfg.ContainerImage}
+	}
+	ctx, span := tracing.Start(ctx, "scan images", tracing.Int(tracing.AttrImages, len(images)))
+	defer func() { endSpan(span, runs) }()
+	SetPhase(PhaseScan)
+
+	cleanup, err := setupTempDir(cfg)
+	if err != nil {
+		return []*types.ScanResults{newResults(cfg, types.NewScanResult().SetError(&OperationalError{err}))}
+	}
+	defer cleanup()
+
+	pulls := newSemaphore(pullParallelism(cfg))
+	for _, image := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		tag := &v1.TagReference{
+			From: &corev1.ObjectReference{
+				Name: image,
+			},
+		}
+		runs = append(runs, validateTagArches(ctx, tag, cfg, pulls)...)
+	}
+	return runs
+}
+
+// pullParallelism returns the number of images to pull at once, which is
+// cfg.PullParallelism, if set, and cfg.Parallelism otherwise.
+func pullParallelism(cfg *types.Config) int {
+	if cfg.PullParallelism > 0 {
+		return cfg.PullParallelism
+	}
+	if cfg.Parallelism > 0 {
+		return cfg.Parallelism
+	}
+	return 1
+}
+
+// ReadList reads a newline-delimited list (such as of image pull specs).
+// Empty lines and lines starting with # are ignored.
+func ReadList(r io.Reader) ([]string, error) {
+	var list []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		list = append(list, line)
 	}
-	return []*types.ScanResults{validateTag(ctx, tag, cfg)}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
-func RunPayloadScan(ctx context.Context, cfg *types.Config) []*types.ScanResults {
+// ReadImageList reads a newline-delimited list of image pull specs
+// (see ReadList). The list must not be empty.
+func ReadImageList(r io.Reader) ([]string, error) {
+	images, err := ReadList(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, errors.New("image list is empty")
+	}
+	return images, nil
+}
+
+// RunPayloadScan pulls and scans the images of the release payload given
+// by cfg.FromURL, cfg.FromFile, or cfg.FromMapping. It only returns an
+// error if the payload can't be read; scan errors are reported in the
+// results.
+func RunPayloadScan(ctx context.Context, cfg *types.Config) (results []*types.ScanResults, err error) {
 	var runs []*types.ScanResults
 
-	payload, err := GetPayload(cfg)
+	ctx, span := tracing.Start(ctx, "scan payload", tracing.String(tracing.AttrPayload, payloadRef(cfg)))
+	defer func() {
+		span.SetError(err)
+		endSpan(span, results)
+	}()
+
+	SetPhase(PhasePayload)
+	getCtx, getSpan := tracing.Start(ctx, "get payload")
+	payload, err := GetPayload(getCtx, cfg)
+	getSpan.SetError(err)
+	getSpan.End()
+	if err != nil {
+		return nil, fmt.Errorf("could not get pods from payload: %w", err)
+	}
+	if err := checkComponentPatterns(cfg.Components, payload.References.Spec.Tags); err != nil {
+		return nil, err
+	}
+
+	SetPhase(PhaseScan)
+
+	var state *resumeState
+	if cfg.ResumeFile != "" {
+		state, err = loadResumeState(cfg.ResumeFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read resume state: %w", err)
+		}
+	}
+
+	cleanup, err := setupTempDir(cfg)
 	if err != nil {
-		klog.Fatalf("could not get pods from payload: %v", err)
+		return nil, err
 	}
+	defer cleanup()
 
 	parallelism := cfg.Parallelism
-	limit := cfg.Limit
+	if parallelism < 1 {
+		parallelism = 1
+	}
 
 	tx := make(chan *Request, parallelism)
 	rx := make(chan *Result, parallelism)
 	var wgThreads sync.WaitGroup
 	var wgRx sync.WaitGroup
 
-	wgThreads.Add(cfg.Parallelism)
+	pulls := newSemaphore(pullParallelism(cfg))
+	wgThreads.Add(parallelism)
 	for i := 0; i < parallelism; i++ {
 		go func() {
-			scan(ctx, cfg, tx, rx)
+			scan(ctx, cfg, pulls, tx, rx)
 			wgThreads.Done()
 		}()
 	}
 
+	progress := startProgress(cfg, "images")
+	defer progress.Stop()
+
 	wgRx.Add(1)
 	go func() {
 		for res := range rx {
-			runs = append(runs, res.Results)
+			runs = append(runs, res.Results...)
+			progress.Done()
+			// An image cut short by the time limit or an interrupt
+			// is to be scanned again on resume.
+			if state != nil && ctx.Err() == nil {
+				if err := state.Save(res.Tag.From.Name, res.Results); err != nil {
+					klog.Errorf("could not save resume state: %v", err)
+				}
+			}
 		}
 		wgRx.Done()
 	}()
 
-	contains := func(slice []string, item string) bool {
-		for _, elem := range slice {
-			if elem == item {
-				return true
+	var tags []*v1.TagReference
+	var resumed []*types.ScanResults // Results restored from the resume state.
+	for _, tag := range selectTags(cfg, payload.References.Spec.Tags) {
+		if saved, ok := state.Saved(tag.From.Name); ok {
+			klog.V(1).InfoS("resume: skipping already scanned image", "image", tag.From.Name)
+			for _, results := range saved {
+				for _, res := range results.Items {
+					onResult(cfg, res)
+				}
 			}
+			resumed = append(resumed, saved...)
+		} else {
+			tags = append(tags, tag)
 		}
-		return false
 	}
+	progress.SetTotal(len(tags))
 
-	for i, tag := range payload.References.Spec.Tags {
-		// scan only user specified components if provided
-		// on command line
-		if len(cfg.Components) > 0 && !contains(cfg.Components, tag.Name) {
-			continue
-		}
-		tag := tag
-		tx <- &Request{Tag: &tag}
-		if limit > 0 && i == limit-1 {
-			break
+tags:
+	for _, tag := range tags {
+		select {
+		case tx <- &Request{Tag: tag}:
+		case <-ctx.Done():
+			break tags
 		}
 	}
 
@@ -111,18 +243,119 @@ func RunPayloadScan(ctx context.Context, cfg *types.Config) []*types.ScanResults
 	close(rx)
 	wgRx.Wait()
 
-	return runs
+	return append(resumed, runs...), nil
+}
+
+// selectTags returns the payload tags to scan, i.e. those selected by
+// cfg.Components, up to cfg.Limit.
+func selectTags(cfg *types.Config, tags []v1.TagReference) []*v1.TagReference {
+	var selected []*v1.TagReference
+	for i, tag := range tags {
+		// scan only user specified components if provided
+		// on command line
+		if cfg.IsComponentSelected(tag.Name) {
+			tag := tag
+			selected = append(selected, &tag)
+		}
+		if cfg.Limit > 0 && i == cfg.Limit-1 {
+			break
+		}
+	}
+	return selected
 }
 
-func scan(ctx context.Context, cfg *types.Config, tx <-chan *Request, rx chan<- *Result) {
+// DumpImages prints the payload images which would be scanned (the tag
+// name and the image pull spec, and the mirror used, if any), and their
+// number, without pulling or scanning them.
+func DumpImages(ctx context.Context, cfg *types.Config) error {
+	payload, err := GetPayload(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("could not get pods from payload: %w", err)
+	}
+	if err := checkComponentPatterns(cfg.Components, payload.References.Spec.Tags); err != nil {
+		return err
+	}
+	n := 0
+	for _, tag := range selectTags(cfg, payload.References.Spec.Tags) {
+		image := tag.From.Name
+		if isImageFiltered(cfg, image) {
+			klog.V(1).InfoS("Ignoring image", "image", image)
+			continue
+		}
+		if mirror := cfg.MirrorImage(image); mirror != image {
+			image += " (mirror: " + mirror + ")"
+		}
+		fmt.Println(tag.Name, image)
+		n++
+	}
+	fmt.Printf("---- %d images to scan\n", n)
+	return nil
+}
+
+// checkComponentPatterns returns an error if any of the component patterns
+// (see --components) is malformed, or matches none of the payload tags
+// (which is likely a typo).
+func checkComponentPatterns(patterns []string, tags []v1.TagReference) error {
+	var unmatched []string
+	for _, pattern := range patterns {
+		found := false
+		for _, tag := range tags {
+			ok, err := path.Match(pattern, tag.Name)
+			if err != nil {
+				return fmt.Errorf("--components: bad pattern %q: %w", pattern, err)
+			}
+			if ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+	if len(unmatched) > 0 {
+		return fmt.Errorf("--components: no payload components match %s", strings.Join(unmatched, ", "))
+	}
+	return nil
+}
+
+func scan(ctx context.Context, cfg *types.Config, pulls semaphore, tx <-chan *Request, rx chan<- *Result) {
 	for req := range tx {
-		ValidateTag(ctx, cfg, req.Tag, rx)
+		ValidateTag(ctx, cfg, req.Tag, pulls, rx)
 	}
 }
 
-func ValidateTag(ctx context.Context, cfg *types.Config, tag *v1.TagReference, rx chan<- *Result) {
-	result := validateTag(ctx, tag, cfg)
-	rx <- &Result{Results: result}
+func ValidateTag(ctx context.Context, cfg *types.Config, tag *v1.TagReference, pulls semaphore, rx chan<- *Result) {
+	results := validateTagArches(ctx, tag, cfg, pulls)
+	rx <- &Result{Tag: tag, Results: results}
+}
+
+// OperationalError is an error caused by the scan environment (such as
+// an image pull failure or a missing rpm database), rather than by a failed
+// validation.
+type OperationalError struct {
+	Err error
+}
+
+func (e *OperationalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OperationalError) Unwrap() error {
+	return e.Err
+}
+
+// IsOperationalFailure tells if any of the results is an OperationalError.
+func IsOperationalFailure(results []*types.ScanResults) bool {
+	for _, result := range results {
+		for _, res := range result.Items {
+			var opErr *OperationalError
+			if res.Error != nil && errors.As(res.Error.Error, &opErr) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func IsFailed(results []*types.ScanResults) bool {
@@ -147,30 +380,87 @@ func IsWarnings(results []*types.ScanResults) bool {
 	return false
 }
 
-func GetPayload(config *types.Config) (*release.ReleaseInfo, error) {
+// IsFailedAtSeverity tells if there are failures with the severity of at
+// least threshold. Those without a severity (i.e. not caused by a check)
+// are considered to be of the highest severity. Warnings (including the
+// failures downgraded by a baseline) are not considered.
+func IsFailedAtSeverity(results []*types.ScanResults, threshold types.Severity) bool {
+	for _, result := range results {
+		for _, res := range result.Items {
+			if res.IsLevel(types.Error) && !IsBelowSeverity(res, threshold) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsBelowSeverity tells if res has a severity lower than threshold
+// (see IsFailedAtSeverity). Nothing is below the unknown threshold.
+func IsBelowSeverity(res *types.ScanResult, threshold types.Severity) bool {
+	return res.Severity != types.SeverityUnknown && res.Severity < threshold
+}
+
+// defaultReleaseRepo is the repository of OpenShift release images,
+// used for release images given by a bare digest.
+const defaultReleaseRepo = "quay.io/openshift-release-dev/ocp-release"
+
+// digestRe matches a bare image digest.
+var digestRe = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// ReleaseImage returns the release image pull spec for ref, which can be
+// a pull spec (optionally with a transport prefix, such as "docker://"),
+// or a bare digest of an image in defaultReleaseRepo.
+func ReleaseImage(ref string) string {
+	ref = trimTransport(ref)
+	if digestRe.MatchString(ref) {
+		return defaultReleaseRepo + "@" + ref
+	}
+	return ref
+}
+
+func GetPayload(ctx context.Context, config *types.Config) (*release.ReleaseInfo, error) {
 	var payload *release.ReleaseInfo
 	var err error
 	if config.FromURL != "" {
-		payload, err = DownloadReleaseInfo(config.FromURL, config.PullSecret)
+		url := config.MirrorImage(config.FromURL)
+		if url != config.FromURL {
+			klog.V(1).InfoS("using mirror", "image", config.FromURL, "mirror", url)
+		}
+		authFile, cleanup, authErr := registryAuthFile(ctx, config, url)
+		if authErr != nil {
+			return nil, authErr
+		}
+		defer cleanup()
+		payload, err = DownloadReleaseInfo(ctx, url, authFile, config.ProxyEnv())
+	} else if config.FromMapping != "" {
+		payload, err = ReadMappingFile(config.FromMapping)
 	} else {
 		payload, err = ReadReleaseInfo(config.FromFile)
 	}
 	return payload, err
 }
 
-func DownloadReleaseInfo(url string, pullSecret string) (*release.ReleaseInfo, error) {
+// DownloadReleaseInfo runs oc adm release info to get the payload
+// information. The env is a list of additional environment variables
+// (in "key=value" form) to set for oc. The oc process is killed if ctx
+// is done before it finishes.
+func DownloadReleaseInfo(ctx context.Context, url string, pullSecret string, env []string) (*release.ReleaseInfo, error) {
 	// oc adm release info  --output json --pullspecs
 	klog.InfoS("oc adm release info", "url", url)
 	var cmd *exec.Cmd
 	var stdout bytes.Buffer
 	if pullSecret != "" {
-		cmd = exec.CommandContext(context.Background(), "oc", "adm", "release", "-a", pullSecret, "info", "--output", "json", "--pullspecs", url)
+		cmd = exec.Command("oc", "adm", "release", "-a", pullSecret, "info", "--output", "json", "--pullspecs", url)
 	} else {
-		cmd = exec.CommandContext(context.Background(), "oc", "adm", "release", "info", "--output", "json", "--pullspecs", url)
+		cmd = exec.Command("oc", "adm", "release", "info", "--output", "json", "--pullspecs", url)
 	}
 
+	if len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
 	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+	if err := proc.Run(ctx, cmd); err != nil {
 		return nil, err
 	}
 	releaseInfo := &release.ReleaseInfo{}
@@ -192,54 +482,328 @@ func ReadReleaseInfo(filename string) (*release.ReleaseInfo, error) {
 	return releaseInfo, nil
 }
 
-func validateTag(ctx context.Context, tag *v1.TagReference, cfg *types.Config) *types.ScanResults {
-	image := tag.From.Name
+// validateTagArches is like validateTag, but with cfg.AllArches set, scans
+// every supported architecture of the image (if it is a manifest list),
+// one after another, returning the results per architecture.
+func validateTagArches(ctx context.Context, tag *v1.TagReference, cfg *types.Config, pulls semaphore) []*types.ScanResults {
+	if !cfg.AllArches || isImageFiltered(cfg, tag.From.Name) {
+		return []*types.ScanResults{validateTag(ctx, tag, cfg, pulls)}
+	}
+	arches, err := imageArches(ctx, cfg, tag.From.Name)
+	if err != nil {
+		res := types.NewScanResult().SetTag(tag).SetError(&OperationalError{err})
+		return []*types.ScanResults{newResults(cfg, res).SetTag(tag)}
+	}
+	if arches == nil {
+		// Not a manifest list.
+		return []*types.ScanResults{validateTag(ctx, tag, cfg, pulls)}
+	}
+	var runs []*types.ScanResults
+	for _, arch := range arches {
+		if ctx.Err() != nil {
+			break
+		}
+		archCfg := *cfg
+		archCfg.Arch = arch
+		runs = append(runs, validateTag(ctx, tag, &archCfg, pulls).SetArch(arch))
+	}
+	return runs
+}
 
-	// skip over ignored images
+// imageArches returns the supported architectures (see Arches) of the
+// image, in the manifest list order, or nil if the image is not
+// a manifest list. Other platforms are skipped with a warning.
+func imageArches(ctx context.Context, cfg *types.Config, image string) ([]string, error) {
+	ref := cfg.MirrorImage(image)
+	authFile, cleanup, err := registryAuthFile(ctx, cfg, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	opts := &podman.PullOptions{
+		Insecure: cfg.InsecurePull,
+		AuthFile: authFile,
+		Env:      cfg.ProxyEnv(),
+	}
+	platforms, err := podman.ManifestPlatforms(ctx, ref, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(platforms) == 0 {
+		return nil, nil
+	}
+	arches := []string{}
+	seen := make(map[string]bool)
+	for _, p := range platforms {
+		if p.OS == "unknown" && p.Architecture == "unknown" {
+			// Not an image (e.g. a build attestation).
+			continue
+		}
+		if p.OS != "linux" || ValidateArch(p.Architecture) != nil {
+			klog.Warningf("skipping platform %s of image %s: not supported (supported are linux/%s)", p, image, strings.Join(Arches, ", linux/"))
+			continue
+		}
+		if !seen[p.Architecture] {
+			seen[p.Architecture] = true
+			arches = append(arches, p.Architecture)
+		}
+	}
+	if len(arches) == 0 {
+		return nil, fmt.Errorf("image %s has no supported platforms", image)
+	}
+	return arches, nil
+}
+
+// isImageFiltered tells if the image is to be skipped (see FilterImages).
+func isImageFiltered(cfg *types.Config, image string) bool {
 	for _, ignoredImage := range cfg.FilterImages {
 		if ignoredImage == image {
-			klog.InfoS("Ignoring image", "image", image)
-			return types.NewScanResults().Append(types.NewScanResult().SetTag(tag).Success())
+			return true
+		}
+	}
+	return false
+}
+
+// validateTag pulls, mounts, and scans the image. The pulls semaphore
+// limits the number of concurrent pulls.
+func validateTag(ctx context.Context, tag *v1.TagReference, cfg *types.Config, pulls semaphore) (results *types.ScanResults) {
+	image := tag.From.Name
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "scan image", tracing.String(tracing.AttrImage, image), tracing.String(tracing.AttrTag, tag.Name), tracing.String(tracing.AttrArch, cfg.Arch))
+	defer func() {
+		results.SetTag(tag).SetTime(start, time.Now())
+		endSpan(span, []*types.ScanResults{results})
+		metricImages.Inc()
+	}()
+
+	// skip over ignored images
+	if isImageFiltered(cfg, image) {
+		klog.InfoS("Ignoring image", "image", image)
+		return newResults(cfg, types.NewScanResult().SetTag(tag).Success())
+	}
+
+	// Use the cached root filesystem, if available (an incremental
+	// scan needs the image to be pulled, so the cache is not used).
+	if cfg.CacheDir != "" && !cfg.NoCache && cfg.PreviousImage == "" {
+		if digest := cache.DigestFromRef(image); digest != "" {
+			if c, err := newCache(cfg); err == nil {
+				if root, component, ok := c.Get(cacheKey(cfg, digest)); ok {
+					defer c.Release(cacheKey(cfg, digest))
+					return scanRoot(ctx, cfg, tag, component, root, nil)
+				}
+			}
 		}
 	}
 
 	// pull
-	if err := podman.Pull(ctx, image, cfg.InsecurePull); err != nil {
-		return types.NewScanResults().Append(types.NewScanResult().SetTag(tag).SetError(err))
+	if err := pulls.acquire(ctx); err != nil {
+		return imageError(cfg, tag, err)
+	}
+	pullCtx, pullSpan := tracing.Start(ctx, "pull", tracing.String(tracing.AttrImage, image))
+	ref, err := pullImage(pullCtx, cfg, image)
+	pullSpan.SetError(err)
+	pullSpan.End()
+	pulls.release()
+	if err != nil {
+		return imageError(cfg, tag, err)
+	}
+	if cfg.FromArchive != "" {
+		// Remove the image loaded from the archive (after unmounting it).
+		defer func() {
+			if cfg.KeepTemp {
+				klog.InfoS("keeping image loaded from archive", "archive", cfg.FromArchive, "id", ref)
+				return
+			}
+			if err := podman.RemoveImage(context.Background(), ref); err != nil {
+				klog.Warningf("can't remove image loaded from %s: %v", cfg.FromArchive, err)
+			}
+		}()
 	}
 	// mount
-	mountPath, err := podman.Mount(ctx, image)
+	mountCtx, mountSpan := tracing.Start(ctx, "mount", tracing.String(tracing.AttrImage, image))
+	mountPath, err := podman.Mount(mountCtx, ref)
+	mountSpan.SetError(err)
+	mountSpan.End()
 	if err != nil {
-		return types.NewScanResults().Append(types.NewScanResult().SetTag(tag).SetError(err))
+		return imageError(cfg, tag, err)
 	}
+	klog.V(2).InfoS("image mounted", "image", image, "path", mountPath)
 	defer func() {
-		_ = podman.Unmount(ctx, image)
+		if cfg.KeepTemp {
+			klog.InfoS("keeping image mounted", "image", image, "path", mountPath)
+			return
+		}
+		// Use a fresh context so the cleanup is done even
+		// if ctx is canceled or timed out.
+		if err := podman.Unmount(context.Background(), ref); err != nil {
+			klog.Warningf("can't unmount image %s: %v", image, err)
+		}
 	}()
 	// get openshift component
-	component, _ := podman.GetOpenshiftComponentFromImage(ctx, image)
+	component, _ := podman.GetOpenshiftComponentFromImage(ctx, ref)
 	if component != nil {
 		klog.V(1).InfoS("found operator", "component", component.Component, "source_location", component.SourceLocation, "maintainer_component", component.MaintainerComponent, "is_bundle", component.IsBundle)
 	}
+	if cfg.CacheDir != "" {
+		cacheRoot(ctx, cfg, image, ref, mountPath, component)
+	}
+	var inc *incremental
+	if cfg.PreviousImage != "" {
+		inc, err = setupIncremental(ctx, cfg, ref, pulls)
+		if err != nil {
+			return imageError(cfg, tag, err)
+		}
+	}
+
+	return scanRoot(ctx, cfg, tag, component, mountPath, inc)
+}
+
+// imageError returns the results of an image which could not be scanned
+// because of an operational error, such as a failed pull.
+func imageError(cfg *types.Config, tag *v1.TagReference, err error) *types.ScanResults {
+	return newResults(cfg, types.NewScanResult().SetTag(tag).SetError(&OperationalError{err}))
+}
+
+func newCache(cfg *types.Config) (*cache.Cache, error) {
+	c, err := cache.New(cfg.CacheDir, cfg.CacheMaxSize)
+	if err != nil {
+		klog.Warningf("can't use cache: %v", err)
+	}
+	return c, err
+}
+
+// cacheKey returns the cache key for the image with a given digest. As
+// the digest may be of a multi-arch manifest list, the key includes the
+// architecture, if set (see --arch).
+func cacheKey(cfg *types.Config, digest string) string {
+	if cfg.Arch == "" {
+		return digest
+	}
+	return digest + "-" + cfg.Arch
+}
+
+// cacheRoot saves a copy of the image root filesystem to the cache.
+// Any errors are logged but otherwise ignored.
+func cacheRoot(ctx context.Context, cfg *types.Config, image, ref, root string, component *types.OpenshiftComponent) {
+	c, err := newCache(cfg)
+	if err != nil {
+		return
+	}
+	digest := cache.DigestFromRef(image)
+	if digest == "" {
+		digest, err = podman.ImageDigest(ctx, ref)
+		if err != nil {
+			klog.Warningf("can't get image digest for cache: %v", err)
+			return
+		}
+	}
+	digest = cacheKey(cfg, digest)
+	if !cfg.NoCache {
+		if _, _, ok := c.Get(digest); ok {
+			// Already cached.
+			c.Release(digest)
+			return
+		}
+	}
+	if err := c.Put(digest, root, component); err != nil {
+		klog.Warningf("can't cache image %s: %v", image, err)
+	}
+}
+
+// scanRoot scans the image root filesystem mounted (or extracted) to root.
+// If inc is not nil, only the files changed since the previous image are
+// scanned.
+func scanRoot(ctx context.Context, cfg *types.Config, tag *v1.TagReference, component *types.OpenshiftComponent, mountPath string, inc *incremental) (results *types.ScanResults) {
+	ctx, span := tracing.Start(ctx, "scan files")
+	defer func() { endSpan(span, []*types.ScanResults{results}) }()
+
 	// skip if bundle image
-	if component.IsBundle {
-		return types.NewScanResults().Append(types.NewScanResult().SetTag(tag).Skipped())
+	if component != nil && component.IsBundle {
+		return newResults(cfg, types.NewScanResult().SetTag(tag).Skipped())
 	}
 
+	// Images are already scanned in parallel, so use a single worker.
 	if cfg.UseRPMScan {
 		// Same as "scan node", essentially meaning to
 		//  - only scan files from rpms;
 		//  - skip per-tag and per-component config rules.
-		return rpmRootScan(ctx, cfg, mountPath)
+		return rpmRootScan(ctx, cfg, mountPath, nil, 1)
 	}
-	return walkDirScan(ctx, cfg, tag, component, mountPath)
+	return walkDirScan(ctx, cfg, tag, component, mountPath, inc, 1)
 }
 
-func walkDirScan(ctx context.Context, cfg *types.Config, tag *v1.TagReference, component *types.OpenshiftComponent, mountPath string) *types.ScanResults {
+// pullImage pulls the image (from a mirror, if any of cfg.RegistryMirrors
+// matches), and returns the reference to be used for subsequent podman
+// commands.
+func pullImage(ctx context.Context, cfg *types.Config, image string) (string, error) {
+	if cfg.FromArchive != "" {
+		// Load from archive rather than pull from a registry.
+		return podman.PullArchive(ctx, cfg.FromArchive, cfg.FromArchiveTransport, &podman.PullOptions{TempDir: cfg.TempDir})
+	}
+	if ref := cfg.MirrorImage(image); ref != image {
+		klog.V(1).InfoS("using mirror", "image", image, "mirror", ref)
+		image = ref
+	}
+	authFile, cleanup, err := registryAuthFile(ctx, cfg, image)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	opts := &podman.PullOptions{
+		Insecure: cfg.InsecurePull,
+		AuthFile: authFile,
+		Arch:     cfg.Arch,
+		Env:      cfg.ProxyEnv(),
+		TempDir:  cfg.TempDir,
+	}
+	if err := pullWithRetry(ctx, image, opts, cfg.PullRetries); err != nil {
+		return "", err
+	}
+	if cfg.Arch != "" {
+		// For a single-arch image, podman pulls it even if
+		// the arch does not match, so check it explicitly.
+		arch, err := podman.ImageArch(ctx, image)
+		if err != nil {
+			return "", err
+		}
+		if arch != cfg.Arch {
+			return "", fmt.Errorf("image %s has no %s manifest (got %s)", image, cfg.Arch, arch)
+		}
+	}
+	return image, nil
+}
+
+// Arches are the architectures supported by --arch.
+var Arches = []string{"amd64", "arm64", "ppc64le", "s390x"}
+
+// ValidateArch checks that arch is one of Arches.
+func ValidateArch(arch string) error {
+	for _, a := range Arches {
+		if a == arch {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown architecture %q (should be one of %s)", arch, strings.Join(Arches, ", "))
+}
+
+// walkDirScan scans the files under mountPath, found by a directory tree
+// walk. The files are scanned by the given number of workers in parallel.
+// Errors accessing individual files or directories are reported, but do
+// not stop the walk.
+func walkDirScan(ctx context.Context, cfg *types.Config, tag *v1.TagReference, component *types.OpenshiftComponent, mountPath string, inc *incremental, workers int) *types.ScanResults {
 	results := types.NewScanResults()
 
+	// appendResult adds a scan result (see also cfg.OnResult).
+	appendResult := func(res *types.ScanResult) {
+		results.Append(res)
+		onResult(cfg, res)
+	}
+
 	// does the image contain openssl
-	opensslInfo := validations.ValidateOpenssl(ctx, mountPath)
-	results.Append(types.NewScanResult().SetOpenssl(opensslInfo).SetTag(tag))
+	if !cfg.DryRun {
+		opensslInfo := validations.ValidateOpenssl(ctx, mountPath)
+		appendResult(types.NewScanResult().SetOpenssl(opensslInfo).SetTag(tag))
+	}
 
 	errIgnoreLists := []types.ErrIgnoreList{cfg.ErrIgnores}
 
@@ -253,15 +817,67 @@ func walkDirScan(ctx context.Context, cfg *types.Config, tag *v1.TagReference, c
 			errIgnoreLists = append(errIgnoreLists, i.ErrIgnores)
 		}
 	}
+	var disabledChecks []string
+	if o := cfg.ComponentOverride(component); o != nil {
+		errIgnoreLists = append(errIgnoreLists, o.ErrIgnores)
+		disabledChecks = o.DisableChecks
+	}
+
+	// The walk feeds the files to scan to the workers.
+	if workers < 1 {
+		workers = 1
+	}
+	tx := make(chan walkFile, workers)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for f := range tx {
+				for _, res := range scanWalkFile(ctx, cfg, mountPath, f, disabledChecks, errIgnoreLists) {
+					res.SetTag(tag).SetComponent(component)
+					logWalkResult(cfg, res)
+					appendResult(res)
+					checkFailFast(ctx, res)
+				}
+			}
+		}()
+	}
+
+	// dispatch scans the file found by the walk (or by the sampling).
+	dispatch := func(f walkFile) error {
+		if cfg.DryRun {
+			appendResult(types.NewScanResult().SetPath(f.innerPath).SetTag(tag).SetComponent(component))
+			return nil
+		}
+		// The workers decide if the previous results still hold.
+		f.prior, _ = inc.carryForward(f.innerPath)
+		select {
+		case tx <- f:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+	// With cfg.SampleLargest, the files are dispatched after the walk.
+	var sample []walkFile
 
 	// business logic for scan
-	if err := filepath.WalkDir(mountPath, func(path string, file fs.DirEntry, err error) error {
+	_ = filepath.WalkDir(mountPath, func(path string, file fs.DirEntry, err error) error {
+		innerPath := stripMountPath(mountPath, path)
 		if err != nil {
-			return err
+			// Report the error, and carry on (for a directory
+			// that can't be read, its contents are skipped).
+			klog.InfoS("scanning error", "path", innerPath, "error", err)
+			appendResult(types.NewScanResult().SetPath(innerPath).SetTag(tag).SetComponent(component).SetError(&OperationalError{err}))
+			return nil
+		}
+		if ctx.Err() != nil {
+			// Time limit exceeded.
+			return ctx.Err()
 		}
-		innerPath := stripMountPath(mountPath, path)
 		if file.IsDir() {
-			if cfg.IgnoreDirWithComponent(innerPath, component) {
+			if !cfg.IsDirIncluded(innerPath) || cfg.IgnoreDirWithComponent(innerPath, component) {
 				return filepath.SkipDir
 			}
 			return nil
@@ -275,48 +891,175 @@ func walkDirScan(ctx context.Context, cfg *types.Config, tag *v1.TagReference, c
 		// as it calls lstat(2) under the hood.
 		fi, err := file.Info()
 		if err != nil {
-			return err
+			appendResult(types.NewScanResult().SetPath(innerPath).SetTag(tag).SetComponent(component).SetError(&OperationalError{err}))
+			return nil
 		}
-		if fi.Mode().Perm()&0o111 == 0 {
-			// Not an executable.
+		var archive string
+		if fi.Mode().Perm()&0o111 == 0 && !validations.IsPythonExtensionName(innerPath) {
+			// Not an executable (python extension modules
+			// need not be executable, but are scanned).
+			if !cfg.ScanArchives {
+				return nil
+			}
+			if archive, err = archiveKind(path); err != nil || archive == "" {
+				return nil
+			}
+		}
+		if !cfg.IsIncluded(innerPath) {
 			return nil
 		}
 		if cfg.IgnoreFileWithTag(innerPath, tag) || cfg.IgnoreFileWithComponent(innerPath, component) {
 			return nil
 		}
-		klog.V(1).InfoS("scanning path", "path", path)
-		res := validations.ScanBinary(ctx, mountPath, innerPath, cfg.RPMIgnores, errIgnoreLists...)
-		if res.Skip {
-			// Do not add skipped binaries to results.
+		if isUnmodified(cfg, fi) {
+			if !cfg.DryRun {
+				appendResult(types.NewScanResult().SetPath(innerPath).SetTag(tag).SetComponent(component).SkippedBecause(skipReasonUnchanged))
+			}
 			return nil
 		}
-		// Check rpm.* excludes. Performed post-check because the rpm name was not known before.
-		if !res.IsSuccess() && res.RPM != "" && cfg.IgnoreFileByRpm(innerPath, res.RPM) {
+		f := walkFile{path: path, innerPath: innerPath, archive: archive, size: fi.Size()}
+		if cfg.SampleLargest > 0 {
+			sample = append(sample, f)
 			return nil
 		}
-		res.SetTag(tag).SetComponent(component)
-		if res.IsSuccess() {
-			klog.V(1).InfoS("scanning success", "image", getImage(res), "path", innerPath, "status", "success")
-		} else {
-			status := res.Status()
-			klog.InfoS("scanning "+status,
-				"image", getImage(res),
-				"path", innerPath,
-				"error", res.Error.Error,
-				"component", getComponent(res),
-				"tag", getTag(res),
-				"rpm", res.RPM,
-				"status", status)
+		return dispatch(f)
+	})
+	if cfg.SampleLargest > 0 && ctx.Err() == nil {
+		for _, f := range sampleLargest(sample, cfg.SampleLargest) {
+			if dispatch(f) != nil {
+				break
+			}
 		}
-		results.Append(res)
-		return nil
-	}); err != nil {
-		return results.Append(types.NewScanResult().SetError(err))
 	}
+	close(tx)
+	wg.Wait()
 
 	return results
 }
 
+// onResult calls cfg.OnResult, if set, with the scan result.
+func onResult(cfg *types.Config, res *types.ScanResult) {
+	countResult(res)
+	if cfg.OnResult != nil {
+		cfg.OnResult(res)
+	}
+}
+
+// newResults returns the scan results made of the given ones, which
+// are passed to onResult.
+func newResults(cfg *types.Config, res ...*types.ScanResult) *types.ScanResults {
+	results := types.NewScanResults()
+	for _, r := range res {
+		results.Append(r)
+		onResult(cfg, r)
+	}
+	return results
+}
+
+// logWalkResult logs the file scan result, unless cfg.Quiet is set.
+func logWalkResult(cfg *types.Config, res *types.ScanResult) {
+	if cfg.Quiet {
+		return
+	}
+	if res.IsSuccess() {
+		klog.V(1).InfoS("scanning success", "image", getImage(res), "path", res.Path, "status", "success")
+		return
+	}
+	status := res.Status()
+	klog.InfoS("scanning "+status,
+		"image", getImage(res),
+		"path", res.Path,
+		"error", res.Error.Error,
+		"component", getComponent(res),
+		"tag", getTag(res),
+		"rpm", res.RPM,
+		"status", status)
+}
+
+// walkFile is a file to scan, found by walkDirScan.
+type walkFile struct {
+	path, innerPath string
+	// archive is the archive kind, if the file is an archive to scan.
+	archive string
+	// size is the file size, used by cfg.SampleLargest.
+	size int64
+	// prior are the previous results of the file unchanged since the
+	// previous image (see incremental.carryForward), if any.
+	prior []*types.ScanResult
+}
+
+// scanWalkFile scans a single file found by walkDirScan.
+func scanWalkFile(ctx context.Context, cfg *types.Config, mountPath string, f walkFile, disabledChecks []string, errIgnoreLists []types.ErrIgnoreList) []*types.ScanResult {
+	innerPath := f.innerPath
+	if f.archive != "" {
+		budget := cfg.ArchiveMaxSize
+		return scanArchive(ctx, cfg, mountPath, innerPath, f.path, innerPath, f.archive, 1, &budget, disabledChecks, errIgnoreLists...)
+	}
+	if f.prior != nil && isPriorValid(ctx, cfg, mountPath, innerPath, f.prior, disabledChecks, errIgnoreLists) {
+		klog.V(1).InfoS("unchanged since previous image, using previous results", "path", innerPath)
+		return f.prior
+	}
+	if !cfg.Quiet {
+		klog.V(1).InfoS("scanning path", "path", f.path)
+	}
+	countBinary()
+	res := scanBinary(ctx, cfg, mountPath, innerPath, disabledChecks, errIgnoreLists...)
+	if res.Skip && res.SkipReason == "" {
+		// Do not add skipped non-binaries to results, but keep the
+		// files skipped for a reason (such as core dumps).
+		return nil
+	}
+	// Check rpm.* excludes. Performed post-check because the rpm name was not known before.
+	if !res.IsSuccess() && res.RPM != "" {
+		if rule := cfg.IgnoreFileByRpmRule(innerPath, res.RPM); rule != "" && !cfg.RefuseException(innerPath, res.Error.GetError(), rule) {
+			// Keep the result, to tell which rule filtered it out.
+			res.Success().Skipped().AddException(rule)
+		}
+	}
+	return []*types.ScanResult{res}
+}
+
+// scanBinary is like validations.ScanBinary, but limits the scan time
+// to cfg.PerBinaryTimeout (if set). On timeout, the context passed to
+// the checks is canceled (so that any subprocesses are killed), and a
+// result with a timeout error is returned once the check in progress
+// returns (see withBinaryTimeout).
+func scanBinary(ctx context.Context, cfg *types.Config, topDir, innerPath string, disabledChecks []string, errIgnores ...types.ErrIgnoreList) *types.ScanResult {
+	return withBinaryTimeout(ctx, cfg, innerPath, func(ctx context.Context) *types.ScanResult {
+		return validations.ScanBinary(ctx, cfg, topDir, innerPath, disabledChecks, errIgnores...)
+	})
+}
+
+// withBinaryTimeout runs scan, limiting its time to cfg.PerBinaryTimeout
+// (see scanBinary). The innerPath is the path to report on timeout. On
+// timeout, it still waits for scan to return (which it does after the
+// check in progress, as the remaining ones are not started), so that the
+// timed out scans don't pile up beyond cfg.Parallelism.
+func withBinaryTimeout(ctx context.Context, cfg *types.Config, innerPath string, scan func(context.Context) *types.ScanResult) *types.ScanResult {
+	if cfg.PerBinaryTimeout <= 0 {
+		return scan(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.PerBinaryTimeout)
+	defer cancel()
+
+	done := make(chan *types.ScanResult, 1)
+	go func() {
+		done <- scan(ctx)
+	}()
+	select {
+	case res := <-done:
+		return res
+	case <-ctx.Done():
+		err := ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("scan timed out after %v", cfg.PerBinaryTimeout)
+		}
+		cancel()
+		<-done
+		return types.NewScanResult().SetPath(innerPath).SetError(err)
+	}
+}
+
 func stripMountPath(mountPath, path string) string {
 	return strings.TrimPrefix(path, mountPath)
 }
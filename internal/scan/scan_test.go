@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/openshift/api/image/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestValidateArch(t *testing.T) {
+	for _, arch := range Arches {
+		assert.NoError(t, ValidateArch(arch))
+	}
+	assert.Error(t, ValidateArch("x86_64"))
+	assert.Error(t, ValidateArch(""))
+}
+
+func TestCacheKey(t *testing.T) {
+	assert.Equal(t, "sha256:1234", cacheKey(&types.Config{}, "sha256:1234"))
+	assert.Equal(t, "sha256:1234-arm64", cacheKey(&types.Config{Arch: "arm64"}, "sha256:1234"))
+}
+
+func TestPullParallelism(t *testing.T) {
+	assert.Equal(t, 2, pullParallelism(&types.Config{Parallelism: 5, PullParallelism: 2}))
+	assert.Equal(t, 5, pullParallelism(&types.Config{Parallelism: 5}))
+	assert.Equal(t, 1, pullParallelism(&types.Config{}))
+}
+
+func TestReleaseImage(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	for in, want := range map[string]string{
+		digest: "quay.io/openshift-release-dev/ocp-release@" + digest,
+		"quay.io/openshift-release-dev/ocp-release@" + digest:          "quay.io/openshift-release-dev/ocp-release@" + digest,
+		"docker://quay.io/openshift-release-dev/ocp-release@" + digest: "quay.io/openshift-release-dev/ocp-release@" + digest,
+		"quay.io/openshift-release-dev/ocp-release:4.14.1-x86_64":      "quay.io/openshift-release-dev/ocp-release:4.14.1-x86_64",
+		"registry.example.com/ocp/release@" + digest:                   "registry.example.com/ocp/release@" + digest,
+		"sha256:1234": "sha256:1234",
+	} {
+		assert.Equal(t, want, ReleaseImage(in), in)
+	}
+}
+
+func TestSelectTags(t *testing.T) {
+	var tags []v1.TagReference
+	for _, name := range []string{"cli", "etcd", "cluster-a", "cluster-b"} {
+		tags = append(tags, v1.TagReference{Name: name, From: &corev1.ObjectReference{Name: "quay.io/" + name}})
+	}
+	names := func(cfg *types.Config) []string {
+		var names []string
+		for _, tag := range selectTags(cfg, tags) {
+			names = append(names, tag.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"cli", "etcd", "cluster-a", "cluster-b"}, names(&types.Config{}))
+	assert.Equal(t, []string{"etcd", "cluster-a", "cluster-b"}, names(&types.Config{Components: []string{"cluster-*", "etcd"}}))
+	assert.Equal(t, []string{"cli", "etcd"}, names(&types.Config{Limit: 2}))
+	// The limit applies to the payload tags, not the selected ones.
+	assert.Equal(t, []string{"etcd"}, names(&types.Config{Components: []string{"cluster-*", "etcd"}, Limit: 2}))
+}
+
+func TestDownloadReleaseInfoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := DownloadReleaseInfo(ctx, "quay.io/openshift-release-dev/ocp-release:4.14.0-x86_64", "", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWithBinaryTimeout(t *testing.T) {
+	cfg := &types.Config{PerBinaryTimeout: 10 * time.Millisecond}
+	var running atomic.Int64
+	for i := 0; i < 5; i++ {
+		res := withBinaryTimeout(context.Background(), cfg, "/bin/slow", func(ctx context.Context) *types.ScanResult {
+			running.Add(1)
+			defer running.Add(-1)
+			<-ctx.Done()
+			// Like a check not looking at ctx, which takes a while to finish.
+			time.Sleep(20 * time.Millisecond)
+			return types.NewScanResult().Success()
+		})
+		assert.Equal(t, "/bin/slow", res.Path)
+		assert.ErrorContains(t, res.Error.Error, "scan timed out after 10ms")
+		// The timed out scan is not left running.
+		assert.Zero(t, running.Load())
+	}
+}
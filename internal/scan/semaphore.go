@@ -0,0 +1,35 @@
+package scan
+
+import "context"
+
+// semaphore limits the number of concurrent operations.
+// A nil semaphore means no limit.
+type semaphore chan struct{}
+
+// newSemaphore returns a semaphore allowing up to n concurrent operations,
+// or nil (no limit) if n is not positive.
+func newSemaphore(n int) semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+// acquire blocks until the operation is allowed to proceed, or ctx is done.
+func (s semaphore) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
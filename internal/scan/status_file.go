@@ -0,0 +1,43 @@
+package scan
+
+import (
+	"encoding/json"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// runStatus is the machine-readable status of the run, written to
+// the --status-file at the end of the run, whatever the output format.
+type runStatus struct {
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Warned  int `json:"warned"`
+	Skipped int `json:"skipped"`
+	// ExitReason is the reason for the exit code (see main.exitReason).
+	ExitReason string `json:"exitReason"`
+	// Error is the message of the operational error the run failed with.
+	Error string `json:"error,omitempty"`
+}
+
+// WriteStatusFile writes the numbers of results by status, the exit
+// reason, and the operational error message (if opErr is not nil) to the
+// file, as JSON. The file is updated atomically, so a reader never sees it
+// half-written.
+func WriteStatusFile(file string, results []*types.ScanResults, exitReason string, opErr error) error {
+	sum := countResults(results)
+	status := runStatus{
+		Passed:     sum.Passed,
+		Failed:     sum.Failed,
+		Warned:     sum.Warnings,
+		Skipped:    sum.Skipped,
+		ExitReason: exitReason,
+	}
+	if opErr != nil {
+		status.Error = opErr.Error()
+	}
+	data, err := json.MarshalIndent(&status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, append(data, '\n'))
+}
@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestWriteStatusFile(t *testing.T) {
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/ok").Success()).
+			Append(types.NewScanResult().SetPath("/ok2").Success()).
+			Append(types.NewScanResult().SetPath("/fail").SetError(errors.New("fail"))).
+			Append(types.NewScanResult().SetPath("/warn").SetValidationError(types.NewValidationError(errors.New("warn")).SetWarning())),
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/skip").Skipped()),
+	}
+	file := filepath.Join(t.TempDir(), "status.json")
+
+	for _, tc := range []struct {
+		results []*types.ScanResults
+		reason  string
+		err     error
+		want    string
+	}{
+		{results, "failed", nil, `{
+  "passed": 2,
+  "failed": 1,
+  "warned": 1,
+  "skipped": 1,
+  "exitReason": "failed"
+}
+`},
+		{nil, "error", errors.New("can't pull"), `{
+  "passed": 0,
+  "failed": 0,
+  "warned": 0,
+  "skipped": 0,
+  "exitReason": "error",
+  "error": "can't pull"
+}
+`},
+	} {
+		if err := WriteStatusFile(file, tc.results, tc.reason, tc.err); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.want {
+			t.Errorf("want:\n%s\ngot:\n%s", tc.want, data)
+		}
+	}
+
+	if err := WriteStatusFile(filepath.Join(file, "x"), results, "passed", nil); err == nil {
+		t.Error("want error writing to a bad path, got nil")
+	}
+}
@@ -0,0 +1,141 @@
+package scan
+
+import (
+	"sort"
+	"time"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// summary is a number of scan results by status.
+type summary struct {
+	Total    int `json:"total"`
+	Passed   int `json:"passed"`
+	Failed   int `json:"failed"`
+	Warnings int `json:"warnings"`
+	Skipped  int `json:"skipped"`
+	// Severities is a number of failures and warnings by the severity
+	// of the failed check (results without a severity are not counted).
+	Severities map[types.Severity]int `json:"severities,omitempty"`
+	// Duration is the total wall-clock scan time, in seconds.
+	Duration float64 `json:"duration_seconds"`
+	// Incomplete is the reason the scan was stopped early, if it was.
+	Incomplete string `json:"incomplete,omitempty"`
+	// Sampled tells that only some of the files were scanned (see
+	// --sample-largest), if that is the case.
+	Sampled string `json:"sampled,omitempty"`
+	// Arches is a breakdown by image architecture, only set
+	// for --all-arches scans.
+	Arches []archSummary `json:"arches,omitempty"`
+}
+
+// archSummary is a summary of scan results of a given architecture.
+type archSummary struct {
+	Arch string `json:"arch"`
+	*summary
+}
+
+func newSummary(results []*types.ScanResults) *summary {
+	s := countResults(results)
+	byArch := make(map[string][]*types.ScanResults)
+	var arches []string
+	for _, result := range results {
+		if result.Arch == "" {
+			continue
+		}
+		if _, ok := byArch[result.Arch]; !ok {
+			arches = append(arches, result.Arch)
+		}
+		byArch[result.Arch] = append(byArch[result.Arch], result)
+	}
+	sort.Strings(arches)
+	for _, arch := range arches {
+		s.Arches = append(s.Arches, archSummary{Arch: arch, summary: countResults(byArch[arch])})
+	}
+	return s
+}
+
+// countResults returns the summary of results, without a per-arch breakdown.
+func countResults(results []*types.ScanResults) *summary {
+	s := &summary{}
+	var start, end time.Time
+	for _, result := range results {
+		if result.Duration() > 0 {
+			if start.IsZero() || result.Start.Before(start) {
+				start = result.Start
+			}
+			if result.End.After(end) {
+				end = result.End
+			}
+		}
+		for _, res := range result.Items {
+			s.Total++
+			switch {
+			case res.Skip:
+				s.Skipped++
+			case res.IsLevel(types.Error):
+				s.Failed++
+			case res.IsLevel(types.Warning):
+				s.Warnings++
+			default:
+				s.Passed++
+			}
+			if res.Error != nil && !res.Skip && res.Severity != types.SeverityUnknown {
+				if s.Severities == nil {
+					s.Severities = make(map[types.Severity]int)
+				}
+				s.Severities[res.Severity]++
+			}
+		}
+	}
+	if !start.IsZero() {
+		s.Duration = end.Sub(start).Seconds()
+	}
+	return s
+}
+
+// slowestImages returns up to n results of image scans which took
+// the longest time, slowest first.
+func slowestImages(results []*types.ScanResults, n int) []*types.ScanResults {
+	var images []*types.ScanResults
+	for _, result := range results {
+		if result.Tag != nil && result.Duration() > 0 {
+			images = append(images, result)
+		}
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].Duration() > images[j].Duration()
+	})
+	if len(images) > n {
+		images = images[:n]
+	}
+	return images
+}
+
+// filterResults returns the results to be shown, according to
+// cfg.OnlyFailures and cfg.OnlyWarnings, with the duplicates
+// collapsed if cfg.Dedupe is set (see dedupeResults). If none
+// is set, results are returned as is.
+func filterResults(cfg *types.Config, results []*types.ScanResults) []*types.ScanResults {
+	if cfg.Dedupe {
+		results = dedupeResults(results)
+	}
+	if !cfg.OnlyFailures && !cfg.OnlyWarnings {
+		return results
+	}
+	filtered := make([]*types.ScanResults, 0, len(results))
+	for _, result := range results {
+		shown := types.NewScanResults()
+		for _, res := range result.Items {
+			if res.Skip {
+				continue
+			}
+			if (cfg.OnlyFailures && res.IsLevel(types.Error)) ||
+				(cfg.OnlyWarnings && res.IsLevel(types.Warning)) {
+				shown.Append(res)
+			}
+		}
+		filtered = append(filtered, shown)
+	}
+	return filtered
+}
@@ -0,0 +1,159 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/openshift/api/image/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestSummaryAndFilter(t *testing.T) {
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/ok").Success()).
+			Append(types.NewScanResult().SetPath("/fail").SetError(errors.New("fail"))).
+			Append(types.NewScanResult().SetPath("/warn").SetValidationError(types.NewValidationError(errors.New("warn")).SetWarning())),
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/skip").Skipped()),
+	}
+
+	sum := newSummary(results)
+	want := summary{Total: 4, Passed: 1, Failed: 1, Warnings: 1, Skipped: 1}
+	if !reflect.DeepEqual(*sum, want) {
+		t.Errorf("summary: want %+v, got %+v", want, *sum)
+	}
+
+	paths := func(cfg *types.Config) []string {
+		var res []string
+		for _, result := range filterResults(cfg, results) {
+			for _, r := range result.Items {
+				res = append(res, r.Path)
+			}
+		}
+		return res
+	}
+	for _, tc := range []struct {
+		cfg  types.Config
+		want []string
+	}{
+		{types.Config{}, []string{"/ok", "/fail", "/warn", "/skip"}},
+		{types.Config{OnlyFailures: true}, []string{"/fail"}},
+		{types.Config{OnlyWarnings: true}, []string{"/warn"}},
+		{types.Config{OnlyFailures: true, OnlyWarnings: true}, []string{"/fail", "/warn"}},
+	} {
+		got := paths(&tc.cfg)
+		if len(got) != len(tc.want) {
+			t.Errorf("%+v: want %v, got %v", tc.cfg, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%+v: want %v, got %v", tc.cfg, tc.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestSummaryByArch(t *testing.T) {
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/ok").Success()).
+			Append(types.NewScanResult().SetPath("/fail").SetError(errors.New("fail"))).
+			SetArch("s390x"),
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/ok").Success()).
+			Append(types.NewScanResult().SetPath("/fail").Success()).
+			SetArch("amd64"),
+	}
+	for _, res := range results[0].Items {
+		if res.Arch != "s390x" {
+			t.Errorf("%s: want arch s390x, got %q", res.Path, res.Arch)
+		}
+	}
+
+	sum := newSummary(results)
+	want := summary{
+		Total: 4, Passed: 3, Failed: 1,
+		Arches: []archSummary{
+			{Arch: "amd64", summary: &summary{Total: 2, Passed: 2}},
+			{Arch: "s390x", summary: &summary{Total: 2, Passed: 1, Failed: 1}},
+		},
+	}
+	if !reflect.DeepEqual(*sum, want) {
+		t.Errorf("summary: want %+v, got %+v", want, *sum)
+	}
+
+	out := renderSummary(sum, "csv")
+	for _, row := range []string{"Arch,Total,", "amd64,2,2,0,", "s390x,2,1,1,", "all,4,3,1,"} {
+		if !strings.Contains(out, row) {
+			t.Errorf("summary table: no %q in\n%s", row, out)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	results := []*types.ScanResults{
+		types.NewScanResults().
+			Append(types.NewScanResult().SetPath("/ok").Success()).
+			Append(types.NewScanResult().SetPath("/low").SetError(errors.New("fail")).SetSeverity(types.SeverityLow)).
+			Append(types.NewScanResult().SetPath("/medium").SetValidationError(types.NewValidationError(errors.New("warn")).SetWarning()).SetSeverity(types.SeverityMedium)),
+	}
+
+	sum := newSummary(results)
+	want := map[types.Severity]int{types.SeverityLow: 1, types.SeverityMedium: 1}
+	if !reflect.DeepEqual(sum.Severities, want) {
+		t.Errorf("severities: want %v, got %v", want, sum.Severities)
+	}
+
+	for _, tc := range []struct {
+		threshold types.Severity
+		want      bool
+	}{
+		{types.SeverityInfo, true},
+		{types.SeverityLow, true},
+		// Warnings are not considered.
+		{types.SeverityMedium, false},
+		{types.SeverityHigh, false},
+	} {
+		if got := IsFailedAtSeverity(results, tc.threshold); got != tc.want {
+			t.Errorf("IsFailedAtSeverity(%v): want %v, got %v", tc.threshold, tc.want, got)
+		}
+	}
+
+	// A failure not caused by a check is of the highest severity.
+	results[0].Append(types.NewScanResult().SetPath("/other").SetError(errors.New("fail")))
+	if !IsFailedAtSeverity(results, types.SeverityHigh) {
+		t.Error("IsFailedAtSeverity(high): want true for a failure with no severity")
+	}
+}
+
+func TestSeverityBaseline(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "baseline.json")
+	if err := os.WriteFile(file, []byte(`{"failures": [{"image": "quay.io/foo@sha256:1", "path": "/known"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBaseline(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := &v1.TagReference{From: &corev1.ObjectReference{Name: "quay.io/foo@sha256:1"}}
+	known := types.NewScanResult().SetPath("/known").SetTag(tag).SetError(types.ErrNotDynLinked).SetSeverity(types.SeverityHigh)
+	results := []*types.ScanResults{types.NewScanResults().Append(known)}
+	if !IsFailedAtSeverity(results, types.SeverityHigh) {
+		t.Fatal("IsFailedAtSeverity(high): want true before applying the baseline")
+	}
+	// A baselined failure does not fail the run with --fail-on-severity.
+	b.Apply(&types.Config{}, results)
+	if IsFailedAtSeverity(results, types.SeverityHigh) {
+		t.Error("IsFailedAtSeverity(high): want false for a baselined failure")
+	}
+}
@@ -0,0 +1,141 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// tempDirPrefix is the name prefix of temporary directories we create.
+const tempDirPrefix = "check-payload-"
+
+// tempDirLock is the lock file in the temporary directories we create,
+// holding the PID of the process using the directory (see createTempDir).
+const tempDirLock = ".lock"
+
+// tempDirBase returns the directory to create temporary directories in.
+func tempDirBase() string {
+	if dir := os.Getenv("TMPDIR"); dir != "" {
+		return dir
+	}
+	// Same as podman default, as /tmp may be a (small) tmpfs.
+	return "/var/tmp"
+}
+
+// createTempDir creates a temporary directory (named tempDirPrefix+suffix
+// followed by a random string), which is locked until the returned remove
+// function is called, so it is never removed by CleanStaleTempDirs of
+// another run. The remove function removes the directory, unless keep is
+// set.
+func createTempDir(suffix string, keep bool) (dir string, remove func(), _ error) {
+	dir, err := os.MkdirTemp(tempDirBase(), tempDirPrefix+suffix)
+	if err != nil {
+		return "", nil, err
+	}
+	lock, err := os.Create(filepath.Join(dir, tempDirLock))
+	if err == nil {
+		err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			_, err = lock.WriteString(strconv.Itoa(os.Getpid()))
+		}
+		if err != nil {
+			lock.Close()
+		}
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	return dir, func() {
+		// Closing the file releases the lock.
+		lock.Close()
+		if keep {
+			return
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			klog.Warningf("can't remove temporary directory: %v", err)
+		}
+	}, nil
+}
+
+// isTempDirInUse tells if the temporary directory is used by a running
+// process, i.e. if it is locked, or the process which created it is alive.
+func isTempDirInUse(dir string) bool {
+	lock, err := os.Open(filepath.Join(dir, tempDirLock))
+	if err != nil {
+		// Not created by createTempDir.
+		return false
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		return true
+	}
+	data := make([]byte, 32)
+	n, _ := lock.Read(data)
+	pid, err := strconv.Atoi(string(data[:n]))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	err = syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// setupTempDir creates a temporary directory for podman to use while
+// pulling images (which is set as cfg.TempDir), and returns a function to
+// remove it (unless cfg.KeepTemp is set). If podman is killed (e.g. on
+// timeout), its temporary files are removed as well.
+func setupTempDir(cfg *types.Config) (cleanup func(), _ error) {
+	dir, remove, err := createTempDir("", cfg.KeepTemp)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(2).InfoS("using temporary directory", "path", dir)
+	cfg.TempDir = dir
+
+	return func() {
+		cfg.TempDir = ""
+		if cfg.KeepTemp {
+			klog.InfoS("keeping temporary directory", "path", dir)
+		}
+		remove()
+	}, nil
+}
+
+// CleanStaleTempDirs removes temporary directories left over by previous
+// (e.g. killed) runs, which were last modified more than age ago. The ones
+// still in use by another run are kept.
+func CleanStaleTempDirs(age time.Duration) {
+	base := tempDirBase()
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		klog.Warningf("can't clean stale temporary directories: %v", err)
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), tempDirPrefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < age {
+			continue
+		}
+		path := filepath.Join(base, e.Name())
+		if isTempDirInUse(path) {
+			klog.V(1).InfoS("keeping temporary directory in use", "path", path)
+			continue
+		}
+		klog.InfoS("removing stale temporary directory", "path", path, "modified", info.ModTime())
+		if err := os.RemoveAll(path); err != nil {
+			klog.Warningf("can't remove stale temporary directory: %v", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestTempDir(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("TMPDIR", base)
+
+	cfg := &types.Config{}
+	cleanup, err := setupTempDir(cfg)
+	require.NoError(t, err)
+	dirs, _ := filepath.Glob(filepath.Join(base, tempDirPrefix+"*"))
+	require.Len(t, dirs, 1)
+	assert.Equal(t, dirs[0], cfg.TempDir)
+	cleanup()
+	assert.NoDirExists(t, dirs[0])
+	assert.Empty(t, cfg.TempDir)
+
+	// Stale directories are removed, the recent ones and others are kept.
+	old := filepath.Join(base, tempDirPrefix+"old")
+	recent := filepath.Join(base, tempDirPrefix+"recent")
+	other := filepath.Join(base, "other")
+	for _, dir := range []string{old, recent, other} {
+		require.NoError(t, os.Mkdir(dir, 0o700))
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(old, past, past))
+	require.NoError(t, os.Chtimes(other, past, past))
+
+	// The ones in use by another run are kept, however old.
+	locked, remove, err := createTempDir("locked-", false)
+	require.NoError(t, err)
+	defer remove()
+	alive := filepath.Join(base, tempDirPrefix+"alive")
+	dead := filepath.Join(base, tempDirPrefix+"dead")
+	require.NoError(t, os.Mkdir(alive, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(alive, tempDirLock), []byte(strconv.Itoa(os.Getppid())), 0o600))
+	require.NoError(t, os.Mkdir(dead, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dead, tempDirLock), []byte("0"), 0o600))
+	for _, dir := range []string{locked, alive, dead} {
+		require.NoError(t, os.Chtimes(dir, past, past))
+	}
+
+	CleanStaleTempDirs(24 * time.Hour)
+	assert.NoDirExists(t, old)
+	assert.DirExists(t, recent)
+	assert.DirExists(t, other)
+	assert.DirExists(t, locked)
+	assert.DirExists(t, alive)
+	assert.NoDirExists(t, dead)
+}
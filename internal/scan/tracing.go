@@ -0,0 +1,35 @@
+package scan
+
+import (
+	"github.com/openshift/check-payload/internal/tracing"
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// payloadRef returns the release payload to scan, as given by cfg
+// (the image pull spec, or the file name).
+func payloadRef(cfg *types.Config) string {
+	switch {
+	case cfg.FromURL != "":
+		return cfg.FromURL
+	case cfg.FromMapping != "":
+		return cfg.FromMapping
+	}
+	return cfg.FromFile
+}
+
+// endSpan sets the span status attribute ("failed", "warning", or
+// "success") according to the results, and ends the span.
+func endSpan(span *tracing.Span, results []*types.ScanResults) {
+	if span == nil {
+		return
+	}
+	status := "success"
+	switch {
+	case IsFailed(results):
+		status = "failed"
+	case IsWarnings(results):
+		status = "warning"
+	}
+	span.SetAttributes(tracing.String(tracing.AttrStatus, status))
+	span.End()
+}
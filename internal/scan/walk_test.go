@@ -0,0 +1,217 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestWalkDirScanParallel(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	var want []string
+	for _, dir := range []string{"bin", "sbin", "usr/bin", "usr/libexec/foo"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+		for _, name := range []string{"a", "b", "c"} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(filepath.Join(root, path), exe, 0o755))
+			want = append(want, "/"+path)
+		}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "bin/README"), []byte("text"), 0o644))
+
+	cfg := &types.Config{Checks: []string{"dyn-linked"}}
+	for _, workers := range []int{1, 4} {
+		var got []string
+		for _, res := range walkDirScan(context.Background(), cfg, nil, nil, root, nil, workers).Items {
+			if res.Path == "" {
+				// The openssl info.
+				continue
+			}
+			assert.True(t, res.IsSuccess(), res.Path)
+			got = append(got, res.Path)
+		}
+		assert.ElementsMatch(t, want, got, "workers=%d", workers)
+	}
+}
+
+func TestWalkDirScanModifiedSince(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	since := time.Now().Add(-time.Hour)
+	for name, mtime := range map[string]time.Time{
+		"old": since.Add(-time.Minute),
+		"new": since.Add(time.Minute),
+	} {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.WriteFile(path, exe, 0o755))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	cfg := &types.Config{Checks: []string{"dyn-linked"}, ModifiedSince: since}
+	got := make(map[string]*types.ScanResult)
+	for _, res := range walkDirScan(context.Background(), cfg, nil, nil, root, nil, 1).Items {
+		got[res.Path] = res
+	}
+	require.Contains(t, got, "/old")
+	assert.True(t, got["/old"].Skip)
+	assert.Equal(t, "unchanged", got["/old"].SkipReason)
+	require.Contains(t, got, "/new")
+	assert.False(t, got["/new"].Skip)
+	assert.True(t, got["/new"].IsSuccess())
+
+	cfg.DryRun = true
+	var paths []string
+	for _, res := range walkDirScan(context.Background(), cfg, nil, nil, root, nil, 1).Items {
+		paths = append(paths, res.Path)
+	}
+	assert.Equal(t, []string{"/new"}, paths)
+}
+
+func TestParseModifiedSince(t *testing.T) {
+	got, err := ParseModifiedSince("2024-01-02T15:04:05Z")
+	require.NoError(t, err)
+	assert.True(t, got.Equal(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)))
+
+	got, err = ParseModifiedSince("2024-01-02T15:04:05.5+02:00")
+	require.NoError(t, err)
+	assert.True(t, got.Equal(time.Date(2024, 1, 2, 13, 4, 5, 5e8, time.UTC)))
+
+	got, err = ParseModifiedSince("2024-01-02")
+	require.NoError(t, err)
+	assert.True(t, got.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)))
+
+	_, err = ParseModifiedSince("yesterday")
+	assert.Error(t, err)
+}
+
+func TestWalkDirScanOnResult(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), exe, 0o755))
+	}
+
+	var mu sync.Mutex
+	var streamed []string
+	cfg := &types.Config{Checks: []string{"dyn-linked"}, OnResult: func(res *types.ScanResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed = append(streamed, res.Path)
+	}}
+	var got []string
+	for _, res := range walkDirScan(context.Background(), cfg, nil, nil, root, nil, 4).Items {
+		got = append(got, res.Path)
+	}
+	// Including the openssl info.
+	assert.ElementsMatch(t, []string{"", "/a", "/b", "/c", "/d"}, streamed)
+	assert.ElementsMatch(t, got, streamed)
+
+	// So are the results of images which could not be scanned.
+	streamed = nil
+	results := imageError(cfg, nil, errors.New("pull failed"))
+	assert.Len(t, results.Items, 1)
+	assert.Equal(t, []string{""}, streamed)
+}
+
+func TestWalkDirScanCoreDump(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "true"), exe, 0o755))
+	// Turn the executable into a "core dump" by setting e_type to ET_CORE.
+	core := append([]byte{}, exe...)
+	order := binary.ByteOrder(binary.LittleEndian)
+	if elf.Data(core[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+	order.PutUint16(core[16:], uint16(elf.ET_CORE))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "core"), core, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "script"), []byte("#!/bin/sh\n"), 0o755))
+
+	cfg := &types.Config{Checks: []string{"dyn-linked"}}
+	results := walkDirScan(context.Background(), cfg, nil, nil, root, nil, 1)
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, []*types.ScanResults{results}, newSummary([]*types.ScanResults{results}), nil))
+	var report struct {
+		Results []jsonResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	got := make(map[string]jsonResult)
+	for _, jr := range report.Results {
+		if jr.Path != "" { // Not the openssl info.
+			got[jr.Path] = jr
+		}
+	}
+	// The core dump is reported as skipped, other non-binaries are not reported.
+	require.Len(t, got, 2, got)
+	assert.Equal(t, "success", got["/true"].Status)
+	assert.True(t, got["/core"].Skip)
+	assert.Equal(t, "core dump", got["/core"].SkipReason)
+}
+
+func TestLogWalkResultQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	klog.SetOutput(&buf)
+	klog.LogToStderr(false)
+	t.Cleanup(func() {
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	})
+
+	res := types.NewScanResult().SetPath("/bin/foo").SetError(types.ErrNotDynLinked)
+	logWalkResult(&types.Config{Quiet: true}, res)
+	klog.Flush()
+	assert.Empty(t, buf.String())
+
+	logWalkResult(&types.Config{}, res)
+	klog.Flush()
+	assert.Contains(t, buf.String(), `"scanning failed" image="" path="/bin/foo"`)
+}
+
+func TestWalkDirScanSampleLargest(t *testing.T) {
+	root := t.TempDir()
+	for name, size := range map[string]int{"small": 10, "big": 1000, "medium": 100, "medium2": 100} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), make([]byte, size), 0o755))
+	}
+	sampledOut.Store(0)
+	defer sampledOut.Store(0)
+
+	cfg := &types.Config{DryRun: true, SampleLargest: 3}
+	var got []string
+	for _, res := range walkDirScan(context.Background(), cfg, nil, nil, root, nil, 1).Items {
+		got = append(got, res.Path)
+	}
+	assert.Equal(t, []string{"/big", "/medium", "/medium2"}, got)
+	assert.EqualValues(t, 1, sampledOut.Load())
+
+	// Nothing is left out if there are no more files than the sample size.
+	cfg.SampleLargest = 4
+	assert.Len(t, walkDirScan(context.Background(), cfg, nil, nil, root, nil, 1).Items, 4)
+	assert.EqualValues(t, 1, sampledOut.Load())
+}
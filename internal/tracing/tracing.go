@@ -0,0 +1,420 @@
+// Package tracing implements optional OpenTelemetry tracing of scans.
+// The spans are exported to an OTLP/HTTP collector, using the JSON
+// encoding. Unless Setup is called, tracing is disabled, and starting
+// a span is a no-op.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	serviceName = "check-payload"
+	// batchSize is the number of ended spans to export at once.
+	batchSize = 512
+	// maxQueuedBatches is the number of batches waiting to be exported,
+	// above which the new batches are dropped (if the collector is slow,
+	// or unreachable).
+	maxQueuedBatches = 8
+	// tracesPath is the default OTLP/HTTP traces endpoint path.
+	tracesPath = "/v1/traces"
+)
+
+// OTLP span kind and status codes.
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// Span attribute keys.
+const (
+	AttrArch    = "check_payload.arch"
+	AttrImage   = "check_payload.image"
+	AttrImages  = "check_payload.images" // The number of images.
+	AttrKind    = "check_payload.kind"
+	AttrPath    = "check_payload.path"
+	AttrPayload = "check_payload.payload"
+	AttrRoot    = "check_payload.root"
+	AttrStatus  = "check_payload.status"
+	AttrTag     = "check_payload.tag"
+)
+
+// exp is the span exporter, nil if tracing is disabled.
+var exp atomic.Pointer[exporter]
+
+// Setup enables tracing, with the spans exported to the OTLP/HTTP
+// collector at endpoint (such as "http://localhost:4318"; if there is
+// no path, "/v1/traces" is used). The version is reported as the
+// service version. Additional HTTP headers (such as for authentication)
+// can be set via OTEL_EXPORTER_OTLP_HEADERS environment variable, in
+// "key1=value1,key2=value2" form.
+func Setup(endpoint, version string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("endpoint %q: want http or https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = tracesPath
+	}
+	headers, err := parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+	}
+	resource := []Attr{String("service.name", serviceName)}
+	if version != "" {
+		resource = append(resource, String("service.version", version))
+	}
+	e := &exporter{
+		url:      u.String(),
+		headers:  headers,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		resource: resource,
+		queue:    make(chan exportItem, maxQueuedBatches),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	exp.Store(e)
+	klog.V(1).InfoS("exporting traces", "endpoint", e.url)
+	return nil
+}
+
+// Flush exports the spans ended so far, and waits for the export to
+// finish (or ctx to be done). It is a no-op if tracing is disabled.
+// Export errors are logged rather than returned; an error is only
+// returned if ctx is done first.
+func Flush(ctx context.Context) error {
+	e := exp.Load()
+	if e == nil {
+		return nil
+	}
+	flushed := make(chan struct{})
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	item := exportItem{spans: e.spans, flushed: flushed}
+	e.spans = nil
+	select {
+	case e.queue <- item:
+	case <-ctx.Done():
+		e.mu.Unlock()
+		return ctx.Err()
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the remaining spans (waiting for the exports in
+// progress to finish, until ctx is done), and disables tracing. It is
+// a no-op if tracing is disabled. Export errors are logged rather than
+// returned; an error is only returned if ctx is done first.
+func Shutdown(ctx context.Context) error {
+	e := exp.Swap(nil)
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	var err error
+	if len(e.spans) > 0 {
+		select {
+		case e.queue <- exportItem{spans: e.spans}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		e.spans = nil
+	}
+	e.closed = true
+	close(e.queue)
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-e.done:
+		if n := e.dropped.Load(); n > 0 {
+			klog.Warningf("can't export traces: %d spans dropped, as the export queue was full", n)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseHeaders parses the headers in "key1=value1,key2=value2" form.
+func parseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("want key=value, got %q", kv)
+		}
+		if uv, err := url.QueryUnescape(strings.TrimSpace(v)); err == nil {
+			v = uv
+		}
+		headers[k] = v
+	}
+	return headers, nil
+}
+
+// Attr is a span attribute.
+type Attr struct {
+	Key   string    `json:"key"`
+	Value attrValue `json:"value"`
+}
+
+type attrValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"` // int64 is a string in OTLP JSON.
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: attrValue{StringValue: &value}}
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: attrValue{IntValue: strconv.Itoa(value)}}
+}
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: attrValue{BoolValue: &value}}
+}
+
+// Span is a traced operation. All its methods are no-ops for a nil span,
+// which is returned by Start if tracing is disabled.
+type Span struct {
+	exp      *exporter
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+	attrs    []Attr
+	err      error
+}
+
+type spanKey struct{}
+
+// Start starts a span with a given name, which is a child of the span
+// in ctx, if any. The returned context contains the new span. The span
+// must be ended by calling End.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	e := exp.Load()
+	if e == nil {
+		return ctx, nil
+	}
+	s := &Span{exp: e, name: name, start: time.Now(), attrs: attrs}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError sets the span status to error, if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err
+}
+
+// End ends the span, and queues it for export.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	js := jsonSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:        s.attrs,
+	}
+	if s.parentID != [8]byte{} {
+		js.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if s.err != nil {
+		js.Status = &jsonStatus{Code: statusCodeError, Message: s.err.Error()}
+	}
+	s.exp.add(js)
+}
+
+// OTLP/HTTP JSON request, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []Attr `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []jsonSpan `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	jsonSpan struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		ParentSpanID      string      `json:"parentSpanId,omitempty"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []Attr      `json:"attributes,omitempty"`
+		Status            *jsonStatus `json:"status,omitempty"`
+	}
+	jsonStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// exporter batches the ended spans, and exports them to the collector,
+// one batch at a time, in the background (see run).
+type exporter struct {
+	url      string
+	headers  map[string]string
+	client   *http.Client
+	resource []Attr
+
+	// queue is the batches to export, closed by Shutdown.
+	queue chan exportItem
+	// done is closed when all the batches are exported.
+	done    chan struct{}
+	dropped atomic.Int64 // The number of spans dropped.
+
+	mu     sync.Mutex
+	spans  []jsonSpan
+	closed bool
+}
+
+// exportItem is a batch of spans to export. If flushed is not nil, it is
+// closed once the batch (and all the previous ones) are exported.
+type exportItem struct {
+	spans   []jsonSpan
+	flushed chan struct{}
+}
+
+// add queues the span for export. The spans ended after Shutdown
+// (such as those of binary scans abandoned on timeout) are dropped,
+// and so are the batches which don't fit into the export queue.
+func (e *exporter) add(s jsonSpan) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	e.spans = append(e.spans, s)
+	if len(e.spans) >= batchSize {
+		select {
+		case e.queue <- exportItem{spans: e.spans}:
+		default:
+			e.dropped.Add(int64(len(e.spans)))
+		}
+		e.spans = nil
+	}
+}
+
+// run exports the queued batches, until the queue is closed. Errors are
+// logged, as tracing is not to fail the scan.
+func (e *exporter) run() {
+	defer close(e.done)
+	for item := range e.queue {
+		if len(item.spans) > 0 {
+			if err := e.export(item.spans); err != nil {
+				klog.Warningf("can't export traces: %v", err)
+			}
+		}
+		if item.flushed != nil {
+			close(item.flushed)
+		}
+	}
+}
+
+func (e *exporter) export(spans []jsonSpan) error {
+	data, err := json.Marshal(&exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: e.resource},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: serviceName}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", e.url, resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDisabled(t *testing.T) {
+	ctx := context.Background()
+	ctx2, span := Start(ctx, "test", String(AttrPath, "/bin/foo"))
+	if span != nil || ctx2 != ctx {
+		t.Fatal("want no span when tracing is disabled")
+	}
+	// Must not panic.
+	span.SetAttributes(String(AttrStatus, "success"))
+	span.SetError(errors.New("error"))
+	span.End()
+	if err := Shutdown(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []exportRequest
+		headers  []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req exportRequest
+		if r.URL.Path != tracesPath || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		headers = append(headers, r.Header.Get("Authorization"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20xyz")
+	if err := Setup(srv.URL, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := Start(context.Background(), "scan image", String(AttrImage, "quay.io/foo/bar:latest"))
+	_, child := Start(ctx, "validate", String(AttrPath, "/bin/foo"))
+	child.SetAttributes(String(AttrStatus, "failed"))
+	child.SetError(errors.New("not dynamically linked"))
+	child.End()
+	parent.End()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Spans ended after Shutdown are dropped.
+	_, late := Start(context.Background(), "late")
+	if late != nil {
+		t.Error("want no span after Shutdown")
+	}
+
+	if len(requests) != 1 || len(requests[0].ResourceSpans) != 1 {
+		t.Fatalf("want 1 export request, got %+v", requests)
+	}
+	if headers[0] != "Bearer xyz" {
+		t.Errorf("Authorization header: got %q", headers[0])
+	}
+	rs := requests[0].ResourceSpans[0]
+	if want := []Attr{String("service.name", serviceName), String("service.version", "v1")}; !reflect.DeepEqual(rs.Resource.Attributes, want) {
+		t.Errorf("resource attributes: got %+v", rs.Resource.Attributes)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.Name != "validate" || p.Name != "scan image" {
+		t.Errorf("span names: got %q, %q", c.Name, p.Name)
+	}
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" {
+		t.Errorf("want %+v to be a child of %+v", c, p)
+	}
+	if len(p.TraceID) != 32 || len(p.SpanID) != 16 {
+		t.Errorf("bad ids: %q, %q", p.TraceID, p.SpanID)
+	}
+	if want := []Attr{String(AttrPath, "/bin/foo"), String(AttrStatus, "failed")}; !reflect.DeepEqual(c.Attributes, want) {
+		t.Errorf("child attributes: got %+v", c.Attributes)
+	}
+	if c.Status == nil || c.Status.Code != statusCodeError || c.Status.Message != "not dynamically linked" || p.Status != nil {
+		t.Errorf("bad status: %+v, %+v", c.Status, p.Status)
+	}
+}
+
+func TestExportQueue(t *testing.T) {
+	var mu sync.Mutex
+	exported := 0
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var req exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		exported += len(req.ResourceSpans[0].ScopeSpans[0].Spans)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	if err := Setup(srv.URL, ""); err != nil {
+		t.Fatal(err)
+	}
+	// With the collector stuck, maxQueuedBatches are queued (and
+	// one more is being exported, if already taken from the queue);
+	// the others are dropped.
+	const batches = maxQueuedBatches + 3
+	for i := 0; i < batches*batchSize; i++ {
+		_, span := Start(context.Background(), "validate")
+		span.End()
+	}
+	_, span := Start(context.Background(), "last")
+	span.End()
+	e := exp.Load()
+	if n := e.dropped.Load(); n < 2*batchSize || n > 3*batchSize || n%batchSize != 0 {
+		t.Errorf("want 2 or 3 batches dropped, got %d spans", n)
+	}
+
+	close(release)
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if want := batches*batchSize + 1 - int(e.dropped.Load()); exported != want {
+		t.Errorf("want %d spans exported, got %d", want, exported)
+	}
+	mu.Unlock()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4318", "ftp://localhost", "http://", ":"} {
+		if err := Setup(endpoint, ""); err == nil {
+			t.Errorf("%q: want error", endpoint)
+		}
+	}
+	if err := Setup("https://collector.example.com/otlp/v1/traces", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Shutdown(context.Background()) })
+	if got := exp.Load().url; got != "https://collector.example.com/otlp/v1/traces" {
+		t.Errorf("got url %q", got)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "foo")
+	if err := Setup("http://localhost:4318", ""); err == nil {
+		t.Error("want error for bad headers")
+	}
+}
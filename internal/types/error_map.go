@@ -3,14 +3,27 @@
 package types
 
 var KnownErrors = map[string]error {
+	"ErrCryptoRunpath": ErrCryptoRunpath,
+	"ErrExecStack": ErrExecStack,
+	"ErrFIPSModuleIntegrity": ErrFIPSModuleIntegrity,
+	"ErrGoBundledOpenssl": ErrGoBundledOpenssl,
+	"ErrGoCryptoBackend": ErrGoCryptoBackend,
 	"ErrGoInvalidTag": ErrGoInvalidTag,
 	"ErrGoMissingSymbols": ErrGoMissingSymbols,
 	"ErrGoMissingTag": ErrGoMissingTag,
 	"ErrGoNoCgoInit": ErrGoNoCgoInit,
+	"ErrGoNoFIPSInit": ErrGoNoFIPSInit,
 	"ErrGoNoTags": ErrGoNoTags,
 	"ErrGoNotCgoEnabled": ErrGoNotCgoEnabled,
 	"ErrLibcryptoMany": ErrLibcryptoMany,
 	"ErrLibcryptoMissing": ErrLibcryptoMissing,
 	"ErrLibcryptoSoMissing": ErrLibcryptoSoMissing,
+	"ErrNoRelro": ErrNoRelro,
+	"ErrNoStackCanary": ErrNoStackCanary,
 	"ErrNotDynLinked": ErrNotDynLinked,
+	"ErrPyExtBundledOpenssl": ErrPyExtBundledOpenssl,
+	"ErrRPMOrphan": ErrRPMOrphan,
+	"ErrRustCrypto": ErrRustCrypto,
+	"ErrSetuid": ErrSetuid,
+	"ErrWorldWritable": ErrWorldWritable,
 }
@@ -7,14 +7,27 @@ import "errors"
 // Well-known errors returned by scan. If you modify this list,
 // do not forget to run 'go generate'.
 var (
-	ErrGoInvalidTag       = errors.New("go binary has invalid build tag(s) set")
-	ErrGoMissingSymbols   = errors.New("go binary does not contain required symbol(s)")
-	ErrGoMissingTag       = errors.New("go binary does not contain required tag(s)")
-	ErrGoNoCgoInit        = errors.New("x_cgo_init not found")
-	ErrGoNoTags           = errors.New("go binary has no build tags set (should have strictfipsruntime)")
-	ErrGoNotCgoEnabled    = errors.New("go binary is not CGO_ENABLED")
-	ErrLibcryptoMany      = errors.New("openssl: found multiple different libcrypto versions")
-	ErrLibcryptoMissing   = errors.New("openssl: did not find libcrypto library within binary")
-	ErrLibcryptoSoMissing = errors.New("could not find dependent openssl version within container image")
-	ErrNotDynLinked       = errors.New("executable is not dynamically linked")
+	ErrCryptoRunpath       = errors.New("binary depends on libcrypto or libssl, and its RPATH or RUNPATH points outside of system library directories")
+	ErrExecStack           = errors.New("executable has an executable stack (no NX)")
+	ErrFIPSModuleIntegrity = errors.New("openssl FIPS module integrity metadata is missing, malformed, or does not match the module")
+	ErrGoBundledOpenssl    = errors.New("go binary contains its own copy of openssl, rather than using the system one")
+	ErrGoCryptoBackend     = errors.New("go binary uses a crypto backend which is not allowed")
+	ErrGoInvalidTag        = errors.New("go binary has invalid build tag(s) set")
+	ErrGoMissingSymbols    = errors.New("go binary does not contain required symbol(s)")
+	ErrGoMissingTag        = errors.New("go binary does not contain required tag(s)")
+	ErrGoNoCgoInit         = errors.New("x_cgo_init not found")
+	ErrGoNoFIPSInit        = errors.New("go binary is built with FIPS support, but does not contain the FIPS initialization code")
+	ErrGoNoTags            = errors.New("go binary has no build tags set (should have strictfipsruntime)")
+	ErrGoNotCgoEnabled     = errors.New("go binary is not built with CGO_ENABLED=1 (cgo is disabled)")
+	ErrLibcryptoMany       = errors.New("openssl: found multiple different libcrypto versions")
+	ErrLibcryptoMissing    = errors.New("openssl: did not find libcrypto library within binary")
+	ErrLibcryptoSoMissing  = errors.New("could not find dependent openssl version within container image")
+	ErrNoRelro             = errors.New("executable is not built with full RELRO")
+	ErrNoStackCanary       = errors.New("executable is not built with stack canaries")
+	ErrNotDynLinked        = errors.New("executable is not dynamically linked")
+	ErrPyExtBundledOpenssl = errors.New("python extension module contains its own copy of openssl, rather than using the system one")
+	ErrRPMOrphan           = errors.New("file is not owned by any rpm package")
+	ErrRustCrypto          = errors.New("rust binary uses its own crypto crate(s), rather than the system openssl")
+	ErrSetuid              = errors.New("executable has setuid or setgid bit set")
+	ErrWorldWritable       = errors.New("file is world-writable")
 )
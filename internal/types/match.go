@@ -0,0 +1,102 @@
+package types
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// regexpPrefix is the prefix of filter_files entries which are
+// regular expressions.
+const regexpPrefix = "re:"
+
+// regexps caches compiled regular expressions, so every one is only
+// compiled once. Maps an expression to *regexp.Regexp (nil if invalid).
+var regexps sync.Map
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexps.Store(expr, re)
+	return re, nil
+}
+
+// isGlob tells if the entry is a shell pattern (see path.Match).
+func isGlob(entry string) bool {
+	return strings.ContainsAny(entry, `*?[`)
+}
+
+// isFileMatch tells if the file path matches one of the entries. Each entry
+// is one of:
+//   - a regular expression, if it has "re:" prefix (unanchored, so it
+//     matches any part of the path unless ^ and/or $ are used);
+//   - a shell pattern, if it contains any of *, ?, or [ (if the pattern has
+//     no slashes, it is matched against the file name only, otherwise
+//     against the whole path);
+//   - a literal path.
+//
+// An entry is always compared literally first, so an entry such as
+// "/usr/bin/[" matches the file with this very name.
+func isFileMatch(file string, entries []string) bool {
+	_, ok := fileMatch(file, entries)
+	return ok
+}
+
+// fileMatch is like isFileMatch, but also returns the matching entry.
+func fileMatch(file string, entries []string) (string, bool) {
+	for _, e := range entries {
+		if e == file {
+			return e, true
+		}
+		if strings.HasPrefix(e, regexpPrefix) {
+			re, err := compileRegexp(strings.TrimPrefix(e, regexpPrefix))
+			if err == nil && re.MatchString(file) {
+				return e, true
+			}
+			continue
+		}
+		if !isGlob(e) {
+			continue
+		}
+		name := file
+		if !strings.Contains(e, "/") {
+			name = path.Base(file)
+		}
+		if ok, _ := path.Match(e, name); ok {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// isDirMatch tells if the file is under one of the directories. A directory
+// entry can also be a shell pattern (such as /usr/lib/python3.*), which is
+// matched against every parent directory of the file.
+func isDirMatch(file string, dirs []string) bool {
+	_, ok := dirMatch(file, dirs)
+	return ok
+}
+
+// dirMatch is like isDirMatch, but also returns the matching entry.
+func dirMatch(file string, dirs []string) (string, bool) {
+	for _, d := range dirs {
+		if strings.HasPrefix(file, d+"/") {
+			return d, true
+		}
+		if !isGlob(d) {
+			continue
+		}
+		for dir := path.Dir(file); dir != "/" && dir != "."; dir = path.Dir(dir) {
+			if ok, _ := path.Match(d, dir); ok {
+				return d, true
+			}
+		}
+	}
+	return "", false
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"html/template"
+	"sync"
 	"time"
 
 	v1 "github.com/openshift/api/image/v1"
@@ -8,48 +10,168 @@ import (
 )
 
 type Config struct {
+	AllArches               bool          `json:"all_arches"`
+	Arch                    string        `json:"arch"`
+	ArchiveMaxDepth         int           `json:"archive_max_depth"`
+	ArchiveMaxSize          int64         `json:"archive_max_size"`
+	AuthFile                string        `json:"auth_file"`
+	CacheDir                string        `json:"cache_dir"`
+	CacheMaxSize            int64         `json:"cache_max_size"`
+	Checks                  []string      `json:"checks"`
+	Color                   bool          `json:"color"`
+	Compress                bool          `json:"compress"`
 	Components              []string      `json:"components"`
+	CredentialHelpers       []string      `json:"credential_helpers"`
+	Dedupe                  bool          `json:"dedupe"`
+	DryRun                  bool          `json:"dry_run"`
+	FailFast                bool          `json:"fail_fast"`
+	FailOnSeverity          Severity      `json:"fail_on_severity"`
 	FailOnWarnings          bool          `json:"fail_on_warnings"`
-	FilterFile              string        `json:"filter_file"`
+	FilterFile              string        `json:"filter_file"` // A file with additional FilterFiles entries.
+	FollowSymlinks          bool          `json:"follow_symlinks"`
+	FromArchive             string        `json:"from_archive"`
+	FromArchiveTransport    string        `json:"-"` // The podman transport of FromArchive, if known (see podman.ArchiveTransport).
 	FromFile                string        `json:"from_file"`
+	FromMapping             string        `json:"from_mapping"`
 	FromURL                 string        `json:"from_url"`
+	HTMLTemplateFile        string        `json:"html_template_file"`
+	HTTPProxy               string        `json:"http_proxy"`
+	HTTPSProxy              string        `json:"https_proxy"`
 	InsecurePull            bool          `json:"insecure_pull"`
+	KeepTemp                bool          `json:"keep_temp"`
 	Limit                   int           `json:"limit"`
+	ModifiedSince           time.Time     `json:"modified_since"`
+	NoCache                 bool          `json:"no_cache"`
+	NoProxy                 string        `json:"no_proxy"`
+	OnlyFailures            bool          `json:"only_failures"`
+	OnlyWarnings            bool          `json:"only_warnings"`
 	ContainerImageComponent string        `json:"container_image_component"`
 	ContainerImage          string        `json:"container_image"`
+	ContainerImages         []string      `json:"container_images"`
+	OutputDir               string        `json:"output_dir"`
 	OutputFile              string        `json:"output_file"`
 	OutputFormat            string        `json:"output_format"`
 	Parallelism             int           `json:"parallelism"`
+	PerBinaryTimeout        time.Duration `json:"per_binary_timeout"`
+	PreviousImage           string        `json:"previous_image"`
+	PreviousReport          string        `json:"previous_report"`
 	PrintExceptions         bool          `json:"print_exceptions"`
+	ProgressInterval        time.Duration `json:"progress_interval"`
+	ReportDuplicates        bool          `json:"report_duplicates"`
+	ReportUnusedExceptions  bool          `json:"report_unused_exceptions"`
+	PullParallelism         int           `json:"pull_parallelism"`
+	PullRetries             int           `json:"pull_retries"`
 	PullSecret              string        `json:"pull_secret"`
+	Quiet                   bool          `json:"quiet"`
+	ResumeFile              string        `json:"resume_file"`
+	SampleLargest           int           `json:"sample_largest"` // Only scan the N largest files of each image (or root).
+	ScanArchives            bool          `json:"scan_archives"`
+	SummaryOnly             bool          `json:"summary_only"`
 	TimeLimit               time.Duration `json:"time_limit"`
 	Verbose                 bool          `json:"verbose"`
 	UseRPMScan              bool          `json:"use_rpm_scan"`
 
+	// Metadata, if set, is included into the report (see --metadata).
+	Metadata *ReportMetadata `json:"-"`
+	// HTMLTemplate, if set, is used to render the html report
+	// (see --html-template).
+	HTMLTemplate *template.Template `json:"-"`
+	// OnResult, if set, is called with every scan result as soon as it is
+	// available. The result is still kept, and returned by the scan when
+	// it is done. It may be called from multiple goroutines at once.
+	OnResult func(*ScanResult) `json:"-"`
+	// TempDir, if set, is the directory for podman temporary files (such
+	// as image layers being pulled), created for the scan.
+	TempDir string `json:"-"`
+
 	ConfigFile
 }
 
+// ReportMetadata describes how the report was produced (see --metadata).
+type ReportMetadata struct {
+	// Version is the check-payload version (git commit).
+	Version string `json:"version"`
+	// ConfigFile is the config file used ("embedded" for the embedded one),
+	// or a comma-separated list of files, if several are used.
+	ConfigFile       string `json:"config_file"`
+	ConfigForVersion string `json:"config_for_version,omitempty"`
+	// Command is the check-payload command run, such as "check-payload scan payload".
+	Command string `json:"command"`
+	// Flags are the command-line flags set, in "--name=value" form,
+	// with credentials redacted.
+	Flags []string `json:"flags,omitempty"`
+	// Target is the release payload, or images scanned.
+	Target string `json:"target,omitempty"`
+	// Time is the time the scan started.
+	Time time.Time `json:"time"`
+}
+
 // ConfigFile is a part of Config. It contains fields that can be set via a
 // configuration files.
 type ConfigFile struct {
 	FilterFiles  []string `json:"filter_files" toml:"filter_files"`
 	FilterDirs   []string `json:"filter_dirs" toml:"filter_dirs"`
 	FilterImages []string `json:"filter_images" toml:"filter_images"`
+	FilterRPMs   []string `json:"filter_rpms" toml:"filter_rpms"`
+
+	// IncludeFiles and IncludeDirs, if any is set, restrict the scan
+	// to matching files only (the filters are applied afterwards).
+	IncludeFiles []string `json:"include_files" toml:"include_files"`
+	IncludeDirs  []string `json:"include_dirs" toml:"include_dirs"`
 
-	PayloadIgnores map[string]IgnoreLists `toml:"payload"`
-	TagIgnores     map[string]IgnoreLists `toml:"tag"`
-	RPMIgnores     map[string]IgnoreLists `toml:"rpm"`
+	PayloadIgnores map[string]IgnoreLists `json:"payload" toml:"payload"`
+	TagIgnores     map[string]IgnoreLists `json:"tag" toml:"tag"`
+	RPMIgnores     map[string]IgnoreLists `json:"rpm" toml:"rpm"`
 	ErrIgnores     ErrIgnoreList          `json:"ignore" toml:"ignore"`
+
+	// Required are [[required]] sections, listing the paths no
+	// exception applies to (see RefuseException).
+	Required []RequiredPaths `json:"required" toml:"required"`
+
+	// GoCryptoBackends are the allowed go crypto backends
+	// (see the go-crypto-backend check). If empty, any is allowed.
+	GoCryptoBackends []string `json:"go_crypto_backends" toml:"go_crypto_backends"`
+
+	// RegistryMirrors are image reference rewrite rules, in the
+	// "source=mirror" form (see MirrorImage).
+	RegistryMirrors []string `json:"registry_mirrors" toml:"registry_mirrors"`
+
+	// Severities remap the default severities of validations
+	// (validation name to severity, see list-checks).
+	Severities map[string]Severity `json:"severity" toml:"severity"`
+
+	// ComponentOverrides are [[component]] sections.
+	ComponentOverrides []ComponentOverride `json:"component" toml:"component"`
+}
+
+// ComponentOverride overrides validations for binaries of a particular
+// OpenShift component.
+type ComponentOverride struct {
+	// Name is the component name (same as in [payload.<name>]).
+	Name string `json:"name" toml:"name"`
+	// DisableChecks are names of validations (see list-checks)
+	// which are not run for the component.
+	DisableChecks []string      `json:"disable_checks" toml:"disable_checks"`
+	ErrIgnores    ErrIgnoreList `json:"ignore" toml:"ignore"`
 }
 
 type ErrIgnore struct {
-	Error KnownError `toml:"error"`
-	Files []string   `toml:"files"`
-	Dirs  []string   `toml:"dirs"`
+	Error KnownError `json:"error" toml:"error"`
+	Files []string   `json:"files" toml:"files"`
+	Dirs  []string   `json:"dirs" toml:"dirs"`
 }
 
 type ErrIgnoreList []ErrIgnore
 
+// RequiredPaths is a [[required]] section. The files (with the same syntax
+// as filter_files) and dirs (with the same syntax as the dirs of [[ignore]])
+// must always pass the checks: failures there are never excepted, and
+// warnings there are failures.
+type RequiredPaths struct {
+	Files []string `json:"files" toml:"files"`
+	Dirs  []string `json:"dirs" toml:"dirs"`
+}
+
 type IgnoreLists struct {
 	FilterFiles []string      `json:"filter_files" toml:"filter_files"`
 	FilterDirs  []string      `json:"filter_dirs" toml:"filter_dirs"`
@@ -68,10 +190,53 @@ type ScanResult struct {
 	Path      string
 	Skip      bool
 	Error     *ValidationError
+	// SkipReason tells why the file was skipped, if it is not
+	// evident (such as a core dump).
+	SkipReason string
+	// SymlinkTarget is the path the skipped symlink resolves to (see
+	// rpm scans without --follow-symlinks).
+	SymlinkTarget string
+	// Exceptions are descriptions of config rules which suppressed
+	// the validation errors, or filtered out the binary.
+	Exceptions []string
+	// Kind is the kind of the scanned binary, i.e. "go", "exe", or
+	// "pyext" (a python extension module). Empty for skipped files.
+	Kind string
+	// SHA256 is a hex-encoded SHA-256 digest of the scanned binary.
+	SHA256 string
+	// GoBuildInfo is only set for go binaries.
+	GoBuildInfo *GoBuildInfo
+	// Arch is the image architecture, only set for --all-arches scans.
+	Arch string
+	// Severity is the severity of the failed check (see Config.Severities).
+	Severity Severity
+	// DuplicatePaths are the other paths of the same binary (in the same
+	// image, and with the same status), collapsed into this result in the
+	// report (see --dedupe).
+	DuplicatePaths []string
+}
+
+// GoBuildInfo is a subset of build information embedded into a go binary.
+type GoBuildInfo struct {
+	GoVersion   string `json:"go_version"`
+	VCSRevision string `json:"vcs_revision,omitempty"`
+	// Settings are the relevant build settings, such as CGO_ENABLED or -tags.
+	Settings map[string]string `json:"settings,omitempty"`
+	// CryptoBackend is the crypto backend detected (such as
+	// "openssl-fips", or "none"), if the binary is using crypto.
+	CryptoBackend string `json:"crypto_backend,omitempty"`
 }
 
 type ScanResults struct {
+	mu    sync.Mutex
 	Items []*ScanResult
+	// Tag is the image scanned (nil for non-image scans).
+	Tag *v1.TagReference
+	// Arch is the image architecture, only set for --all-arches scans.
+	Arch string
+	// Start and End is the time the scan started and ended
+	// (zero if unknown, e.g. for results restored by --resume).
+	Start, End time.Time
 }
 
 type OpenshiftComponent struct {
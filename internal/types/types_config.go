@@ -2,6 +2,9 @@ package types
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path"
 	"strings"
 
 	imagev1 "github.com/openshift/api/image/v1"
@@ -9,7 +12,86 @@ import (
 )
 
 func (c *Config) Log() {
-	klog.Infof("using config %+v", c)
+	// Make a shallow copy to hide the credentials.
+	cc := *c
+	cc.HTTPProxy = RedactProxy(c.HTTPProxy)
+	cc.HTTPSProxy = RedactProxy(c.HTTPSProxy)
+	klog.Infof("using config %+v", &cc)
+}
+
+// RedactProxy hides the user credentials in proxy URL, if any.
+func RedactProxy(proxy string) string {
+	at := strings.LastIndexByte(proxy, '@')
+	if at == -1 {
+		return proxy
+	}
+	scheme := ""
+	if i := strings.Index(proxy, "://"); i != -1 && i < at {
+		scheme = proxy[:i+3]
+	}
+	return scheme + "xxxxx" + proxy[at:]
+}
+
+// ProxyEnv returns the proxy environment variables to set for commands
+// accessing a registry (such as podman pull), as set by c.HTTPProxy,
+// c.HTTPSProxy, and c.NoProxy. Those take precedence over the
+// corresponding environment variables inherited from the parent process.
+func (c *Config) ProxyEnv() []string {
+	var env []string
+	for _, e := range []struct{ name, value string }{
+		{"HTTP_PROXY", c.HTTPProxy},
+		{"HTTPS_PROXY", c.HTTPSProxy},
+		{"NO_PROXY", c.NoProxy},
+	} {
+		if e.value != "" {
+			env = append(env, e.name+"="+e.value, strings.ToLower(e.name)+"="+e.value)
+		}
+	}
+	return env
+}
+
+// RegistryAuthFile returns the file with registry credentials to use for
+// pulling images and payload info: c.AuthFile, c.PullSecret, or the
+// REGISTRY_AUTH_FILE environment variable, whichever is set first.
+// An empty string means the tools' default is used.
+func (c *Config) RegistryAuthFile() string {
+	for _, file := range []string{c.AuthFile, c.PullSecret, os.Getenv("REGISTRY_AUTH_FILE")} {
+		if file != "" {
+			return file
+		}
+	}
+	return ""
+}
+
+// parseMirror parses the registry mirror rule in the "source=mirror" form.
+func parseMirror(rule string) (source, mirror string, ok bool) {
+	source, mirror, ok = strings.Cut(rule, "=")
+	source, mirror = strings.TrimSpace(source), strings.TrimSpace(mirror)
+	return source, mirror, ok && source != "" && mirror != ""
+}
+
+// MirrorImage rewrites the image reference according to c.RegistryMirrors,
+// similar to ImageContentSourcePolicy: if the image repository is the rule
+// source, or is under it, the source is replaced by the mirror, keeping
+// the rest of the reference (the tag or digest, and any sub-path) intact.
+// The rule with the longest matching source wins. The image is returned
+// as is if no rule matches.
+func (c *ConfigFile) MirrorImage(image string) string {
+	best, bestMirror := "", ""
+	for _, rule := range c.RegistryMirrors {
+		source, mirror, ok := parseMirror(rule)
+		if !ok || len(source) <= len(best) || !strings.HasPrefix(image, source) {
+			continue
+		}
+		// Only match at the path component boundary.
+		if rest := image[len(source):]; rest == "" || strings.ContainsRune("/:@", rune(rest[0])) {
+			best, bestMirror = source, mirror
+		}
+	}
+	if best == "" {
+		return image
+	}
+	return bestMirror + image[len(best):]
 }
 
 // isMatch tells if path equals to one of the entries.
@@ -27,7 +109,7 @@ func (c *Config) isFileIgnoredByComponent(path string, component *OpenshiftCompo
 		return false
 	}
 	if op, ok := c.PayloadIgnores[component.Component]; ok {
-		return isMatch(path, op.FilterFiles)
+		return isFileMatch(path, op.FilterFiles)
 	}
 	return false
 }
@@ -47,20 +129,27 @@ func (c *Config) isFileIgnoredByTag(path string, tag *imagev1.TagReference) bool
 		return false
 	}
 	if op, ok := c.TagIgnores[tag.Name]; ok {
-		return isMatch(path, op.FilterFiles)
+		return isFileMatch(path, op.FilterFiles)
 	}
 	return false
 }
 
-func (c *Config) isFileIgnoredByRPM(path string, rpm string) bool {
-	if op, ok := c.RPMIgnores[rpm]; ok {
-		return isMatch(path, op.FilterFiles)
+// ComponentOverride returns the [[component]] section for the component,
+// or nil if there is none.
+func (c *Config) ComponentOverride(component *OpenshiftComponent) *ComponentOverride {
+	if component == nil {
+		return nil
 	}
-	return false
+	for i := range c.ComponentOverrides {
+		if c.ComponentOverrides[i].Name == component.Component {
+			return &c.ComponentOverrides[i]
+		}
+	}
+	return nil
 }
 
 func (c *Config) IgnoreFile(path string) bool {
-	return isMatch(path, c.FilterFiles)
+	return isFileMatch(path, c.FilterFiles)
 }
 
 func (c *Config) IgnoreFileWithComponent(path string, component *OpenshiftComponent) bool {
@@ -76,13 +165,115 @@ func (c *Config) IgnoreFileWithTag(path string, tag *imagev1.TagReference) bool
 }
 
 func (c *Config) IgnoreFileByRpm(path string, rpm string) bool {
-	return c.isFileIgnoredByRPM(path, rpm)
+	return c.IgnoreFileByRpmRule(path, rpm) != ""
+}
+
+// IgnoreFileByRpmRule is like IgnoreFileByRpm, but returns a description of
+// the matching filter (such as `[rpm.foo] filter_files="/usr/bin/foo"`), or
+// an empty string if the file is not filtered out.
+func (c *Config) IgnoreFileByRpmRule(path string, rpm string) string {
+	if op, ok := c.RPMIgnores[rpm]; ok {
+		if f, ok := fileMatch(path, op.FilterFiles); ok {
+			return fmt.Sprintf("[rpm.%s] filter_files=%q", rpm, f)
+		}
+	}
+	return ""
+}
+
+// RequiredRule returns a description of the [[required]] entry matching
+// the path (such as `[[required]] files="/usr/bin/foo"`), or an empty
+// string if the path is not required.
+func (c *Config) RequiredRule(path string) string {
+	for _, r := range c.Required {
+		if d, ok := dirMatch(path, r.Dirs); ok {
+			return fmt.Sprintf("[[required]] dirs=%q", d)
+		}
+		if f, ok := fileMatch(path, r.Files); ok {
+			return fmt.Sprintf("[[required]] files=%q", f)
+		}
+	}
+	return ""
+}
+
+// RefuseException tells if the exception (rule, which matched the error
+// err at the path) is not to be applied, since the path is required (see
+// RequiredRule). It is to be called after an exception is found, and logs
+// the refusal.
+func (c *Config) RefuseException(path string, err error, rule string) bool {
+	required := c.RequiredRule(path)
+	if required == "" {
+		return false
+	}
+	klog.InfoS("exception refused for a required path", "path", path, "error", err, "rule", rule, "required", required)
+	return true
 }
 
 func (c *Config) IgnoreDirWithComponent(path string, component *OpenshiftComponent) bool {
 	return c.isDirIgnoredByComponent(path, component) || c.IgnoreDir(path)
 }
 
+// IsIncluded tells if the file is to be scanned according to c.IncludeFiles
+// and c.IncludeDirs. If both are empty, all files are included.
+func (c *Config) IsIncluded(path string) bool {
+	if len(c.IncludeFiles) == 0 && len(c.IncludeDirs) == 0 {
+		return true
+	}
+	for _, dir := range c.IncludeDirs {
+		if strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return isFileMatch(path, c.IncludeFiles)
+}
+
+// IsDirIncluded tells if the directory may contain files included according
+// to c.IncludeFiles and c.IncludeDirs, i.e. it is to be traversed.
+func (c *Config) IsDirIncluded(path string) bool {
+	if len(c.IncludeFiles) != 0 || len(c.IncludeDirs) == 0 {
+		// Patterns may match files in any directory.
+		return true
+	}
+	if path == "" || path == "/" {
+		// The root directory.
+		return true
+	}
+	for _, dir := range c.IncludeDirs {
+		// Either the directory is inside an included one,
+		// or an included one is inside it.
+		if path == dir || strings.HasPrefix(path, dir+"/") || strings.HasPrefix(dir, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsComponentSelected tells if the payload component (tag) with a given name
+// is to be scanned, i.e. c.Components is empty, or the name matches any of
+// its entries (shell patterns, such as cluster-*, are supported).
+func (c *Config) IsComponentSelected(name string) bool {
+	if len(c.Components) == 0 {
+		return true
+	}
+	for _, pattern := range c.Components {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreRPM checks if the rpm with the given name is to be ignored. The
+// c.FilterRPMs entries are shell patterns (see path.Match). The pattern that
+// matched is returned as well.
+func (c *Config) IgnoreRPM(name string) (string, bool) {
+	for _, pattern := range c.FilterRPMs {
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
 // IgnoreDirPrefix is similar to IgnoreDir. The difference is, this method
 // performs a a prefix match, meaning that "/a/b/c" path supplied will
 // return true if c.FilterDirs contains "/a" or "/a/b".
@@ -103,21 +294,28 @@ func (i ErrIgnoreList) Ignore(file string, err error) bool {
 		return false
 	}
 
-	for _, ie := range i {
+	return i.Match(file, err) != ""
+}
+
+// Match is like Ignore, but returns a description of the matching
+// exception (such as `error=ErrNotDynLinked files="/usr/bin/foo"`),
+// or an empty string if the error is not to be ignored. The matching
+// entry is marked as used (see UnusedExceptions).
+func (i ErrIgnoreList) Match(file string, err error) string {
+	for idx := range i {
+		ie := &i[idx]
 		if !errors.Is(err, ie.Error.Err) {
 			continue
 		}
-		for _, d := range ie.Dirs {
-			if strings.HasPrefix(file, d+"/") {
-				return true
-			}
+		if d, ok := dirMatch(file, ie.Dirs); ok {
+			markExceptionHit(ie, d)
+			return fmt.Sprintf("error=%s dirs=%q", ie.Error.Str, d)
 		}
-		for _, f := range ie.Files {
-			if file == f {
-				return true
-			}
+		if f, ok := fileMatch(file, ie.Files); ok {
+			markExceptionHit(ie, f)
+			return fmt.Sprintf("error=%s files=%q", ie.Error.Str, f)
 		}
 	}
 
-	return false
+	return ""
 }
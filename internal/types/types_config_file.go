@@ -1,7 +1,10 @@
 package types
 
 import (
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"go.uber.org/multierr"
@@ -13,15 +16,24 @@ import (
 // It returns errors and warnings; errors are considered fatal,
 // while warnings are more like FYI.
 func (c *ConfigFile) Validate() (err, warn error) {
-	validateFileList("filter_files", &err, c.FilterFiles)
+	validateFilterFileList("filter_files", &err, c.FilterFiles)
 	validateFileList("filter_dirs", &err, c.FilterDirs)
+	validateNonEmpty("filter_images", &err, c.FilterImages)
 	validateOverlaps("filter_", &warn, c.FilterFiles, c.FilterDirs)
+	validatePatternList("filter_rpms", &err, c.FilterRPMs)
+	validateFilterFileList("include_files", &err, c.IncludeFiles)
+	validateFileList("include_dirs", &err, c.IncludeDirs)
+	validateNonEmpty("go_crypto_backends", &err, c.GoCryptoBackends)
+	validateRegistryMirrors("registry_mirrors", &err, c.RegistryMirrors)
 
 	validateIgnoreLists("payload", &err, &warn, c.PayloadIgnores)
 	validateIgnoreLists("tag", &err, &warn, c.TagIgnores)
 	validateIgnoreLists("rpm", &err, &warn, c.RPMIgnores)
 
 	validateErrIgnores("[[ignore]]", &err, &warn, c.ErrIgnores)
+	validateRequired("[[required]]", &err, &warn, c.Required)
+
+	validateComponentOverrides(&err, &warn, c.ComponentOverrides)
 
 	return
 }
@@ -45,6 +57,15 @@ func (e *errNAbsPath) Error() string {
 	return `config entry ` + e.Listname + ` contains non-absolute path "` + e.Path + `"`
 }
 
+type errBadPattern struct {
+	Listname string
+	Pattern  string
+}
+
+func (e *errBadPattern) Error() string {
+	return `config entry ` + e.Listname + ` contains malformed pattern "` + e.Pattern + `"`
+}
+
 type errOverlap struct {
 	Listname string
 	Path     string
@@ -55,6 +76,57 @@ func (e *errOverlap) Error() string {
 	return `config entry ` + e.Listname + ` contains a redundant path "` + e.Path + `", overlapped by "` + e.By + `"`
 }
 
+type errEmptyEntry struct {
+	Listname string
+}
+
+func (e *errEmptyEntry) Error() string {
+	return `config entry ` + e.Listname + ` contains an empty value`
+}
+
+type errBadMirror struct {
+	Listname string
+	Mirror   string
+}
+
+func (e *errBadMirror) Error() string {
+	return `config entry ` + e.Listname + ` contains malformed mirror rule "` + e.Mirror + `" (should be "source=mirror")`
+}
+
+type errEmptyName struct {
+	Section string
+}
+
+func (e *errEmptyName) Error() string {
+	return `config section [` + e.Section + `.""] has an empty name`
+}
+
+type errUnknownError struct {
+	Section string
+	Name    string
+}
+
+func (e *errUnknownError) Error() string {
+	return `config entry ` + e.Section + ` has unknown error="` + e.Name + `" (known errors are: ` + strings.Join(knownErrorNames(), ", ") + `)`
+}
+
+func knownErrorNames() []string {
+	names := make([]string, 0, len(KnownErrors))
+	for name := range KnownErrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type errDupSection struct {
+	Section string
+}
+
+func (e *errDupSection) Error() string {
+	return `config section ` + e.Section + ` is duplicated`
+}
+
 type errEmpty struct {
 	Listname string
 	What     string
@@ -67,6 +139,10 @@ func (e *errEmpty) Error() string {
 // validateFileList checks that the paths in the list are clean and absolute.
 func validateFileList(listname string, perr *error, list []string) {
 	for _, f := range list {
+		if f == "" {
+			multierr.AppendInto(perr, &errEmptyEntry{listname})
+			continue
+		}
 		cf := filepath.Clean(f)
 		if f != cf {
 			multierr.AppendInto(perr, &errBadPath{listname, f, cf})
@@ -77,33 +153,140 @@ func validateFileList(listname string, perr *error, list []string) {
 	}
 }
 
+// validateFilterFileList is like validateFileList, but also allows
+// shell patterns and regular expressions (see isFileMatch).
+func validateFilterFileList(listname string, perr *error, list []string) {
+	for _, f := range list {
+		switch {
+		case f == "":
+			multierr.AppendInto(perr, &errEmptyEntry{listname})
+		case strings.HasPrefix(f, regexpPrefix):
+			if _, err := compileRegexp(strings.TrimPrefix(f, regexpPrefix)); err != nil {
+				multierr.AppendInto(perr, &errBadPattern{listname, f})
+			}
+		case isGlob(f):
+			if _, err := path.Match(f, ""); err != nil {
+				multierr.AppendInto(perr, &errBadPattern{listname, f})
+			} else if strings.Contains(f, "/") && f[0] != '/' {
+				multierr.AppendInto(perr, &errNAbsPath{listname, f})
+			}
+		default:
+			validateFileList(listname, perr, []string{f})
+		}
+	}
+}
+
+// validateDirList is like validateFileList, but also allows shell
+// patterns (see isDirMatch).
+func validateDirList(listname string, perr *error, list []string) {
+	for _, d := range list {
+		if isGlob(d) {
+			if _, err := path.Match(d, ""); err != nil {
+				multierr.AppendInto(perr, &errBadPattern{listname, d})
+				continue
+			}
+		}
+		validateFileList(listname, perr, []string{d})
+	}
+}
+
+// validatePatternList checks that the shell patterns in the list are valid.
+func validatePatternList(listname string, perr *error, list []string) {
+	for _, p := range list {
+		if p == "" {
+			multierr.AppendInto(perr, &errEmptyEntry{listname})
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			multierr.AppendInto(perr, &errBadPattern{listname, p})
+		}
+	}
+}
+
+// validateNonEmpty checks that the list has no empty values.
+func validateNonEmpty(listname string, perr *error, list []string) {
+	for _, v := range list {
+		if v == "" {
+			multierr.AppendInto(perr, &errEmptyEntry{listname})
+		}
+	}
+}
+
+func validateRegistryMirrors(listname string, perr *error, list []string) {
+	for _, v := range list {
+		if _, _, ok := parseMirror(v); !ok {
+			multierr.AppendInto(perr, &errBadMirror{listname, v})
+		}
+	}
+}
+
 func validateIgnoreLists(listname string, perr, pwarn *error, list map[string]IgnoreLists) {
-	for k, v := range list {
+	// Sort the keys, for the errors to be reported in a stable order.
+	keys := make([]string, 0, len(list))
+	for k := range list {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := list[k]
+		if k == "" {
+			multierr.AppendInto(perr, &errEmptyName{listname})
+		}
 		prefix := "[" + listname + "." + k
-		validateFileList(prefix+"].filter_files", perr, v.FilterFiles)
+		validateFilterFileList(prefix+"].filter_files", perr, v.FilterFiles)
 		validateFileList(prefix+"].filter_dirs", perr, v.FilterDirs)
 		validateOverlaps(prefix+"].filter_", pwarn, v.FilterFiles, v.FilterDirs)
 		validateErrIgnores("["+prefix+".ignore]]", perr, pwarn, v.ErrIgnores)
 	}
 }
 
+func validateComponentOverrides(perr, pwarn *error, list []ComponentOverride) {
+	seen := make(map[string]bool, len(list))
+	for _, v := range list {
+		if v.Name == "" {
+			multierr.AppendInto(perr, &errEmpty{"[[component]]", "name="})
+			continue
+		}
+		section := "[[component]] name=" + v.Name
+		if seen[v.Name] {
+			multierr.AppendInto(perr, &errDupSection{section})
+		}
+		seen[v.Name] = true
+		validateNonEmpty(section+" disable_checks", perr, v.DisableChecks)
+		validateErrIgnores(section+" [[component.ignore]]", perr, pwarn, v.ErrIgnores)
+	}
+}
+
 func validateErrIgnores(section string, perr, pwarn *error, l ErrIgnoreList) {
 	for _, v := range l {
 		// Make sure error is set.
 		if v.Error.Str == "" {
 			multierr.AppendInto(perr, &errEmpty{section, "error="})
+		} else if v.Error.Err == nil {
+			multierr.AppendInto(perr, &errUnknownError{section, v.Error.Str})
 		}
 		// Make sure files/dirs are not empty.
 		if len(v.Files)+len(v.Dirs) == 0 {
 			multierr.AppendInto(perr, &errEmpty{section, "files= nor dirs="})
 		}
 		prefix := section + ".error=" + v.Error.Str
-		validateFileList(prefix+".files", perr, v.Files)
-		validateFileList(prefix+".dirs", perr, v.Dirs)
+		validateFilterFileList(prefix+".files", perr, v.Files)
+		validateDirList(prefix+".dirs", perr, v.Dirs)
 		validateOverlaps(prefix+".", pwarn, v.Files, v.Dirs)
 	}
 }
 
+func validateRequired(section string, perr, pwarn *error, l []RequiredPaths) {
+	for _, v := range l {
+		if len(v.Files)+len(v.Dirs) == 0 {
+			multierr.AppendInto(perr, &errEmpty{section, "files= nor dirs="})
+		}
+		validateFilterFileList(section+".files", perr, v.Files)
+		validateDirList(section+".dirs", perr, v.Dirs)
+		validateOverlaps(section+".", pwarn, v.Files, v.Dirs)
+	}
+}
+
 func validateOverlaps(listname string, perr *error, files, dirs []string) {
 	// First, check that dirs do not overlap.
 	for i := range dirs {
@@ -126,22 +309,135 @@ func validateOverlaps(listname string, perr *error, files, dirs []string) {
 	}
 }
 
+// ExpandEnv replaces ${var} or $var in all the file and directory paths
+// (filter_files, filter_dirs, include_files, include_dirs, and files and
+// dirs of [[ignore]] entries, including those in the payload, tag, rpm,
+// and component sections, and of [[required]] entries) according to the values of the current
+// environment variables. It should be called before Validate.
+func (c *ConfigFile) ExpandEnv() {
+	expandEnvList(c.FilterFiles)
+	expandEnvList(c.FilterDirs)
+	expandEnvList(c.IncludeFiles)
+	expandEnvList(c.IncludeDirs)
+	for _, lists := range []map[string]IgnoreLists{c.PayloadIgnores, c.TagIgnores, c.RPMIgnores} {
+		for _, l := range lists {
+			expandEnvList(l.FilterFiles)
+			expandEnvList(l.FilterDirs)
+			expandEnvErrIgnores(l.ErrIgnores)
+		}
+	}
+	expandEnvErrIgnores(c.ErrIgnores)
+	for _, r := range c.Required {
+		expandEnvList(r.Files)
+		expandEnvList(r.Dirs)
+	}
+	for _, co := range c.ComponentOverrides {
+		expandEnvErrIgnores(co.ErrIgnores)
+	}
+}
+
+func expandEnvErrIgnores(l ErrIgnoreList) {
+	for _, ei := range l {
+		expandEnvList(ei.Files)
+		expandEnvList(ei.Dirs)
+	}
+}
+
+func expandEnvList(list []string) {
+	for i := range list {
+		list[i] = os.ExpandEnv(list[i])
+	}
+}
+
+// Add adds the entries from add to c. The [severity] entries already
+// set in c are kept (a different value in add is reported as an error).
 func (c *ConfigFile) Add(add *ConfigFile) error {
+	return c.add(add, false)
+}
+
+// Layer is like Add, but the [severity] entries from add override those
+// in c (which is still reported as an error). It is used to merge the
+// user's own config files, the later ones taking precedence.
+func (c *ConfigFile) Layer(add *ConfigFile) error {
+	return c.add(add, true)
+}
+
+func (c *ConfigFile) add(add *ConfigFile, override bool) error {
 	var err error
 
 	c.FilterFiles = appendUniq("filter_files", &err, c.FilterFiles, add.FilterFiles)
 	c.FilterDirs = appendUniq("filter_dirs", &err, c.FilterDirs, add.FilterDirs)
 	c.FilterImages = appendUniq("filter_images", &err, c.FilterImages, add.FilterImages)
+	c.FilterRPMs = appendUniq("filter_rpms", &err, c.FilterRPMs, add.FilterRPMs)
+	c.IncludeFiles = appendUniq("include_files", &err, c.IncludeFiles, add.IncludeFiles)
+	c.IncludeDirs = appendUniq("include_dirs", &err, c.IncludeDirs, add.IncludeDirs)
+	c.GoCryptoBackends = appendUniq("go_crypto_backends", &err, c.GoCryptoBackends, add.GoCryptoBackends)
+	c.RegistryMirrors = appendUniq("registry_mirrors", &err, c.RegistryMirrors, add.RegistryMirrors)
 
 	c.PayloadIgnores = mergeLists("payload", &err, c.PayloadIgnores, add.PayloadIgnores)
 	c.TagIgnores = mergeLists("tag", &err, c.TagIgnores, add.TagIgnores)
 	c.RPMIgnores = mergeLists("rpm", &err, c.RPMIgnores, add.RPMIgnores)
 
 	c.ErrIgnores = mergeErrIgnoreLists("[[ignore]]", &err, c.ErrIgnores, add.ErrIgnores)
+	// The [[required]] entries only add up, there's nothing to override.
+	c.Required = append(c.Required, add.Required...)
+
+	c.Severities = mergeSeverities("severity", &err, c.Severities, add.Severities, override)
+
+	c.ComponentOverrides = mergeComponentOverrides(&err, c.ComponentOverrides, add.ComponentOverrides)
 
 	return err
 }
 
+// mergeSeverities merges the severities. A different value in add is
+// reported as an error, and, if override is set, replaces the one in
+// main, otherwise, it is ignored.
+func mergeSeverities(listname string, perr *error, main, add map[string]Severity, override bool) map[string]Severity {
+	if main == nil && len(add) > 0 {
+		main = make(map[string]Severity, len(add))
+	}
+	// Sort the names, for the errors to be reported in a stable order.
+	names := make([]string, 0, len(add))
+	for name := range add {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		severity := add[name]
+		if s, ok := main[name]; ok && s != severity {
+			if !override {
+				multierr.AppendInto(perr, &errDup{listname, name + " = " + s.String()})
+				continue
+			}
+			multierr.AppendInto(perr, &errOverride{listname, name + " = " + s.String(), severity.String()})
+		}
+		main[name] = severity
+	}
+	return main
+}
+
+func mergeComponentOverrides(perr *error, main, add []ComponentOverride) []ComponentOverride {
+	for _, a := range add {
+		// See if the component is already in the list.
+		var found *ComponentOverride
+		for i := range main {
+			if main[i].Name == a.Name {
+				found = &main[i]
+				break
+			}
+		}
+		if found == nil {
+			main = append(main, a)
+			continue
+		}
+		section := "[[component]] name=" + a.Name
+		found.DisableChecks = appendUniq(section+" disable_checks", perr, found.DisableChecks, a.DisableChecks)
+		found.ErrIgnores = mergeErrIgnoreLists(section+" [[component.ignore]]", perr, found.ErrIgnores, a.ErrIgnores)
+	}
+
+	return main
+}
+
 type errDup struct {
 	Listname string
 	Dup      string
@@ -151,6 +447,16 @@ func (e *errDup) Error() string {
 	return "main config " + e.Listname + " already contains " + e.Dup
 }
 
+type errOverride struct {
+	Listname string
+	Old      string
+	New      string
+}
+
+func (e *errOverride) Error() string {
+	return "main config " + e.Listname + " " + e.Old + " is overridden by " + e.New
+}
+
 func contains(list []string, elem string) bool {
 	for _, e := range list {
 		if e == elem {
@@ -1,11 +1,14 @@
 package types_test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+	"sigs.k8s.io/yaml"
 
 	"github.com/openshift/check-payload/internal/types"
 )
@@ -27,6 +30,7 @@ const (
 	ex1 = `filter_files = [ "/some", "/files" ]
 filter_dirs = [ "/some", "/dirs" ]
 filter_images = [ "some", "images" ]
+filter_rpms = [ "some-rpm" ]
 
 [payload.one]
   filter_files  = [ "/one_file" ]
@@ -38,6 +42,7 @@ filter_images = [ "some", "images" ]
 	ex2 = `filter_files = [ "/more" ]
 filter_dirs = [ "/more" ]
 filter_images = [ "more" ]
+filter_rpms = [ "more-rpm*" ]
 
 [payload.two]
   filter_files = [ "/two" ]
@@ -50,6 +55,7 @@ filter_images = [ "more" ]
 	ex1ex2 = `filter_files = ["/some", "/files", "/more"]
 filter_dirs = [ "/some", "/dirs", "/more" ]
 filter_images = [ "some", "images", "more" ]
+filter_rpms = [ "some-rpm", "more-rpm*" ]
 
 [payload.one]
   filter_files  = [ "/one_file" ]
@@ -210,3 +216,220 @@ func TestConfigMerge(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateFilterFilePatterns(t *testing.T) {
+	// Valid patterns; decode validates the config.
+	_ = decode(t, `filter_files = [ "/usr/bin/a", "*.so", "/usr/lib*/lib?.so", "re:\\.test$" ]`)
+
+	bad := &types.ConfigFile{
+		FilterFiles: []string{"usr/lib/*.so", "[a", "re:(", "usr/bin/a"},
+	}
+	err, _ := bad.Validate()
+	assert.Len(t, multierr.Errors(err), 4)
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	var cfg types.ConfigFile
+	_, err := toml.Decode(`
+filter_files = [ "", "/a" ]
+filter_images = [ "" ]
+
+[payload.""]
+filter_files = [ "/x" ]
+
+[[payload.x.ignore]]
+error = "ErrNope"
+files = [ "/x" ]
+
+[[rpm.y.ignore]]
+error = "ErrNotDynLinked"
+files = [ "" ]
+`, &cfg)
+	require.NoError(t, err)
+
+	err, _ = cfg.Validate()
+	errs := multierr.Errors(err)
+	require.Len(t, errs, 5)
+	assert.Contains(t, errs[0].Error(), "filter_files")
+	assert.Contains(t, errs[1].Error(), "filter_images")
+	assert.Contains(t, errs[2].Error(), `[payload.""]`)
+	assert.Contains(t, errs[3].Error(), `"ErrNope"`)
+	assert.Contains(t, errs[4].Error(), "[[rpm.y.ignore]]")
+}
+
+func TestYAMLConfig(t *testing.T) {
+	exp := decode(t, ex1+`
+[[rpm.foo.ignore]]
+  error = "ErrNotDynLinked"
+  files = [ "/usr/bin/foo" ]
+`)
+	got := &types.ConfigFile{}
+	err := yaml.UnmarshalStrict([]byte(`
+filter_files: [/some, /files]
+filter_dirs: [/some, /dirs]
+filter_images: [some, images]
+filter_rpms: [some-rpm]
+payload:
+  one:
+    filter_files: [/one_file]
+    filter_dirs: [/one_dir]
+tag:
+  smth:
+    filter_files: [/smth_file1, /smth_file2]
+rpm:
+  foo:
+    ignore:
+      - error: ErrNotDynLinked
+        files: [/usr/bin/foo]
+`), got)
+	require.NoError(t, err)
+	assert.Equal(t, exp, got)
+
+	// Unknown keys are rejected.
+	err = yaml.UnmarshalStrict([]byte(`filter_file: [/a]`), got)
+	assert.Error(t, err)
+}
+
+func TestConfigEncodeRoundTrip(t *testing.T) {
+	exp := decode(t, ex1ex2+`
+[[ignore]]
+  error = "ErrNotDynLinked"
+  files = [ "/usr/bin/foo" ]
+`)
+	var buf bytes.Buffer
+	require.NoError(t, toml.NewEncoder(&buf).Encode(exp))
+	got := decode(t, buf.String())
+	assert.Equal(t, exp, got)
+}
+
+func TestComponentOverrides(t *testing.T) {
+	main := decode(t, `
+[[component]]
+  name = "foo-container"
+  disable_checks = [ "dyn-linked" ]
+
+  [[component.ignore]]
+    error = "ErrNotDynLinked"
+    files = [ "/usr/bin/foo" ]
+`)
+	add := decode(t, `
+[[component]]
+  name = "foo-container"
+  disable_checks = [ "go-tags" ]
+
+[[component]]
+  name = "bar-container"
+  disable_checks = [ "go-cgo" ]
+`)
+	require.NoError(t, main.Add(add))
+	require.Len(t, main.ComponentOverrides, 2)
+	assert.Equal(t, []string{"dyn-linked", "go-tags"}, main.ComponentOverrides[0].DisableChecks)
+	assert.Len(t, main.ComponentOverrides[0].ErrIgnores, 1)
+
+	cfg := &types.Config{ConfigFile: *main}
+	o := cfg.ComponentOverride(&types.OpenshiftComponent{Component: "bar-container"})
+	require.NotNil(t, o)
+	assert.Equal(t, []string{"go-cgo"}, o.DisableChecks)
+	assert.Nil(t, cfg.ComponentOverride(&types.OpenshiftComponent{Component: "baz"}))
+	assert.Nil(t, cfg.ComponentOverride(nil))
+
+	// Duplicate and empty names are errors.
+	bad := &types.ConfigFile{ComponentOverrides: []types.ComponentOverride{{Name: "a"}, {Name: "a"}, {}}}
+	err, _ := bad.Validate()
+	assert.Len(t, multierr.Errors(err), 2)
+}
+
+func TestRequired(t *testing.T) {
+	main := decode(t, `
+[[required]]
+  files = [ "/usr/bin/openssl" ]
+  dirs = [ "/usr/lib64/ossl-modules" ]
+`)
+	add := decode(t, `
+[[required]]
+  files = [ "*.so" ]
+`)
+	require.NoError(t, main.Add(add))
+	assert.Equal(t, []types.RequiredPaths{
+		{Files: []string{"/usr/bin/openssl"}, Dirs: []string{"/usr/lib64/ossl-modules"}},
+		{Files: []string{"*.so"}},
+	}, main.Required)
+
+	// Empty entries, and bad paths and patterns are errors.
+	bad := &types.ConfigFile{Required: []types.RequiredPaths{{}, {Files: []string{"usr/bin/foo"}, Dirs: []string{"/usr/lib["}}}}
+	err, _ := bad.Validate()
+	assert.Len(t, multierr.Errors(err), 3)
+}
+
+func TestConfigExpandEnv(t *testing.T) {
+	t.Setenv("CP_TEST_ROOT", "/opt/app")
+	t.Setenv("CP_TEST_BIN", "bin")
+	cfg := &types.ConfigFile{}
+	_, err := toml.Decode(`
+filter_files = [ "${CP_TEST_ROOT}/foo" ]
+filter_dirs = [ "$CP_TEST_ROOT/$CP_TEST_BIN" ]
+filter_images = [ "$CP_TEST_ROOT" ]
+
+[[ignore]]
+  error = "ErrNotDynLinked"
+  files = [ "$CP_TEST_ROOT/bar" ]
+  dirs = [ "/usr/$CP_TEST_BIN" ]
+
+[rpm.foo]
+  filter_files = [ "${CP_TEST_ROOT}/baz" ]
+
+[[rpm.foo.ignore]]
+  error = "ErrGoMissingTag"
+  dirs = [ "${CP_TEST_ROOT}/lib" ]
+
+[[component]]
+  name = "foo-container"
+
+  [[component.ignore]]
+    error = "ErrNotDynLinked"
+    files = [ "$CP_TEST_UNSET/qux" ]
+`, &cfg)
+	require.NoError(t, err)
+	cfg.ExpandEnv()
+	err, _ = cfg.Validate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/opt/app/foo"}, cfg.FilterFiles)
+	assert.Equal(t, []string{"/opt/app/bin"}, cfg.FilterDirs)
+	// Not a path, so not expanded.
+	assert.Equal(t, []string{"$CP_TEST_ROOT"}, cfg.FilterImages)
+	assert.Equal(t, []string{"/opt/app/bar"}, cfg.ErrIgnores[0].Files)
+	assert.Equal(t, []string{"/usr/bin"}, cfg.ErrIgnores[0].Dirs)
+	assert.Equal(t, []string{"/opt/app/baz"}, cfg.RPMIgnores["foo"].FilterFiles)
+	assert.Equal(t, []string{"/opt/app/lib"}, cfg.RPMIgnores["foo"].ErrIgnores[0].Dirs)
+	assert.Equal(t, []string{"/qux"}, cfg.ComponentOverrides[0].ErrIgnores[0].Files)
+}
+
+func TestSeverities(t *testing.T) {
+	main := decode(t, `[severity]
+  go-tags = "low"
+  relro = "high"`)
+	assert.Equal(t, map[string]types.Severity{"go-tags": types.SeverityLow, "relro": types.SeverityHigh}, main.Severities)
+
+	// Same value is fine, a different one is an error.
+	require.NoError(t, main.Add(&types.ConfigFile{Severities: map[string]types.Severity{"go-tags": types.SeverityLow, "nx": types.SeverityInfo}}))
+	assert.Equal(t, types.SeverityInfo, main.Severities["nx"])
+	assert.Error(t, main.Add(&types.ConfigFile{Severities: map[string]types.Severity{"relro": types.SeverityMedium}}))
+	assert.Equal(t, types.SeverityHigh, main.Severities["relro"])
+
+	// When layering, a different one overrides (and is still an error).
+	assert.EqualError(t, main.Layer(&types.ConfigFile{Severities: map[string]types.Severity{"relro": types.SeverityMedium}}),
+		"main config severity relro = high is overridden by medium")
+	assert.Equal(t, types.SeverityMedium, main.Severities["relro"])
+
+	var cfg types.ConfigFile
+	_, err := toml.Decode(`severity = { go-tags = "critical" }`, &cfg)
+	assert.ErrorContains(t, err, `unknown severity "critical"`)
+
+	s, err := types.ParseSeverity("medium")
+	require.NoError(t, err)
+	assert.Equal(t, types.SeverityMedium, s)
+	assert.Equal(t, "medium", s.String())
+	assert.True(t, types.SeverityHigh > s && s > types.SeverityLow)
+	_, err = types.ParseSeverity("")
+	assert.Error(t, err)
+}
@@ -2,7 +2,6 @@ package types
 
 import (
 	"errors"
-	"fmt"
 )
 
 // KnownError is a type used to parse "error = Err*" values in toml config.
@@ -11,15 +10,18 @@ type KnownError struct {
 	Str string
 }
 
-// UnmarshalText is used when parsing toml config.
+// UnmarshalText is used when parsing toml config. Unknown error names
+// are not rejected here (so that decoding continues), but are reported
+// by Validate.
 func (e *KnownError) UnmarshalText(text []byte) error {
-	str := string(text)
-	if err, ok := KnownErrors[str]; ok {
-		e.Str = str
-		e.Err = err
-		return nil
-	}
-	return fmt.Errorf("error=%q is not recognized in config", str)
+	e.Str = string(text)
+	e.Err = KnownErrors[e.Str]
+	return nil
+}
+
+// MarshalText is used when writing toml config.
+func (e KnownError) MarshalText() ([]byte, error) {
+	return []byte(e.Str), nil
 }
 
 // String is used when printing the current configuration.
@@ -25,6 +25,18 @@ func (r *ScanResult) Skipped() *ScanResult {
 	return r
 }
 
+// SkippedBecause marks the result as skipped for a given reason.
+func (r *ScanResult) SkippedBecause(reason string) *ScanResult {
+	r.SkipReason = reason
+	return r.Skipped()
+}
+
+// SetSymlinkTarget sets the path the skipped symlink resolves to.
+func (r *ScanResult) SetSymlinkTarget(target string) *ScanResult {
+	r.SymlinkTarget = target
+	return r
+}
+
 func (r *ScanResult) IsLevel(level ErrorLevel) bool {
 	return r.Error != nil && r.Error.Level == level
 }
@@ -81,3 +93,33 @@ func (r *ScanResult) SetRPM(rpm string) *ScanResult {
 	r.RPM = rpm
 	return r
 }
+
+func (r *ScanResult) AddException(rule string) *ScanResult {
+	r.Exceptions = append(r.Exceptions, rule)
+	return r
+}
+
+func (r *ScanResult) SetKind(kind string) *ScanResult {
+	r.Kind = kind
+	return r
+}
+
+func (r *ScanResult) SetSHA256(digest string) *ScanResult {
+	r.SHA256 = digest
+	return r
+}
+
+func (r *ScanResult) SetGoBuildInfo(info *GoBuildInfo) *ScanResult {
+	r.GoBuildInfo = info
+	return r
+}
+
+func (r *ScanResult) SetSeverity(severity Severity) *ScanResult {
+	r.Severity = severity
+	return r
+}
+
+func (r *ScanResult) SetArch(arch string) *ScanResult {
+	r.Arch = arch
+	return r
+}
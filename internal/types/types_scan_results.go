@@ -1,10 +1,48 @@
 package types
 
+import (
+	"time"
+
+	v1 "github.com/openshift/api/image/v1"
+)
+
 func NewScanResults() *ScanResults {
 	return &ScanResults{}
 }
 
+// Append adds the result to sr. It is safe for concurrent use.
 func (sr *ScanResults) Append(result *ScanResult) *ScanResults {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
 	sr.Items = append(sr.Items, result)
 	return sr
 }
+
+func (sr *ScanResults) SetTag(tag *v1.TagReference) *ScanResults {
+	sr.Tag = tag
+	return sr
+}
+
+// SetArch sets the architecture of the image scanned, for sr and all its items.
+func (sr *ScanResults) SetArch(arch string) *ScanResults {
+	sr.Arch = arch
+	for _, res := range sr.Items {
+		res.SetArch(arch)
+	}
+	return sr
+}
+
+// SetTime records the scan start and end time.
+func (sr *ScanResults) SetTime(start, end time.Time) *ScanResults {
+	sr.Start = start
+	sr.End = end
+	return sr
+}
+
+// Duration returns the scan duration, or 0 if unknown.
+func (sr *ScanResults) Duration() time.Duration {
+	if sr.Start.IsZero() || sr.End.IsZero() {
+		return 0
+	}
+	return sr.End.Sub(sr.Start)
+}
@@ -0,0 +1,61 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is the severity of a validation failure. Unlike the error
+// level (failure or warning), it is used to gate the exit code of a scan
+// (see --fail-on-severity).
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota // Not set, e.g. for failures not caused by a check.
+	SeverityInfo
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+)
+
+var severityNames = [...]string{
+	SeverityUnknown: "",
+	SeverityInfo:    "info",
+	SeverityLow:     "low",
+	SeverityMedium:  "medium",
+	SeverityHigh:    "high",
+}
+
+// SeverityNames are the names of valid severities, from the lowest
+// to the highest.
+var SeverityNames = severityNames[SeverityInfo:]
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity returns the severity with a given name (see SeverityNames).
+func ParseSeverity(name string) (Severity, error) {
+	for s, n := range severityNames {
+		if n != "" && n == name {
+			return Severity(s), nil
+		}
+	}
+	return SeverityUnknown, fmt.Errorf("unknown severity %q (want one of: %s)", name, strings.Join(SeverityNames, ", "))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	v, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// exceptionHits is a set of exceptionHit, recording which exception
+// entries have matched a file (see ErrIgnoreList.Match).
+var exceptionHits sync.Map
+
+// exceptionHit is a single files or dirs entry of an exception.
+// The exception is identified by its address, which is stable as
+// long as the config is not modified after it is loaded.
+type exceptionHit struct {
+	ie    *ErrIgnore
+	entry string
+}
+
+func markExceptionHit(ie *ErrIgnore, entry string) {
+	exceptionHits.Store(exceptionHit{ie, entry}, struct{}{})
+}
+
+func isExceptionHit(ie *ErrIgnore, entry string) bool {
+	_, ok := exceptionHits.Load(exceptionHit{ie, entry})
+	return ok
+}
+
+// UnusedExceptions returns descriptions of exception entries (every
+// files and dirs entry of [[ignore]], [[<section>.ignore]], and
+// [[component.ignore]]) which have not matched any file so far.
+func (c *ConfigFile) UnusedExceptions() []string {
+	var unused []string
+	add := func(section string, l ErrIgnoreList) {
+		for i := range l {
+			ie := &l[i]
+			for _, d := range ie.Dirs {
+				if !isExceptionHit(ie, d) {
+					unused = append(unused, fmt.Sprintf("%s error=%s dirs=%q", section, ie.Error.Str, d))
+				}
+			}
+			for _, f := range ie.Files {
+				if !isExceptionHit(ie, f) {
+					unused = append(unused, fmt.Sprintf("%s error=%s files=%q", section, ie.Error.Str, f))
+				}
+			}
+		}
+	}
+
+	add("[[ignore]]", c.ErrIgnores)
+	for _, s := range []struct {
+		prefix string
+		lists  map[string]IgnoreLists
+	}{
+		{"payload", c.PayloadIgnores},
+		{"tag", c.TagIgnores},
+		{"rpm", c.RPMIgnores},
+	} {
+		names := make([]string, 0, len(s.lists))
+		for name := range s.lists {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add("[["+s.prefix+"."+name+".ignore]]", s.lists[name].ErrIgnores)
+		}
+	}
+	for _, o := range c.ComponentOverrides {
+		add("[[component]] name="+o.Name+" [[component.ignore]]", o.ErrIgnores)
+	}
+
+	return unused
+}
@@ -0,0 +1,85 @@
+package validations
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// A payload has many identical binaries (such as the same tool or library
+// in many images), so the outcomes of validations which only depend on the
+// binary contents are cached within a run, keyed by the binary digest.
+// The path specific parts of a result (the path, tag, rpm, exceptions) are
+// not cached.
+var (
+	checkCache   sync.Map // checkCacheKey -> *types.ValidationError (nil if passed).
+	backendCache sync.Map // digest -> go crypto backend.
+
+	cacheHits, cacheMisses atomic.Int64
+)
+
+type checkCacheKey struct {
+	digest string
+	check  string
+	// backends are the allowed go crypto backends, as these
+	// affect the outcome of the go-crypto-backend check.
+	backends string
+}
+
+// CacheStats returns the numbers of validation cache hits and misses.
+func CacheStats() (hits, misses int64) {
+	return cacheHits.Load(), cacheMisses.Load()
+}
+
+// runCheck runs the validation on the binary with a given digest, or returns
+// the cached outcome, if available.
+func runCheck(ctx context.Context, v *Validation, path, digest string, baton *Baton) *types.ValidationError {
+	return baton.symtabErrorOnce(cachedCheck(ctx, v, path, digest, baton))
+}
+
+// cachedCheck is runCheck, without the go symbol table errors filtered out.
+func cachedCheck(ctx context.Context, v *Validation, path, digest string, baton *Baton) *types.ValidationError {
+	if v.NoCache {
+		return v.Fn(ctx, path, baton)
+	}
+	key := checkCacheKey{digest: digest, check: v.Name, backends: strings.Join(baton.GoCryptoBackends, ",")}
+	if cached, ok := checkCache.Load(key); ok {
+		cacheHits.Add(1)
+		err := cached.(*types.ValidationError)
+		if err == nil {
+			return nil
+		}
+		// Return a copy, as the caller may modify it.
+		c := *err
+		return &c
+	}
+	cacheMisses.Add(1)
+	err := v.Fn(ctx, path, baton)
+	if ctx.Err() != nil {
+		// The outcome might be caused by a timeout.
+		return err
+	}
+	if err == nil {
+		checkCache.Store(key, (*types.ValidationError)(nil))
+	} else {
+		c := *err
+		checkCache.Store(key, &c)
+	}
+	return err
+}
+
+// cachedGoCryptoBackend is like baton.goCryptoBackend, but uses
+// the cached value for the binary with a given digest, if available.
+func cachedGoCryptoBackend(path, digest string, baton *Baton) (string, error) {
+	if backend, ok := backendCache.Load(digest); ok {
+		return backend.(string), nil
+	}
+	backend, err := baton.goCryptoBackend(path)
+	if err == nil {
+		backendCache.Store(digest, backend)
+	}
+	return backend, err
+}
@@ -0,0 +1,56 @@
+package validations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestScanBinaryCache(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(dir, name), exe, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Make a (cacheable) check fail, to see the error is cached.
+	calls := 0
+	v := &Validation{Name: "test-fail", Kind: "exe", Fn: func(context.Context, string, *Baton) *types.ValidationError {
+		calls++
+		return types.NewValidationError(types.ErrNotDynLinked)
+	}}
+	validations = append(validations, v)
+	defer func() { validations = validations[:len(validations)-1] }()
+
+	cfg := &types.Config{Checks: []string{"test-fail"}}
+	hits, misses := CacheStats()
+	resA := ScanBinary(context.Background(), cfg, dir, "/a", nil)
+	resB := ScanBinary(context.Background(), cfg, dir, "/b", nil)
+	if calls != 1 {
+		t.Errorf("check run %d times, want 1", calls)
+	}
+	if h, m := CacheStats(); h-hits != 1 || m-misses != 1 {
+		t.Errorf("got %d hits, %d misses; want 1, 1", h-hits, m-misses)
+	}
+	for _, res := range []*types.ScanResult{resA, resB} {
+		if res.Error == nil || res.Error.Error != types.ErrNotDynLinked {
+			t.Errorf("%s: want ErrNotDynLinked, got %+v", res.Path, res.Error)
+		}
+	}
+	if resA.Path != "/a" || resB.Path != "/b" {
+		t.Errorf("wrong paths: %s, %s", resA.Path, resB.Path)
+	}
+	// Results do not share the error.
+	resA.Error.SetWarning()
+	if resB.IsLevel(types.Warning) {
+		t.Error("cached error is shared between results")
+	}
+}
@@ -0,0 +1,118 @@
+package validations
+
+import (
+	"context"
+	"debug/gosym"
+	"fmt"
+	"strings"
+
+	"go.uber.org/multierr"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// goCryptoBackendNone is the backend of go binaries using native go crypto.
+const goCryptoBackendNone = "none"
+
+// goCryptoBackends are the known go crypto backends, and the prefixes of
+// function names identifying them, in order of detection.
+var goCryptoBackends = []struct {
+	name     string
+	prefixes []string
+}{
+	{
+		// golang-fips openssl module (go 1.19+).
+		name: "openssl-fips",
+		prefixes: []string{
+			"vendor/github.com/golang-fips/openssl-fips/openssl.",
+			"vendor/github.com/golang-fips/openssl/",
+		},
+	},
+	{
+		// BoringCrypto API implemented by dlopen'ed openssl.
+		name:     "boring-openssl",
+		prefixes: []string{"crypto/internal/boring._Cfunc__goboringcrypto_DLOPEN_OPENSSL"},
+	},
+	{
+		// Upstream BoringCrypto (GOEXPERIMENT=boringcrypto).
+		name:     "boringcrypto",
+		prefixes: []string{"crypto/internal/boring._Cfunc__goboringcrypto_"},
+	},
+}
+
+// GoCryptoBackendNames returns the names of all known go crypto backends.
+func GoCryptoBackendNames() []string {
+	names := make([]string, 0, len(goCryptoBackends)+1)
+	for _, b := range goCryptoBackends {
+		names = append(names, b.name)
+	}
+	return append(names, goCryptoBackendNone)
+}
+
+// ValidateGoCryptoBackends checks that all names are known go crypto backends.
+func ValidateGoCryptoBackends(names []string) error {
+	known := GoCryptoBackendNames()
+	var err error
+	for _, name := range names {
+		if !isMatchAny(name, known) {
+			multierr.AppendInto(&err, fmt.Errorf("unknown go crypto backend %q (known backends: %s)", name, strings.Join(known, ", ")))
+		}
+	}
+	return err
+}
+
+func isMatchAny(name string, list []string) bool {
+	for _, n := range list {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// goCryptoBackend returns the name of the crypto backend used by a go
+// binary, according to its symbols, or an empty string if the binary
+// does not use crypto.
+func goCryptoBackend(symtable *gosym.Table) string {
+	if !isUsingCryptoModule(symtable) {
+		return ""
+	}
+	for _, b := range goCryptoBackends {
+		for _, fn := range symtable.Funcs {
+			for _, prefix := range b.prefixes {
+				if strings.HasPrefix(fn.Name, prefix) {
+					return b.name
+				}
+			}
+		}
+	}
+	return goCryptoBackendNone
+}
+
+// goCryptoBackend detects (once) and returns the go crypto backend.
+func (b *Baton) goCryptoBackend(path string) (string, error) {
+	symtable, err := b.goSymtable(path)
+	if err != nil {
+		return "", err
+	}
+	if !b.goBackendRead {
+		b.goBackend = goCryptoBackend(symtable)
+		b.goBackendRead = true
+	}
+	return b.goBackend, nil
+}
+
+func validateGoCryptoBackend(_ context.Context, path string, baton *Baton) *types.ValidationError {
+	if len(baton.GoCryptoBackends) == 0 {
+		// Any backend is fine.
+		return nil
+	}
+	backend, err := baton.goCryptoBackend(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	if backend == "" || isMatchAny(backend, baton.GoCryptoBackends) {
+		return nil
+	}
+	return types.NewValidationError(fmt.Errorf("%w: %s", types.ErrGoCryptoBackend, backend))
+}
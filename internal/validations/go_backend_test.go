@@ -0,0 +1,55 @@
+package validations
+
+import (
+	"debug/gosym"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func symtable(names ...string) *gosym.Table {
+	t := &gosym.Table{}
+	for _, name := range names {
+		t.Funcs = append(t.Funcs, gosym.Func{Sym: &gosym.Sym{Name: name}})
+	}
+	return t
+}
+
+func TestGoCryptoBackend(t *testing.T) {
+	testCases := []struct {
+		name    string
+		funcs   []string
+		backend string
+	}{
+		{"no crypto", []string{"main.main", "fmt.Println"}, ""},
+		{"native", []string{"main.main", "crypto/sha256.Sum256"}, "none"},
+		{
+			"openssl-fips",
+			[]string{"crypto/sha256.Sum256", "vendor/github.com/golang-fips/openssl-fips/openssl._Cfunc__goboringcrypto_DLOPEN_OPENSSL"},
+			"openssl-fips",
+		},
+		{
+			"openssl v2",
+			[]string{"crypto/sha256.Sum256", "vendor/github.com/golang-fips/openssl/v2._Cfunc_go_openssl_EVP_MD_CTX_new"},
+			"openssl-fips",
+		},
+		{
+			"boring-openssl",
+			[]string{"crypto/internal/boring._Cfunc__goboringcrypto_SHA256", "crypto/internal/boring._Cfunc__goboringcrypto_DLOPEN_OPENSSL"},
+			"boring-openssl",
+		},
+		{
+			"boringcrypto",
+			[]string{"crypto/internal/boring._Cfunc__goboringcrypto_SHA256"},
+			"boringcrypto",
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.backend, goCryptoBackend(symtable(tc.funcs...)), tc.name)
+	}
+}
+
+func TestValidateGoCryptoBackends(t *testing.T) {
+	assert.NoError(t, ValidateGoCryptoBackends([]string{"openssl-fips", "none"}))
+	assert.Error(t, ValidateGoCryptoBackends([]string{"openssl-fips", "libressl"}))
+}
@@ -0,0 +1,126 @@
+package validations
+
+import (
+	"context"
+	"debug/buildinfo"
+	"debug/gosym"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// goFIPSModes are the ways a go binary can be built with FIPS support, and
+// the initialization functions which enable FIPS mode at run time (and fail
+// if it can't be enabled), which must be present in such a binary.
+var goFIPSModes = []struct {
+	name string
+	// built tells if the binary is built with FIPS support of this kind,
+	// according to its build settings.
+	built func(settings map[string]string) bool
+	// initFunc matches the names of the initialization functions.
+	initFunc *regexp.Regexp
+	// desc describes the initialization functions, for the error message.
+	desc string
+}{
+	{
+		// golang-fips toolchain, using openssl (GOLANG_FIPS=1 at run time).
+		name: "strictfipsruntime",
+		built: func(s map[string]string) bool {
+			return hasListItem(s["-tags"], "strictfipsruntime") || hasListItem(s["GOEXPERIMENT"], "strictfipsruntime")
+		},
+		// Older golang-fips versions initialize openssl in the boring package.
+		initFunc: regexp.MustCompile(`^crypto/internal/(?:backend|boring)\.init\b`),
+		desc:     "crypto/internal/backend init",
+	},
+	{
+		// Upstream BoringCrypto.
+		name: "boringcrypto",
+		built: func(s map[string]string) bool {
+			return hasListItem(s["GOEXPERIMENT"], "boringcrypto")
+		},
+		initFunc: regexp.MustCompile(`^crypto/internal/boring\.init\b`),
+		desc:     "crypto/internal/boring init",
+	},
+	{
+		// Go native FIPS 140-3 module (go 1.24+), enabled by GODEBUG=fips140=on
+		// (which is the default with GOFIPS140 set). The module may be a frozen
+		// version, such as crypto/internal/fips140/v1.0.0-c2097c7c/check.
+		name: "fips140",
+		built: func(s map[string]string) bool {
+			if v := s["GOFIPS140"]; v != "" && v != "off" {
+				return true
+			}
+			godebug := s["DefaultGODEBUG"]
+			return hasListItem(godebug, "fips140=on") || hasListItem(godebug, "fips140=only")
+		},
+		initFunc: regexp.MustCompile(`^crypto/internal/fips140/(?:[^/]+/)?check\.init\b`),
+		desc:     "crypto/internal/fips140/check init (the module self-test)",
+	},
+}
+
+// hasListItem tells if the comma-separated list contains the item.
+func hasListItem(list, item string) bool {
+	for _, s := range strings.Split(list, ",") {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// goFIPSSettings returns the build settings of the go binary, by key.
+func goFIPSSettings(bi *buildinfo.BuildInfo) map[string]string {
+	settings := make(map[string]string)
+	for _, bs := range bi.Settings {
+		settings[bs.Key] = bs.Value
+	}
+	return settings
+}
+
+// missingGoFIPSInit returns the first kind of FIPS support the binary is
+// built with, but lacks the initialization functions of, and the description
+// of the functions, or empty strings if there is no such kind.
+func missingGoFIPSInit(settings map[string]string, symtable *gosym.Table) (string, string) {
+	for _, mode := range goFIPSModes {
+		if !mode.built(settings) {
+			continue
+		}
+		found := false
+		for _, fn := range symtable.Funcs {
+			if mode.initFunc.MatchString(fn.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return mode.name, mode.desc
+		}
+	}
+	return "", ""
+}
+
+// validateGoFIPSInit checks that the go binary built with FIPS support
+// (according to its build settings) contains the code initializing FIPS
+// mode at run time. This is a static heuristic: the binary is not run, so
+// a binary passing the check may still fail to enable FIPS mode in a given
+// environment (such as one with FIPS disabled in the kernel).
+func validateGoFIPSInit(_ context.Context, path string, baton *Baton) *types.ValidationError {
+	// Build settings are only embedded since go 1.18.
+	if baton.GoBuildInfo == nil || goLessThan118.Check(baton.GoVersion) {
+		return nil
+	}
+	symtable, err := baton.goSymtable(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	// Binaries not using crypto do not initialize it.
+	if !isUsingCryptoModule(symtable) {
+		return nil
+	}
+	if mode, desc := missingGoFIPSInit(goFIPSSettings(baton.GoBuildInfo), symtable); mode != "" {
+		return types.NewValidationError(fmt.Errorf("%w: built with %s, but no %s found", types.ErrGoNoFIPSInit, mode, desc))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package validations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+const (
+	goFIPSTestSource = `package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+func main() {
+	fmt.Println(sha256.Sum256([]byte("hello")))
+}
+`
+	goNoCryptoTestSource = `package main
+
+func main() {
+	println("hello")
+}
+`
+)
+
+// buildGo builds the go source using go build with given flags and
+// environment variables, in a new temporary directory, and returns the
+// path to the output file.
+func buildGo(t *testing.T, name, source string, env []string, flags ...string) string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found")
+	}
+	dir := t.TempDir()
+	for file, data := range map[string]string{"main.go": source, "go.mod": "module test\n\ngo 1.18\n"} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exe := filepath.Join(dir, name)
+	cmd := exec.Command(goBin, append([]string{"build", "-o", exe}, flags...)...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GOFLAGS=", "GOTOOLCHAIN=local"), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("go build %v (%v): %v: %s", flags, env, err, out)
+	}
+	return exe
+}
+
+func TestGoFIPSInit(t *testing.T) {
+	plain := buildGo(t, "plain", goFIPSTestSource, nil)
+	// The strictfipsruntime tag has no effect with the upstream toolchain,
+	// so there is no openssl backend.
+	tags := buildGo(t, "tags", goFIPSTestSource, nil, "-tags", "strictfipsruntime")
+	noCrypto := buildGo(t, "no-crypto", goNoCryptoTestSource, nil, "-tags", "strictfipsruntime")
+
+	for _, tc := range []struct {
+		path string
+		msg  string // Substring of the error message, empty for success.
+	}{
+		{plain, ""},
+		{tags, "built with strictfipsruntime, but no crypto/internal/backend init found"},
+		{noCrypto, ""},
+	} {
+		name := filepath.Base(tc.path)
+		baton := &Baton{}
+		if ok, err := isGoExecutable(tc.path, baton); !ok || err != nil {
+			t.Fatalf("%s: not a go executable: %v", name, err)
+		}
+		verr := validateGoFIPSInit(context.Background(), tc.path, baton)
+		switch {
+		case tc.msg == "" && verr != nil:
+			t.Errorf("%s: unexpected error: %v", name, verr.Error)
+		case tc.msg != "" && (verr == nil || !errors.Is(verr.Error, types.ErrGoNoFIPSInit) || !strings.Contains(verr.Error.Error(), tc.msg)):
+			t.Errorf("%s: want %v (%s), got %v", name, types.ErrGoNoFIPSInit, tc.msg, verr)
+		}
+	}
+}
+
+func TestGoFIPSInitNative(t *testing.T) {
+	// The frozen FIPS module (see GOFIPS140) is only available since go 1.24.
+	fips := buildGo(t, "fips", goFIPSTestSource, []string{"GOFIPS140=v1.0.0"})
+	baton := &Baton{}
+	if ok, err := isGoExecutable(fips, baton); !ok || err != nil {
+		t.Fatalf("not a go executable: %v", err)
+	}
+	if verr := validateGoFIPSInit(context.Background(), fips, baton); verr != nil {
+		t.Errorf("unexpected error: %v", verr.Error)
+	}
+}
+
+func TestGoFIPSModes(t *testing.T) {
+	for _, tc := range []struct {
+		settings map[string]string
+		want     []string
+	}{
+		{map[string]string{"-tags": "foo,strictfipsruntime"}, []string{"strictfipsruntime"}},
+		{map[string]string{"GOEXPERIMENT": "strictfipsruntime,boringcrypto"}, []string{"strictfipsruntime", "boringcrypto"}},
+		{map[string]string{"GOFIPS140": "v1.0.0-c2097c7c"}, []string{"fips140"}},
+		{map[string]string{"GOFIPS140": "off"}, nil},
+		{map[string]string{"DefaultGODEBUG": "fips140=on,tlssha1=1"}, []string{"fips140"}},
+		{map[string]string{"DefaultGODEBUG": "fips140=off", "-tags": "strictfipsruntimex"}, nil},
+	} {
+		var got []string
+		for _, mode := range goFIPSModes {
+			if mode.built(tc.settings) {
+				got = append(got, mode.name)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%v: want %v, got %v", tc.settings, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package validations
+
+import (
+	"context"
+	"debug/elf"
+	"errors"
+	"fmt"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// ELF hardening checks. These are not FIPS-related, but are useful
+// for security audits.
+
+// df1Now is DF_1_NOW flag of DT_FLAGS_1 dynamic entry.
+const df1Now = 0x1
+
+// validateRelro checks that the binary is built with full RELRO, i.e. has
+// a PT_GNU_RELRO segment, and, if dynamically linked, is bound immediately
+// (so the relocations are all done before the segment is made read-only).
+func validateRelro(_ context.Context, path string, _ *Baton) *types.ValidationError {
+	exe, err := elf.Open(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	defer exe.Close()
+
+	if !hasProg(exe, elf.PT_GNU_RELRO) {
+		return types.NewValidationError(fmt.Errorf("%w (no RELRO)", types.ErrNoRelro))
+	}
+	bindNow, err := isBindNow(exe)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	if !bindNow && hasProg(exe, elf.PT_DYNAMIC) {
+		return types.NewValidationError(fmt.Errorf("%w (partial RELRO, no BIND_NOW)", types.ErrNoRelro))
+	}
+	return nil
+}
+
+// validateCanary checks that the binary is built with stack canaries,
+// i.e. references __stack_chk_fail. Note that a binary with no functions
+// needing a canary does not reference it, either.
+func validateCanary(_ context.Context, path string, _ *Baton) *types.ValidationError {
+	exe, err := elf.Open(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	defer exe.Close()
+
+	for _, symbols := range []func() ([]elf.Symbol, error){exe.DynamicSymbols, exe.Symbols} {
+		syms, err := symbols()
+		if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+			return types.NewValidationError(err)
+		}
+		for _, sym := range syms {
+			if sym.Name == "__stack_chk_fail" || sym.Name == "__stack_chk_fail_local" {
+				return nil
+			}
+		}
+	}
+	return types.NewValidationError(types.ErrNoStackCanary)
+}
+
+// validateNX checks that the binary has a non-executable stack, i.e.
+// a PT_GNU_STACK segment without the execute permission (without such
+// a segment, the stack is executable on most architectures).
+func validateNX(_ context.Context, path string, _ *Baton) *types.ValidationError {
+	exe, err := elf.Open(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	defer exe.Close()
+
+	for _, p := range exe.Progs {
+		if p.Type != elf.PT_GNU_STACK {
+			continue
+		}
+		if p.Flags&elf.PF_X != 0 {
+			return types.NewValidationError(fmt.Errorf("%w (PT_GNU_STACK is RWX)", types.ErrExecStack))
+		}
+		return nil
+	}
+	return types.NewValidationError(fmt.Errorf("%w (no PT_GNU_STACK)", types.ErrExecStack))
+}
+
+// hasProg tells if exe has a program header of a given type.
+func hasProg(exe *elf.File, typ elf.ProgType) bool {
+	for _, p := range exe.Progs {
+		if p.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// isBindNow tells if the dynamic section of exe requests immediate binding
+// (via DT_BIND_NOW, DF_BIND_NOW in DT_FLAGS, or DF_1_NOW in DT_FLAGS_1).
+func isBindNow(exe *elf.File) (bool, error) {
+	ds := exe.SectionByType(elf.SHT_DYNAMIC)
+	if ds == nil {
+		return false, nil
+	}
+	data, err := ds.Data()
+	if err != nil {
+		return false, err
+	}
+	entSize := 8
+	if exe.Class == elf.ELFCLASS64 {
+		entSize = 16
+	}
+	for ; len(data) >= entSize; data = data[entSize:] {
+		var tag elf.DynTag
+		var val uint64
+		if exe.Class == elf.ELFCLASS64 {
+			tag = elf.DynTag(exe.ByteOrder.Uint64(data[0:8]))
+			val = exe.ByteOrder.Uint64(data[8:16])
+		} else {
+			tag = elf.DynTag(exe.ByteOrder.Uint32(data[0:4]))
+			val = uint64(exe.ByteOrder.Uint32(data[4:8]))
+		}
+		switch {
+		case tag == elf.DT_NULL:
+			return false, nil
+		case tag == elf.DT_BIND_NOW,
+			tag == elf.DT_FLAGS && val&uint64(elf.DF_BIND_NOW) != 0,
+			tag == elf.DT_FLAGS_1 && val&df1Now != 0:
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+package validations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+const hardeningTestSource = `
+#include <stdio.h>
+#include <string.h>
+
+int main(int argc, char **argv) {
+	char buf[64];
+	strncpy(buf, argv[0], sizeof(buf) - 1);
+	buf[sizeof(buf) - 1] = 0;
+	puts(buf);
+	return 0;
+}
+`
+
+// buildC builds the C source using gcc with given flags, in a new
+// temporary directory, and returns the path to the output file.
+func buildC(t *testing.T, name, source string, flags ...string) string {
+	t.Helper()
+	gcc, err := exec.LookPath("gcc")
+	if err != nil {
+		t.Skip("gcc not found")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main.c")
+	if err := os.WriteFile(src, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, name)
+	// Flags go last, so libraries are linked after the source.
+	args := append([]string{"-o", exe, src}, flags...)
+	if out, err := exec.Command(gcc, args...).CombinedOutput(); err != nil {
+		t.Skipf("gcc %v: %v: %s", args, err, out)
+	}
+	return exe
+}
+
+func TestHardeningChecks(t *testing.T) {
+	hardened := buildC(t, "hardened", hardeningTestSource, "-fstack-protector-all", "-Wl,-z,relro,-z,now", "-Wl,-z,noexecstack")
+	weak := buildC(t, "weak", hardeningTestSource, "-fno-stack-protector", "-Wl,-z,norelro", "-Wl,-z,execstack")
+	lazy := buildC(t, "lazy", hardeningTestSource, "-Wl,-z,relro,-z,lazy")
+
+	for _, tc := range []struct {
+		name string
+		fn   ValidationFn
+		path string
+		want error  // nil for success.
+		msg  string // Substring of the error message.
+	}{
+		{"relro", validateRelro, hardened, nil, ""},
+		{"relro", validateRelro, weak, types.ErrNoRelro, "no RELRO"},
+		{"relro", validateRelro, lazy, types.ErrNoRelro, "partial RELRO"},
+		{"canary", validateCanary, hardened, nil, ""},
+		{"canary", validateCanary, weak, types.ErrNoStackCanary, ""},
+		{"nx", validateNX, hardened, nil, ""},
+		{"nx", validateNX, weak, types.ErrExecStack, "RWX"},
+	} {
+		verr := tc.fn(context.Background(), tc.path, &Baton{})
+		name := tc.name + "/" + filepath.Base(tc.path)
+		if tc.want == nil {
+			if verr != nil {
+				t.Errorf("%s: unexpected error: %v", name, verr.Error)
+			}
+			continue
+		}
+		if verr == nil || !errors.Is(verr.Error, tc.want) {
+			t.Errorf("%s: want %v, got %v", name, tc.want, verr)
+			continue
+		}
+		if !strings.Contains(verr.Error.Error(), tc.msg) {
+			t.Errorf("%s: want %q in error, got %v", name, tc.msg, verr.Error)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package validations
+
+import (
+	"context"
+	"debug/elf"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+var (
+	// Functions defined by libcrypto. A binary defining (rather than
+	// importing) any of these has OpenSSL linked in statically.
+	bundledOpensslSymbols = []string{
+		"OpenSSL_version",
+		"OpenSSL_version_num",
+		"OPENSSL_init_crypto",
+		"SSLeay_version",
+	}
+
+	// OPENSSL_VERSION_TEXT, as embedded into libcrypto,
+	// e.g. "OpenSSL 3.0.7 1 Nov 2022" or "OpenSSL 1.1.1k  FIPS 25 Mar 2021".
+	bundledOpensslVersionRegexp = regexp.MustCompile(`OpenSSL \d+\.\d+\.\d+[a-z]*[ -][ -~]{0,32}?\d{1,2} [A-Z][a-z]{2} \d{4}`)
+
+	// libcrypto source file names, used in its error messages. Unlike the
+	// version text above, these are never found in binaries merely built
+	// against OpenSSL headers.
+	bundledOpensslSourceRegexp = regexp.MustCompile(`crypto/evp/[a-z0-9_]{1,64}\.c\x00`)
+)
+
+// opensslChunkSize is the size of a chunk of a file searched for libcrypto
+// strings. The chunks overlap by opensslChunkOverlap bytes (more than the
+// longest match of the regexps above), so strings crossing a chunk boundary
+// are found, too.
+const (
+	opensslChunkSize    = 1024 * 1024
+	opensslChunkOverlap = 256
+)
+
+// validateGoBundledOpenssl checks that the go binary does not contain
+// its own (statically linked) copy of OpenSSL.
+func validateGoBundledOpenssl(_ context.Context, path string, _ *Baton) *types.ValidationError {
+	bundled, err := hasBundledOpenssl(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	if bundled {
+		return types.NewValidationError(types.ErrGoBundledOpenssl)
+	}
+	return nil
+}
+
+func hasBundledOpenssl(path string) (bool, error) {
+	exe, err := elf.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer exe.Close()
+
+	if exe.Section(".openssl") != nil {
+		return true, nil
+	}
+
+	syms, err := exe.Symbols()
+	switch {
+	case err == nil:
+		for _, sym := range syms {
+			if sym.Section == elf.SHN_UNDEF || elf.ST_TYPE(sym.Info) != elf.STT_FUNC {
+				continue
+			}
+			for _, name := range bundledOpensslSymbols {
+				if sym.Name == name {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case errors.Is(err, elf.ErrNoSymbols):
+		// A stripped binary; fall back to looking for libcrypto strings.
+		return hasOpensslStrings(path)
+	}
+	return false, err
+}
+
+func hasOpensslStrings(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return findOpensslStrings(f)
+}
+
+// findOpensslStrings tells if both the libcrypto version text and source
+// file names are found in r.
+func findOpensslStrings(r io.Reader) (bool, error) {
+	var haveVersion, haveSource bool
+	err := searchChunks(r, opensslChunkSize, opensslChunkOverlap, func(chunk []byte) bool {
+		haveVersion = haveVersion || bundledOpensslVersionRegexp.Match(chunk)
+		haveSource = haveSource || bundledOpensslSourceRegexp.Match(chunk)
+		return haveVersion && haveSource
+	})
+	if err != nil {
+		return false, err
+	}
+	return haveVersion && haveSource, nil
+}
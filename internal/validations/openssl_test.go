@@ -0,0 +1,35 @@
+package validations
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFindOpensslStrings(t *testing.T) {
+	version := "OpenSSL 3.0.7 1 Nov 2022"
+	source := "crypto/evp/digest.c\x00"
+	for _, tc := range []struct {
+		name       string
+		versionOff int
+		sourceOff  int
+		want       bool
+	}{
+		{name: "first chunk", versionOff: 100, sourceOff: 200, want: true},
+		{name: "crossing the boundary", versionOff: opensslChunkSize - 10, sourceOff: 2*opensslChunkSize + opensslChunkOverlap - 5, want: true},
+		{name: "in the overlap", versionOff: opensslChunkSize + 10, sourceOff: 2*opensslChunkSize + 10, want: true},
+		{name: "version only", versionOff: opensslChunkSize - 10, sourceOff: -1},
+	} {
+		data := make([]byte, 3*opensslChunkSize)
+		copy(data[tc.versionOff:], version)
+		if tc.sourceOff >= 0 {
+			copy(data[tc.sourceOff:], source)
+		}
+		got, err := findOpensslStrings(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package validations
+
+import (
+	"context"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// pyExtNameRegexp matches file names of CPython extension modules, such as
+// _ssl.cpython-39-x86_64-linux-gnu.so or _rust.abi3.so.
+var pyExtNameRegexp = regexp.MustCompile(`\.(cpython-3\d+[a-z0-9_-]*|abi3)\.so$`)
+
+// IsPythonExtensionName tells if the path looks like a CPython extension
+// module. Such modules are not necessarily executable, yet need to be scanned.
+func IsPythonExtensionName(path string) bool {
+	return pyExtNameRegexp.MatchString(path)
+}
+
+// isPythonExtension tells if the ELF shared object is a CPython extension
+// module, either by its name or by a defined PyInit_* symbol (unless it is
+// libpython itself, which defines Py_Initialize).
+func isPythonExtension(exe *elf.File, path string) bool {
+	if IsPythonExtensionName(path) {
+		return true
+	}
+	syms, err := exe.DynamicSymbols()
+	if err != nil {
+		return false
+	}
+	var pyInit bool
+	for _, sym := range syms {
+		if sym.Section == elf.SHN_UNDEF {
+			continue
+		}
+		if sym.Name == "Py_Initialize" {
+			return false
+		}
+		if strings.HasPrefix(sym.Name, "PyInit_") {
+			pyInit = true
+		}
+	}
+	return pyInit
+}
+
+// validatePyExtBundledOpenssl checks that the python extension module does
+// not contain its own (statically linked) copy of OpenSSL.
+func validatePyExtBundledOpenssl(_ context.Context, path string, _ *Baton) *types.ValidationError {
+	bundled, err := hasBundledOpenssl(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	if bundled {
+		return types.NewValidationError(types.ErrPyExtBundledOpenssl)
+	}
+	return nil
+}
+
+// validatePyExtLibcrypto checks that libcrypto, if the python extension
+// module is linked to it, is present in the image.
+func validatePyExtLibcrypto(_ context.Context, path string, baton *Baton) *types.ValidationError {
+	exe, err := elf.Open(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	defer exe.Close()
+
+	libs, err := exe.ImportedLibraries()
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	for _, lib := range libs {
+		if !strings.HasPrefix(lib, "libcrypto.so") {
+			continue
+		}
+		if !hasLib(baton.TopDir, lib) {
+			return types.NewValidationError(fmt.Errorf("%w: %v", types.ErrLibcryptoSoMissing, lib))
+		}
+	}
+	return nil
+}
+
+// hasLib tells if the shared library is present under root.
+func hasLib(root, lib string) bool {
+	for dir := range systemLibDirs {
+		if _, err := os.Stat(filepath.Join(root, dir, lib)); err == nil {
+			return true
+		}
+	}
+	return false
+}
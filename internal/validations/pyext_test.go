@@ -0,0 +1,73 @@
+package validations
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsPythonExtensionName(t *testing.T) {
+	cases := map[string]bool{
+		"/usr/lib64/python3.9/lib-dynload/_ssl.cpython-39-x86_64-linux-gnu.so":              true,
+		"/usr/lib64/python3.11/site-packages/_cffi_backend.cpython-311-x86_64-linux-gnu.so": true,
+		"/usr/lib64/python3.9/site-packages/cryptography/hazmat/bindings/_rust.abi3.so":     true,
+		"/usr/lib64/libpython3.9.so.1.0":                                                    false,
+		"/usr/lib64/python3.9/site-packages/foo.py":                                         false,
+		"/usr/lib64/libcrypto.so.3":                                                         false,
+	}
+	for path, exp := range cases {
+		if got := IsPythonExtensionName(path); got != exp {
+			t.Errorf("%s: got %v, want %v", path, got, exp)
+		}
+	}
+}
+
+func TestIsElfExe(t *testing.T) {
+	pie := buildC(t, "pie", hardeningTestSource, "-fPIE", "-pie")
+	lib := buildC(t, "libfoo.so", "int foo(void) { return 0; }", "-fPIC", "-shared")
+	pyext := buildC(t, "foo.so", "void *PyInit_foo(void) { return 0; }", "-fPIC", "-shared")
+
+	// A shared object with the dynamic section offset out of the file.
+	broken := filepath.Join(t.TempDir(), "broken.so")
+	data, err := os.ReadFile(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.Open(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range f.Sections {
+		if s.Type == elf.SHT_DYNAMIC {
+			// Offset of sh_offset of the i-th section header (ELF64).
+			off := binary.LittleEndian.Uint64(data[0x28:]) + uint64(i)*64 + 24
+			binary.LittleEndian.PutUint64(data[off:], 1<<40)
+		}
+	}
+	f.Close()
+	if err := os.WriteFile(broken, data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path    string
+		want    bool
+		wantErr bool
+	}{
+		{path: pie, want: true},
+		{path: lib},
+		{path: pyext, want: true},
+		{path: broken, wantErr: true},
+	} {
+		got, _, err := isElfExe(tc.path, &Baton{})
+		name := filepath.Base(tc.path)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package validations
+
+import (
+	"context"
+	"debug/elf"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// cryptoLibRegexp matches the sonames of openssl libraries.
+var cryptoLibRegexp = regexp.MustCompile(`^lib(crypto|ssl)\.so(\.\d+)*$`)
+
+// systemLibDirs are the directories the system libcrypto and libssl
+// are installed to.
+var systemLibDirs = map[string]bool{
+	"/lib":       true,
+	"/lib64":     true,
+	"/usr/lib":   true,
+	"/usr/lib64": true,
+}
+
+// validateCryptoRunpath checks that a binary depending on libcrypto or
+// libssl has no RPATH or RUNPATH entries pointing outside of system library
+// directories, which means a non-system (and likely not FIPS-validated) copy
+// of the library may be loaded.
+func validateCryptoRunpath(_ context.Context, path string, baton *Baton) *types.ValidationError {
+	exe, err := elf.Open(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	defer exe.Close()
+
+	needed, err := exe.ImportedLibraries()
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	var cryptoLibs []string
+	for _, lib := range needed {
+		if cryptoLibRegexp.MatchString(lib) {
+			cryptoLibs = append(cryptoLibs, lib)
+		}
+	}
+	if len(cryptoLibs) == 0 {
+		return nil
+	}
+
+	for _, tag := range []elf.DynTag{elf.DT_RUNPATH, elf.DT_RPATH} {
+		values, err := exe.DynString(tag)
+		if err != nil {
+			return types.NewValidationError(err)
+		}
+		for _, value := range values {
+			for _, dir := range strings.Split(value, ":") {
+				if dir == "" || isSystemLibDir(expandOrigin(dir, path, baton.TopDir)) {
+					continue
+				}
+				name := strings.TrimPrefix(tag.String(), "DT_")
+				return types.NewValidationError(fmt.Errorf("%w: %s %s (needs %s)", types.ErrCryptoRunpath, name, dir, strings.Join(cryptoLibs, ", ")))
+			}
+		}
+	}
+	return nil
+}
+
+// expandOrigin replaces $ORIGIN (the directory of the binary, relative
+// to topDir) in a RPATH or RUNPATH entry. If the binary is not under
+// topDir, dir is returned as is.
+func expandOrigin(dir, path, topDir string) string {
+	if !strings.Contains(dir, "$ORIGIN") && !strings.Contains(dir, "${ORIGIN}") {
+		return dir
+	}
+	rel, err := filepath.Rel(topDir, filepath.Dir(path))
+	if topDir == "" || err != nil || strings.HasPrefix(rel, "..") {
+		return dir
+	}
+	origin := filepath.Join("/", rel)
+	return strings.NewReplacer("${ORIGIN}", origin, "$ORIGIN", origin).Replace(dir)
+}
+
+// isSystemLibDir tells if dir is one of systemLibDirs.
+func isSystemLibDir(dir string) bool {
+	return strings.HasPrefix(dir, "/") && systemLibDirs[filepath.Clean(dir)]
+}
@@ -0,0 +1,69 @@
+package validations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestValidateCryptoRunpath(t *testing.T) {
+	// A fake libcrypto to link against.
+	lib := buildC(t, "libcrypto.so.3", "int fake_crypto(void) { return 0; }", "-shared", "-fPIC", "-Wl,-soname,libcrypto.so.3")
+	link := []string{"-L" + filepath.Dir(lib), "-l:libcrypto.so.3"}
+	const main = "int fake_crypto(void); int main(void) { return fake_crypto(); }"
+	build := func(name string, flags ...string) string {
+		return buildC(t, name, main, append(flags, link...)...)
+	}
+
+	// An image with the binary in /usr/bin.
+	topDir := t.TempDir()
+	install := func(exe string) string {
+		dst := filepath.Join(topDir, "usr", "bin", filepath.Base(exe))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(exe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, data, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return dst
+	}
+
+	for _, tc := range []struct {
+		exe string
+		msg string // Substring of the error message, empty for success.
+	}{
+		{build("no-runpath"), ""},
+		{build("system", "-Wl,-rpath,/usr/lib64/"), ""},
+		{build("origin", "-Wl,-rpath,$ORIGIN/../lib64"), ""},
+		{build("runpath", "-Wl,--enable-new-dtags,-rpath,/opt/foo/lib"), "RUNPATH /opt/foo/lib (needs libcrypto.so.3)"},
+		{build("rpath", "-Wl,--disable-new-dtags,-rpath,/usr/lib64:/opt/foo/lib"), "RPATH /opt/foo/lib"},
+		{build("origin-opt", "-Wl,-rpath,$ORIGIN/../../opt/lib"), "$ORIGIN/../../opt/lib"},
+		{buildC(t, "no-crypto", "int main(void) { return 0; }", "-Wl,-rpath,/opt/foo/lib"), ""},
+	} {
+		path := install(tc.exe)
+		verr := validateCryptoRunpath(context.Background(), path, &Baton{TopDir: topDir})
+		name := filepath.Base(path)
+		if tc.msg == "" {
+			if verr != nil {
+				t.Errorf("%s: unexpected error: %v", name, verr.Error)
+			}
+			continue
+		}
+		if verr == nil || !errors.Is(verr.Error, types.ErrCryptoRunpath) {
+			t.Errorf("%s: want ErrCryptoRunpath, got %v", name, verr)
+			continue
+		}
+		if !strings.Contains(verr.Error.Error(), tc.msg) {
+			t.Errorf("%s: want %q in error, got %v", name, tc.msg, verr.Error)
+		}
+	}
+}
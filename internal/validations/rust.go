@@ -0,0 +1,142 @@
+package validations
+
+import (
+	"context"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+var (
+	// Symbols defined by the rust standard library and runtime.
+	rustSymbols = []string{"rust_begin_unwind", "rust_eh_personality", "rust_panic"}
+
+	// Paths to the standard library sources, embedded into rust binaries
+	// (even stripped ones) as panic locations, such as
+	// "/rustc/90b35a6239c3d8bdabc530a6a0816f7ff89a0aaf/library/std/src/io/mod.rs".
+	rustStdPathRegexp = regexp.MustCompile(`/rustc/[0-9a-f]{40}/library/`)
+
+	// Paths to the sources of crates implementing crypto on their own (or
+	// bundling a crypto library), rather than using the system OpenSSL,
+	// such as ".cargo/registry/src/index.crates.io-6f17d22bba15001f/ring-0.17.8/src/aead.rs"
+	// or "vendor/ring/src/aead.rs" (for vendored crates).
+	rustCryptoPathRegexp = regexp.MustCompile(`(?:registry/src/[^/\x00]+|vendor)/(aws-lc-fips-sys|aws-lc-rs|aws-lc-sys|boring-sys|boring|ring|rustls)(?:-(\d+\.\d+\.\d+[0-9a-z.+-]*))?/`)
+
+	// Symbols of C and assembly code of crypto crates, which are prefixed
+	// with the crate version (such as "ring_core_0_17_8_aes_hw_encrypt").
+	rustCryptoSymbolRegexp = regexp.MustCompile(`^(?:(ring)_core_|(aws_lc)_)(\d+)_(\d+)_(\d+)_`)
+)
+
+// rustCrateName maps the names found in symbols to crate names.
+var rustCrateName = map[string]string{"ring": "ring", "aws_lc": "aws-lc-sys"}
+
+// rustChunkSize is the size of a chunk of a file searched for rust paths.
+// The chunks overlap by rustChunkOverlap bytes, so paths crossing a chunk
+// boundary are found, too.
+const (
+	rustChunkSize    = 1024 * 1024
+	rustChunkOverlap = 1024
+)
+
+// validateRustCrypto checks that the rust executable does not use crypto
+// crates, such as ring or rustls, instead of the system OpenSSL (which is
+// used via the openssl crate). Non-rust executables are not checked.
+func validateRustCrypto(_ context.Context, path string, _ *Baton) *types.ValidationError {
+	isRust, crates, err := rustCryptoCrates(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	if isRust && len(crates) > 0 {
+		return types.NewValidationError(fmt.Errorf("%w: %s", types.ErrRustCrypto, strings.Join(crates, ", ")))
+	}
+	return nil
+}
+
+// rustCryptoCrates tells if the binary is a rust one, and returns the
+// crypto crates found in it, as sorted "name version" strings (or just
+// names, if the version is not known).
+func rustCryptoCrates(path string) (bool, []string, error) {
+	exe, err := elf.Open(path)
+	if err != nil {
+		return false, nil, err
+	}
+	defer exe.Close()
+
+	var isRust bool
+	crates := make(map[string]map[string]bool)
+	addCrate := func(name, version string) {
+		if crates[name] == nil {
+			crates[name] = make(map[string]bool)
+		}
+		crates[name][version] = true
+	}
+
+	for _, symbols := range []func() ([]elf.Symbol, error){exe.Symbols, exe.DynamicSymbols} {
+		syms, err := symbols()
+		if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+			return false, nil, err
+		}
+		for _, sym := range syms {
+			if sym.Section == elf.SHN_UNDEF {
+				continue
+			}
+			if strings.HasPrefix(sym.Name, "__rust_") {
+				isRust = true
+			}
+			for _, name := range rustSymbols {
+				if sym.Name == name {
+					isRust = true
+				}
+			}
+			if m := rustCryptoSymbolRegexp.FindStringSubmatch(sym.Name); m != nil {
+				addCrate(rustCrateName[m[1]+m[2]], m[3]+"."+m[4]+"."+m[5])
+			}
+		}
+	}
+
+	// Stripped binaries have no symbols, but still have the source paths.
+	f, err := os.Open(path)
+	if err != nil {
+		return false, nil, err
+	}
+	defer f.Close()
+	stdPath, err := findRustPaths(f, addCrate)
+	if err != nil {
+		return false, nil, err
+	}
+	isRust = isRust || stdPath
+
+	var list []string
+	for name, versions := range crates {
+		for version := range versions {
+			if version == "" && len(versions) > 1 {
+				continue
+			}
+			list = append(list, strings.TrimSpace(name+" "+version))
+		}
+	}
+	sort.Strings(list)
+	return isRust, list, nil
+}
+
+// findRustPaths searches r for rust source paths. It tells if the paths of
+// the standard library are found, and calls addCrate for every crypto crate
+// path found.
+func findRustPaths(r io.Reader, addCrate func(name, version string)) (bool, error) {
+	var stdPath bool
+	err := searchChunks(r, rustChunkSize, rustChunkOverlap, func(chunk []byte) bool {
+		stdPath = stdPath || rustStdPathRegexp.Match(chunk)
+		for _, m := range rustCryptoPathRegexp.FindAllSubmatch(chunk, -1) {
+			addCrate(string(m[1]), string(m[2]))
+		}
+		return false
+	})
+	return stdPath, err
+}
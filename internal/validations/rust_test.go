@@ -0,0 +1,97 @@
+package validations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// rustTestSource mimics a rust binary: the strings are the panic locations
+// of the standard library and crates, and the function is an assembly
+// routine of ring.
+const rustTestSource = `
+#include <stdio.h>
+
+const char *locations[] = {
+	"/rustc/90b35a6239c3d8bdabc530a6a0816f7ff89a0aaf/library/std/src/io/mod.rs",
+	CRATE_PATHS
+};
+
+void ring_core_0_17_8_aes_hw_encrypt(void) {}
+
+int main(void) {
+	for (int i = 0; i < sizeof(locations) / sizeof(locations[0]); i++)
+		puts(locations[i]);
+	ring_core_0_17_8_aes_hw_encrypt();
+	return 0;
+}
+`
+
+func TestRustCrypto(t *testing.T) {
+	const registry = `"/root/.cargo/registry/src/index.crates.io-6f17d22bba15001f/`
+	ring := buildC(t, "ring", rustTestSource, "-DCRATE_PATHS="+registry+`ring-0.17.8/src/aead.rs"`)
+	// Stripped, so the ring symbol is not there, but the paths still are.
+	rustls := buildC(t, "rustls", rustTestSource, "-s", "-DCRATE_PATHS="+registry+`rustls-0.21.7/src/conn.rs",`+
+		registry+`rustls-pemfile-1.0.4/src/lib.rs", "vendor/aws-lc-rs/src/aead.rs"`)
+	openssl := buildC(t, "openssl", strings.Replace(rustTestSource, "ring_core_0_17_8_", "", -1), "-s", "-DCRATE_PATHS="+registry+`openssl-0.10.57/src/ssl/mod.rs"`)
+	notRust := buildC(t, "c", strings.Replace(rustTestSource, "/rustc/", "/usr/src/", 1), "-DCRATE_PATHS="+registry+`ring-0.17.8/src/aead.rs"`)
+
+	for _, tc := range []struct {
+		path   string
+		isRust bool
+		crates []string
+	}{
+		{ring, true, []string{"ring 0.17.8"}},
+		{rustls, true, []string{"aws-lc-rs", "rustls 0.21.7"}},
+		{openssl, true, nil},
+		{notRust, false, []string{"ring 0.17.8"}},
+	} {
+		name := filepath.Base(tc.path)
+		isRust, crates, err := rustCryptoCrates(tc.path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if isRust != tc.isRust || !reflect.DeepEqual(crates, tc.crates) {
+			t.Errorf("%s: want %v %q, got %v %q", name, tc.isRust, tc.crates, isRust, crates)
+		}
+	}
+
+	verr := validateRustCrypto(context.Background(), rustls, &Baton{})
+	if verr == nil || !errors.Is(verr.Error, types.ErrRustCrypto) || !strings.HasSuffix(verr.Error.Error(), ": aws-lc-rs, rustls 0.21.7") {
+		t.Errorf("want %v, got %v", types.ErrRustCrypto, verr)
+	}
+	for _, path := range []string{openssl, notRust} {
+		if verr := validateRustCrypto(context.Background(), path, &Baton{}); verr != nil {
+			t.Errorf("%s: unexpected error: %v", filepath.Base(path), verr.Error)
+		}
+	}
+}
+
+func TestFindRustPaths(t *testing.T) {
+	// Paths crossing the chunk boundary, and in the overlap.
+	std := "/rustc/90b35a6239c3d8bdabc530a6a0816f7ff89a0aaf/library/core/src/fmt/mod.rs"
+	ring := "registry/src/index.crates.io-6f17d22bba15001f/ring-0.16.20/src/lib.rs"
+	data := make([]byte, 3*rustChunkSize)
+	copy(data[rustChunkSize+rustChunkOverlap-10:], std)
+	copy(data[2*rustChunkSize+rustChunkOverlap-10:], ring)
+	copy(data[2*rustChunkSize+100:], ring)
+
+	var got []string
+	stdPath, err := findRustPaths(bytes.NewReader(data), func(name, version string) {
+		got = append(got, name+" "+version)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second path is in the overlap, so it is found twice.
+	if want := []string{"ring 0.16.20", "ring 0.16.20", "ring 0.16.20"}; !stdPath || !reflect.DeepEqual(got, want) {
+		t.Errorf("want true %q, got %v %q", want, stdPath, got)
+	}
+}
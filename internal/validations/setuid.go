@@ -0,0 +1,31 @@
+package validations
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+// validateSetuid checks that the file has neither setuid nor setgid bit set.
+// This is not FIPS-related, but is useful for hardening audits.
+func validateSetuid(_ context.Context, path string, _ *Baton) *types.ValidationError {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	var bits string
+	switch m := fi.Mode(); {
+	case m&fs.ModeSetuid != 0 && m&fs.ModeSetgid != 0:
+		bits = "setuid and setgid"
+	case m&fs.ModeSetuid != 0:
+		bits = "setuid"
+	case m&fs.ModeSetgid != 0:
+		bits = "setgid"
+	default:
+		return nil
+	}
+	return types.NewValidationError(fmt.Errorf("%w (%s, mode %v)", types.ErrSetuid, bits, fi.Mode()))
+}
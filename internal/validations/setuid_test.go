@@ -0,0 +1,71 @@
+package validations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestValidateSetuid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "exe")
+	if err := os.WriteFile(file, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		mode os.FileMode
+		fail bool
+	}{
+		{0o755, false},
+		{0o755 | os.ModeSetuid, true},
+		{0o755 | os.ModeSetgid, true},
+		{0o755 | os.ModeSetuid | os.ModeSetgid, true},
+	} {
+		if err := os.Chmod(file, tc.mode); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != tc.mode {
+			t.Skipf("can't set file mode %v (got %v)", tc.mode, fi.Mode())
+		}
+		verr := validateSetuid(context.Background(), file, nil)
+		if !tc.fail {
+			if verr != nil {
+				t.Errorf("mode %v: unexpected error: %v", tc.mode, verr.Error)
+			}
+			continue
+		}
+		if verr == nil || !errors.Is(verr.Error, types.ErrSetuid) {
+			t.Errorf("mode %v: want ErrSetuid, got %v", tc.mode, verr)
+		}
+	}
+}
+
+func TestChecksForOptIn(t *testing.T) {
+	has := func(checks []*Validation, name string) bool {
+		for _, v := range checks {
+			if v.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	if has(checksFor(&types.Config{}, "exe", nil), "setuid") {
+		t.Error("opt-in setuid check is enabled by default")
+	}
+	cfg := &types.Config{Checks: []string{"setuid"}}
+	for _, kind := range []string{"exe", "go"} {
+		if !has(checksFor(cfg, kind, nil), "setuid") {
+			t.Errorf("setuid check is not enabled for %s", kind)
+		}
+	}
+	if has(checksFor(cfg, "pyext", nil), "setuid") {
+		t.Error("setuid check is enabled for pyext")
+	}
+}
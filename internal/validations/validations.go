@@ -4,22 +4,27 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"debug/buildinfo"
 	"debug/elf"
 	"debug/gosym"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
+	"go.uber.org/multierr"
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/check-payload/internal/golang"
 	"github.com/openshift/check-payload/internal/rpm"
+	"github.com/openshift/check-payload/internal/tracing"
 	"github.com/openshift/check-payload/internal/types"
 )
 
@@ -38,35 +43,367 @@ var (
 type Baton struct {
 	TopDir      string
 	Static      bool
-	GoNoCrypto  bool
+	PyExt       bool // A python extension module (a shared object).
 	GoVersion   *semver.Version
 	GoBuildInfo *buildinfo.BuildInfo
+	// GoCryptoBackends are the allowed go crypto backends (any if empty).
+	GoCryptoBackends []string
+
+	goSymtab            *gosym.Table
+	goSymtabErr         error
+	goSymtabRead        bool
+	goSymtabErrReported bool
+
+	goBackend     string
+	goBackendRead bool
+}
+
+// goSymtable reads (once) and returns the go symbol table.
+func (b *Baton) goSymtable(path string) (*gosym.Table, error) {
+	if !b.goSymtabRead {
+		b.goSymtab, b.goSymtabErr = golang.ReadTable(path, b.GoBuildInfo)
+		if b.goSymtabErr != nil {
+			b.goSymtabErr = &goSymtabError{fmt.Errorf("go: could not read table for %v: %w", filepath.Base(path), b.goSymtabErr)}
+		}
+		b.goSymtabRead = true
+	}
+	return b.goSymtab, b.goSymtabErr
+}
+
+// goSymtabError is the error reading the go symbol table (see goSymtable).
+type goSymtabError struct {
+	err error
+}
+
+func (e *goSymtabError) Error() string {
+	return e.err.Error()
+}
+
+func (e *goSymtabError) Unwrap() error {
+	return e.err
+}
+
+// symtabErrorOnce returns err, the outcome of a check, unless it is the
+// go symbol table error (see goSymtable) already returned for another
+// check of the binary. That error is only reported by (and can be excepted
+// for) the first check, the other ones are skipped. It is applied after the
+// cache lookup (see runCheck), as the checks run for a binary depend on
+// the component (see disabledChecks).
+func (b *Baton) symtabErrorOnce(err *types.ValidationError) *types.ValidationError {
+	var symErr *goSymtabError
+	if err == nil || !errors.As(err.Error, &symErr) {
+		return err
+	}
+	if b.goSymtabErrReported {
+		return nil
+	}
+	b.goSymtabErrReported = true
+	return err
+}
+
+// goNoCrypto tells if the go binary is not using crypto.
+func (b *Baton) goNoCrypto(path string) (bool, error) {
+	symtable, err := b.goSymtable(path)
+	if err != nil {
+		return false, err
+	}
+	return !isUsingCryptoModule(symtable), nil
 }
 
 type ValidationFn func(ctx context.Context, path string, baton *Baton) *types.ValidationError
 
-var validationFns = map[string][]ValidationFn{
-	"go": {
-		validateGoCgo,
-		validateGoCGOInit,
-		validateGoSymbols,
-		validateGoStatic,
-		validateGoOpenssl,
-		validateGoTags,
+// Validation is a named check performed on binaries of a particular kind.
+type Validation struct {
+	// Name is used to select validations via --checks.
+	Name string `json:"name"`
+	// Description is a one-line description of the validation.
+	Description string `json:"description"`
+	// Kind is a kind of binaries the validation applies to, either
+	// "go", "exe" (a non-go executable), "pyext" (a python extension
+	// module), "any" (go and non-go executables), or "node" (a file
+	// system level check of a node scan, run for all files, with no Fn).
+	Kind string `json:"kind"`
+	// OptIn validations are only run if explicitly selected via --checks.
+	OptIn bool `json:"opt_in,omitempty"`
+	// Severity is the default severity of the validation failures
+	// (can be changed via the [severity] config section).
+	Severity types.Severity `json:"severity"`
+	// NoCache is set for validations which depend on more than the binary
+	// contents (such as other files in the image, or the file mode), so
+	// their outcomes can't be cached by the binary digest.
+	NoCache bool         `json:"-"`
+	Fn      ValidationFn `json:"-"`
+}
+
+// validations is a registry of all validations, in order of execution.
+var validations = []*Validation{
+	{
+		Name:        "go-cgo",
+		Description: "go binary must not be built with CGO_ENABLED=0",
+		Kind:        "go",
+		Severity:    types.SeverityHigh,
+		Fn:          validateGoCgo,
+	},
+	{
+		Name:        "go-cgo-init",
+		Description: "go binary must contain x_cgo_init",
+		Kind:        "go",
+		Severity:    types.SeverityHigh,
+		Fn:          validateGoCGOInit,
+	},
+	{
+		Name:        "go-crypto-symbols",
+		Description: "go binary using crypto must contain FIPS openssl symbols",
+		Kind:        "go",
+		Severity:    types.SeverityHigh,
+		Fn:          validateGoSymbols,
+	},
+	{
+		Name:        "go-dyn-linked",
+		Description: "go binary using crypto must be dynamically linked",
+		Kind:        "go",
+		Severity:    types.SeverityHigh,
+		Fn:          validateGoStatic,
+	},
+	{
+		Name:        "go-openssl",
+		Description: "go binary using crypto must use a single libcrypto version present in the image",
+		Kind:        "go",
+		Severity:    types.SeverityHigh,
+		NoCache:     true,
+		Fn:          validateGoOpenssl,
+	},
+	{
+		Name:        "go-bundled-openssl",
+		Description: "go binary must not contain statically linked openssl",
+		Kind:        "go",
+		Severity:    types.SeverityHigh,
+		Fn:          validateGoBundledOpenssl,
+	},
+	{
+		Name:        "go-tags",
+		Description: "go binary must be built with strictfipsruntime and without no_openssl tags",
+		Kind:        "go",
+		Severity:    types.SeverityMedium,
+		Fn:          validateGoTags,
+	},
+	{
+		Name:        "go-crypto-backend",
+		Description: "go binary using crypto must use one of the allowed crypto backends (go_crypto_backends)",
+		Kind:        "go",
+		Severity:    types.SeverityHigh,
+		Fn:          validateGoCryptoBackend,
+	},
+	{
+		Name:        "go-fips-init",
+		Description: "go binary built with FIPS support must contain the FIPS initialization code (static heuristic, opt-in)",
+		Kind:        "go",
+		Severity:    types.SeverityMedium,
+		OptIn:       true,
+		Fn:          validateGoFIPSInit,
+	},
+	{
+		Name:        "dyn-linked",
+		Description: "executable must be dynamically linked",
+		Kind:        "exe",
+		Severity:    types.SeverityHigh,
+		Fn:          validateNotStatic,
+	},
+	{
+		Name:        "crypto-runpath",
+		Description: "executable depending on libcrypto or libssl must not have RPATH or RUNPATH outside of system library directories",
+		Kind:        "any",
+		Severity:    types.SeverityHigh,
+		NoCache:     true,
+		Fn:          validateCryptoRunpath,
 	},
-	"exe": {
-		validateNotStatic,
+	{
+		Name:        "pyext-bundled-openssl",
+		Description: "python extension module must not contain statically linked openssl",
+		Kind:        "pyext",
+		Severity:    types.SeverityHigh,
+		Fn:          validatePyExtBundledOpenssl,
 	},
+	{
+		Name:        "pyext-libcrypto",
+		Description: "python extension module linked to libcrypto must use a libcrypto present in the image",
+		Kind:        "pyext",
+		Severity:    types.SeverityHigh,
+		NoCache:     true,
+		Fn:          validatePyExtLibcrypto,
+	},
+	{
+		Name:        "rust-crypto",
+		Description: "rust executable must not use embedded crypto crates, such as ring or rustls (opt-in)",
+		Kind:        "exe",
+		Severity:    types.SeverityHigh,
+		OptIn:       true,
+		Fn:          validateRustCrypto,
+	},
+	{
+		Name:        "setuid",
+		Description: "executable must not have setuid or setgid bit set (opt-in)",
+		Kind:        "any",
+		Severity:    types.SeverityMedium,
+		OptIn:       true,
+		NoCache:     true,
+		Fn:          validateSetuid,
+	},
+	{
+		Name:        "relro",
+		Description: "executable must be built with full RELRO (opt-in)",
+		Kind:        "any",
+		Severity:    types.SeverityLow,
+		OptIn:       true,
+		Fn:          validateRelro,
+	},
+	{
+		Name:        "canary",
+		Description: "executable must be built with stack canaries (opt-in)",
+		Kind:        "exe",
+		Severity:    types.SeverityLow,
+		OptIn:       true,
+		Fn:          validateCanary,
+	},
+	{
+		Name:        "nx",
+		Description: "executable must have a non-executable stack (opt-in)",
+		Kind:        "any",
+		Severity:    types.SeverityLow,
+		OptIn:       true,
+		Fn:          validateNX,
+	},
+	{
+		Name:        "world-writable",
+		Description: "regular file must not be world-writable (node scan only, opt-in)",
+		Kind:        "node",
+		Severity:    types.SeverityMedium,
+		OptIn:       true,
+	},
+	{
+		Name:        "rpm-orphan",
+		Description: "file must be owned by an installed rpm package (node scan only, opt-in)",
+		Kind:        "node",
+		Severity:    types.SeverityLow,
+		OptIn:       true,
+	},
+	{
+		Name:        "fips-module",
+		Description: "openssl FIPS module must have valid integrity metadata (node scan only, opt-in)",
+		Kind:        "node",
+		Severity:    types.SeverityHigh,
+		OptIn:       true,
+	},
+}
+
+// Validations returns all the registered validations.
+func Validations() []*Validation {
+	return validations
+}
+
+// ValidateCheckNames checks that all names are names of registered validations.
+func ValidateCheckNames(names []string) error {
+	var err error
+	for _, name := range names {
+		found := false
+		for _, v := range validations {
+			if v.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			multierr.AppendInto(&err, fmt.Errorf("unknown check %q (see list-checks)", name))
+		}
+	}
+	return err
+}
+
+// ValidateSeverities checks that the validation names in the [severity]
+// config section are names of registered validations.
+func ValidateSeverities(severities map[string]types.Severity) error {
+	names := make([]string, 0, len(severities))
+	for name := range severities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return ValidateCheckNames(names)
+}
+
+// severityOf returns the severity of the validation failures,
+// as remapped by cfg.Severities.
+func severityOf(cfg *types.Config, v *Validation) types.Severity {
+	if s, ok := cfg.Severities[v.Name]; ok {
+		return s
+	}
+	return v.Severity
+}
+
+// CheckSeverity returns the severity of failures of the validation
+// with a given name, as remapped by cfg.Severities.
+func CheckSeverity(cfg *types.Config, name string) types.Severity {
+	for _, v := range validations {
+		if v.Name == name {
+			return severityOf(cfg, v)
+		}
+	}
+	return types.SeverityUnknown
+}
+
+// isCheckEnabled tells if the validation is to be run, according to cfg.Checks.
+// Opt-in validations are only run if listed in cfg.Checks.
+func isCheckEnabled(cfg *types.Config, v *Validation) bool {
+	if len(cfg.Checks) == 0 {
+		return !v.OptIn
+	}
+	for _, name := range cfg.Checks {
+		if name == v.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCheckEnabled tells if the validation with a given name is to be run,
+// according to cfg.Checks.
+func IsCheckEnabled(cfg *types.Config, name string) bool {
+	for _, v := range validations {
+		if v.Name == name {
+			return isCheckEnabled(cfg, v)
+		}
+	}
+	return false
+}
+
+// isCheckDisabled tells if the validation is in the disabled list.
+func isCheckDisabled(disabled []string, v *Validation) bool {
+	for _, name := range disabled {
+		if name == v.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// checksFor returns the enabled validations for binaries of a given kind,
+// except for the disabled ones.
+func checksFor(cfg *types.Config, kind string, disabled []string) []*Validation {
+	var checks []*Validation
+	for _, v := range validations {
+		if (v.Kind == kind || v.Kind == "any" && kind != "pyext") && isCheckEnabled(cfg, v) && !isCheckDisabled(disabled, v) {
+			checks = append(checks, v)
+		}
+	}
+	return checks
 }
 
 func validateGoSymbols(_ context.Context, path string, baton *Baton) *types.ValidationError {
-	symtable, err := golang.ReadTable(path, baton.GoBuildInfo)
+	symtable, err := baton.goSymtable(path)
 	if err != nil {
-		return types.NewValidationError(fmt.Errorf("go: could not read table for %v: %w", filepath.Base(path), err))
+		return types.NewValidationError(err)
 	}
 	// Skip if the golang binary is not using crypto
 	if !isUsingCryptoModule(symtable) {
-		baton.GoNoCrypto = true
 		return nil
 	}
 
@@ -90,15 +427,21 @@ func isUsingCryptoModule(symtable *gosym.Table) bool {
 }
 
 func validateGoCgo(_ context.Context, _ string, baton *Baton) *types.ValidationError {
-	if goLessThan118.Check(baton.GoVersion) {
+	// Build settings are only embedded since go 1.18.
+	if baton.GoBuildInfo == nil || goLessThan118.Check(baton.GoVersion) {
 		return nil
 	}
 	for _, bs := range baton.GoBuildInfo.Settings {
-		if bs.Key == "CGO_ENABLED" && bs.Value == "1" {
+		if bs.Key == "CGO_ENABLED" {
+			if bs.Value == "0" {
+				return types.NewValidationError(fmt.Errorf("%w: built with CGO_ENABLED=0", types.ErrGoNotCgoEnabled))
+			}
 			return nil
 		}
 	}
-	return types.NewValidationError(types.ErrGoNotCgoEnabled)
+	// The build info is there, but with no CGO_ENABLED setting, so
+	// there is no telling cgo was enabled.
+	return types.NewValidationError(fmt.Errorf("%w: no CGO_ENABLED build setting", types.ErrGoNotCgoEnabled))
 }
 
 func validateGoTags(_ context.Context, _ string, baton *Baton) *types.ValidationError {
@@ -139,7 +482,11 @@ func validateGoTags(_ context.Context, _ string, baton *Baton) *types.Validation
 
 func validateGoStatic(ctx context.Context, path string, baton *Baton) *types.ValidationError {
 	// if the static golang binary does not contain crypto then skip
-	if baton.GoNoCrypto {
+	noCrypto, err := baton.goNoCrypto(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	if noCrypto {
 		return nil
 	}
 	return validateNotStatic(ctx, path, baton)
@@ -147,7 +494,11 @@ func validateGoStatic(ctx context.Context, path string, baton *Baton) *types.Val
 
 func validateGoOpenssl(_ context.Context, path string, baton *Baton) *types.ValidationError {
 	// if there is no crypto then skip openssl test
-	if baton.GoNoCrypto {
+	noCrypto, err := baton.goNoCrypto(path)
+	if err != nil {
+		return types.NewValidationError(err)
+	}
+	if noCrypto {
 		return nil
 	}
 	// check for openssl strings
@@ -272,6 +623,39 @@ func isGoExecutable(path string, baton *Baton) (bool, error) {
 	return true, nil
 }
 
+// goBuildSettings are the build settings reported in scan results.
+var goBuildSettings = []string{
+	"-ldflags",
+	"-tags",
+	"-trimpath",
+	"CGO_ENABLED",
+	"GOARCH",
+	"GOEXPERIMENT",
+	"GOOS",
+	"vcs.modified",
+}
+
+// goBuildInfo converts the go binary build info to be reported.
+func goBuildInfo(bi *buildinfo.BuildInfo) *types.GoBuildInfo {
+	info := &types.GoBuildInfo{GoVersion: bi.GoVersion}
+	for _, bs := range bi.Settings {
+		if bs.Key == "vcs.revision" {
+			info.VCSRevision = bs.Value
+			continue
+		}
+		for _, key := range goBuildSettings {
+			if bs.Key == key {
+				if info.Settings == nil {
+					info.Settings = make(map[string]string)
+				}
+				info.Settings[key] = bs.Value
+				break
+			}
+		}
+	}
+	return info
+}
+
 // isStatic tells if exe is a static binary.
 func isStatic(exe *elf.File) bool {
 	for _, p := range exe.Progs {
@@ -283,76 +667,145 @@ func isStatic(exe *elf.File) bool {
 	return true
 }
 
+// skipReasonCoreDump is the reason ELF core dumps are skipped.
+const skipReasonCoreDump = "core dump"
+
 // isElfExe checks if path is an ELF executable (which most probably means
 // it is a Linux binary). For ELF executables, it also checks if the binary
-// is dynamic or static, and sets baton.Dynamic accordingly.
-func isElfExe(path string, baton *Baton) (bool, error) {
+// is dynamic or static, and sets baton.Dynamic accordingly. For ELF files
+// which are not to be scanned for a non-obvious reason, such as core dumps,
+// it returns the skip reason.
+func isElfExe(path string, baton *Baton) (bool, string, error) {
 	exe, err := elf.Open(path)
 	if err != nil {
 		var elfErr *elf.FormatError
 		if errors.As(err, &elfErr) || err == io.EOF { //nolint:errorlint // See https://github.com/polyfloyd/go-errorlint/pull/45.
 			// Not an ELF.
-			return false, nil
+			return false, "", nil
 		}
 		// Error accessing the file.
-		return false, err
+		return false, "", err
 	}
 	defer exe.Close()
 	switch exe.Type {
 	case elf.ET_EXEC:
 		baton.Static = isStatic(exe)
-		return true, nil
+		return true, "", nil
 	case elf.ET_DYN: // Either a binary or a shared object.
 		pie, err := golang.IsPie(exe)
-		if err != nil || !pie {
-			return false, err
+		if err != nil {
+			return false, "", err
+		}
+		if !pie {
+			// Of all shared objects, only scan python extension modules.
+			baton.PyExt = isPythonExtension(exe, path)
+			return baton.PyExt, "", nil
 		}
 		baton.Static = isStatic(exe)
-		return true, nil
+		return true, "", nil
+	case elf.ET_CORE:
+		// Leftover core dumps (which may even have the executable bit set)
+		// are not binaries, and the validations would fail on them.
+		return false, skipReasonCoreDump, nil
 	}
 	// Unknown ELF file, so not a binary.
-	return false, nil
+	return false, "", nil
 }
 
-func ScanBinary(ctx context.Context, topDir, innerPath string, rpmIgnores map[string]types.IgnoreLists, errIgnores ...types.ErrIgnoreList) *types.ScanResult {
-	baton := &Baton{TopDir: topDir}
-	res := types.NewScanResult().SetPath(innerPath)
+// ScanBinary runs the validations on the binary. The disabledChecks are
+// names of validations not to run (in addition to those not enabled by
+// cfg.Checks), and errIgnores are the exceptions to apply.
+func ScanBinary(ctx context.Context, cfg *types.Config, topDir, innerPath string, disabledChecks []string, errIgnores ...types.ErrIgnoreList) *types.ScanResult {
+	return scanBinary(ctx, cfg, topDir, filepath.Join(topDir, innerPath), innerPath, innerPath, disabledChecks, errIgnores...)
+}
 
-	path := filepath.Join(topDir, innerPath)
+// ScanArchiveMember is like ScanBinary, but for a binary extracted from an
+// archive to path. The innerPath is the path to report (such as
+// "/opt/foo.tar!/bin/foo"), and archive is the path of the (outermost)
+// archive relative to topDir, used to find out the rpm it belongs to.
+func ScanArchiveMember(ctx context.Context, cfg *types.Config, topDir, archive, path, innerPath string, disabledChecks []string, errIgnores ...types.ErrIgnoreList) *types.ScanResult {
+	return scanBinary(ctx, cfg, topDir, path, innerPath, archive, disabledChecks, errIgnores...)
+}
+
+// scanBinary implements ScanBinary and ScanArchiveMember. The path is
+// the file to scan, innerPath is the path to report and to match the
+// exceptions against, and rpmPath is the path to find the rpm by.
+func scanBinary(ctx context.Context, cfg *types.Config, topDir, path, innerPath, rpmPath string, disabledChecks []string, errIgnores ...types.ErrIgnoreList) (res *types.ScanResult) {
+	baton := &Baton{TopDir: topDir, GoCryptoBackends: cfg.GoCryptoBackends}
+	res = types.NewScanResult().SetPath(innerPath)
 
 	// We are only interested in Linux binaries.
-	elf, err := isElfExe(path, baton)
+	elf, skipReason, err := isElfExe(path, baton)
 	if err != nil {
 		return res.SetError(err)
 	}
 	if !elf {
-		return res.Skipped()
+		return res.SkippedBecause(skipReason)
 	}
 
-	goBinary, err := isGoExecutable(path, baton)
+	// Only trace the binaries, not every file.
+	ctx, span := tracing.Start(ctx, "validate", tracing.String(tracing.AttrPath, innerPath))
+	defer func() {
+		if span == nil {
+			return
+		}
+		span.SetAttributes(tracing.String(tracing.AttrKind, res.Kind), tracing.String(tracing.AttrStatus, res.Status()))
+		if res.IsLevel(types.Error) {
+			span.SetError(res.Error.Error)
+		}
+		span.End()
+	}()
+
+	digest, err := fileSHA256(path)
 	if err != nil {
 		return res.SetError(err)
 	}
-	var checks []ValidationFn
-	if goBinary {
-		checks = validationFns["go"]
+	res.SetSHA256(digest)
+
+	var checks []*Validation
+	if baton.PyExt {
+		res.SetKind("pyext")
+		checks = checksFor(cfg, "pyext", disabledChecks)
 	} else {
-		checks = validationFns["exe"]
+		goBinary, err := isGoExecutable(path, baton)
+		if err != nil {
+			return res.SetError(err)
+		}
+		if goBinary {
+			info := goBuildInfo(baton.GoBuildInfo)
+			if backend, err := cachedGoCryptoBackend(path, digest, baton); err == nil {
+				info.CryptoBackend = backend
+			}
+			res.SetKind("go").SetGoBuildInfo(info)
+			checks = checksFor(cfg, "go", disabledChecks)
+		} else {
+			res.SetKind("exe")
+			checks = checksFor(cfg, "exe", disabledChecks)
+		}
 	}
 
 checks:
-	for _, fn := range checks {
-		if err := fn(ctx, path, baton); err != nil {
+	for _, v := range checks {
+		// Do not start a new check if the scan is canceled or timed out.
+		if err := ctx.Err(); err != nil {
+			return res.SetError(err)
+		}
+		if err := runCheck(ctx, v, path, digest, baton); err != nil {
 			// See if the error is to be ignored.
 			for _, list := range errIgnores {
-				if list.Ignore(innerPath, err.Error) {
+				if rule := list.Match(innerPath, err.Error); rule != "" {
+					if cfg.RefuseException(innerPath, err.Error, rule) {
+						break
+					}
+					klog.V(1).InfoS("error ignored", "path", innerPath, "error", err.Error, "rule", rule)
+					res.AddException(rule)
 					continue checks
 				}
 			}
 			if res.RPM == "" {
 				// Find out which rpm the file belongs to. For performance reasons,
 				// only do it for files that failed validation.
-				rpm, rpmErr := rpm.NameFromFile(ctx, topDir, innerPath)
+				rpm, rpmErr := rpm.NameFromFile(ctx, topDir, rpmPath)
 				if rpmErr != nil {
 					klog.Info(rpmErr) // XXX: a minor warning.
 				} else {
@@ -360,14 +813,23 @@ checks:
 				}
 			}
 			// See if the error is to be ignored for the rpm.
-			if res.RPM != "" && len(rpmIgnores) > 0 {
-				if i, ok := rpmIgnores[res.RPM]; ok {
-					if i.ErrIgnores.Ignore(innerPath, err.Error) {
-						continue
+			if res.RPM != "" && len(cfg.RPMIgnores) > 0 {
+				if i, ok := cfg.RPMIgnores[res.RPM]; ok {
+					if rule := i.ErrIgnores.Match(innerPath, err.Error); rule != "" {
+						rule = "[[rpm." + res.RPM + ".ignore]] " + rule
+						if !cfg.RefuseException(innerPath, err.Error, rule) {
+							klog.V(1).InfoS("error ignored", "path", innerPath, "error", err.Error, "rule", rule)
+							res.AddException(rule)
+							continue
+						}
 					}
 				}
 			}
-			return res.SetValidationError(err)
+			if err.IsWarning() && cfg.RequiredRule(innerPath) != "" {
+				// A required path must pass, so a warning is a failure.
+				err.Level = types.Error
+			}
+			return res.SetValidationError(err).SetSeverity(severityOf(cfg, v))
 		}
 	}
 
@@ -383,3 +845,43 @@ func newSemverConstraint(str string) *semver.Constraints {
 	}
 	return c
 }
+
+// fileSHA256 returns a hex-encoded SHA-256 digest of the file contents.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// searchChunks reads r in chunks of size bytes, overlapping by overlap
+// bytes (more than the longest string searched for), so that strings
+// crossing a chunk boundary are found, too. The match function is called
+// for every chunk, and stops the search by returning true.
+func searchChunks(r io.Reader, size, overlap int, match func(chunk []byte) bool) error {
+	buf := make([]byte, size+overlap)
+	var off int
+	for {
+		n, err := io.ReadFull(r, buf[off:])
+		if n == 0 && err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		chunk := buf[:off+n]
+		if match(chunk) {
+			return nil
+		}
+		if err != nil { // io.ErrUnexpectedEOF: the last chunk.
+			return nil
+		}
+		off = copy(buf, chunk[len(chunk)-overlap:])
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/openshift/check-payload/internal/proc"
 	"github.com/openshift/check-payload/internal/types"
 )
 
@@ -48,9 +49,9 @@ func ValidateOpenssl(ctx context.Context, mountPath string) types.OpensslInfo {
 	info.Path = path
 
 	var stdout bytes.Buffer
-	cmd := exec.CommandContext(ctx, "nm", "-D", filepath.Join(mountPath, path))
+	cmd := exec.Command("nm", "-D", filepath.Join(mountPath, path))
 	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+	if err := proc.Run(ctx, cmd); err != nil {
 		info.Error = err
 		return info
 	}
@@ -0,0 +1,283 @@
+package validations
+
+import (
+	"context"
+	"debug/buildinfo"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/openshift/check-payload/internal/types"
+)
+
+func TestValidateGoCgo(t *testing.T) {
+	cases := []struct {
+		name     string
+		version  string
+		settings []debug.BuildSetting
+		noInfo   bool
+		err      error
+	}{
+		{
+			name:     "cgo enabled",
+			version:  "1.20.10",
+			settings: []debug.BuildSetting{{Key: "CGO_ENABLED", Value: "1"}},
+		},
+		{
+			name:     "cgo disabled",
+			version:  "1.20.10",
+			settings: []debug.BuildSetting{{Key: "-tags", Value: "strictfipsruntime"}, {Key: "CGO_ENABLED", Value: "0"}},
+			err:      types.ErrGoNotCgoEnabled,
+		},
+		{
+			name:    "no build settings",
+			version: "1.20.10",
+			err:     types.ErrGoNotCgoEnabled,
+		},
+		{
+			name:     "no CGO_ENABLED setting",
+			version:  "1.20.10",
+			settings: []debug.BuildSetting{{Key: "-tags", Value: "strictfipsruntime"}},
+			err:      types.ErrGoNotCgoEnabled,
+		},
+		{
+			name:    "no build info",
+			version: "1.20.10",
+			noInfo:  true,
+		},
+		{
+			name:     "go 1.17",
+			version:  "1.17.13",
+			settings: []debug.BuildSetting{{Key: "CGO_ENABLED", Value: "0"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			baton := &Baton{GoVersion: semver.MustParse(tc.version)}
+			if !tc.noInfo {
+				baton.GoBuildInfo = &buildinfo.BuildInfo{Settings: tc.settings}
+			}
+			var err error
+			if verr := validateGoCgo(context.Background(), "", baton); verr != nil {
+				err = verr.Error
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("want error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestFileSHA256(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const exp = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	if digest != exp {
+		t.Errorf("got %s, want %s", digest, exp)
+	}
+}
+
+func TestScanBinaryCoreDump(t *testing.T) {
+	data, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Turn the executable into a "core dump" by setting e_type to ET_CORE.
+	order := binary.ByteOrder(binary.LittleEndian)
+	if elf.Data(data[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+	order.PutUint16(data[16:], uint16(elf.ET_CORE))
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "core"), data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	res := ScanBinary(context.Background(), &types.Config{}, dir, "/core", nil)
+	if !res.Skip || res.SkipReason != "core dump" || res.Error != nil {
+		t.Errorf("want skipped core dump, got %+v", res)
+	}
+
+	res = ScanBinary(context.Background(), &types.Config{}, "/bin", "/true", nil)
+	if res.Skip || res.SkipReason != "" {
+		t.Errorf("want /bin/true not skipped, got %+v", res)
+	}
+}
+
+func TestScanBinaryCanceled(t *testing.T) {
+	if _, err := os.Stat("/bin/true"); err != nil {
+		t.Skip(err)
+	}
+	var secondRun atomic.Bool
+	saved := validations
+	t.Cleanup(func() { validations = saved })
+	validations = []*Validation{
+		{
+			Name:    "slow",
+			Kind:    "any",
+			NoCache: true,
+			Fn: func(ctx context.Context, _ string, _ *Baton) *types.ValidationError {
+				<-ctx.Done()
+				return types.NewValidationError(ctx.Err())
+			},
+		},
+		{
+			Name:    "next",
+			Kind:    "any",
+			NoCache: true,
+			Fn: func(_ context.Context, _ string, _ *Baton) *types.ValidationError {
+				secondRun.Store(true)
+				return nil
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	res := ScanBinary(ctx, &types.Config{}, "/bin", "/true", nil)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("scan took %v after cancel", elapsed)
+	}
+	if res.Error == nil || !errors.Is(res.Error.Error, context.Canceled) {
+		t.Errorf("want context.Canceled error, got %+v", res.Error)
+	}
+	if secondRun.Load() {
+		t.Error("check run after the context was canceled")
+	}
+}
+
+func TestScanBinaryRequired(t *testing.T) {
+	if _, err := os.Stat("/bin/true"); err != nil {
+		t.Skip(err)
+	}
+	warn := false
+	saved := validations
+	t.Cleanup(func() { validations = saved })
+	validations = []*Validation{{
+		Name:    "test-required",
+		Kind:    "any",
+		NoCache: true,
+		Fn: func(context.Context, string, *Baton) *types.ValidationError {
+			err := types.NewValidationError(types.ErrNotDynLinked)
+			if warn {
+				err.SetWarning()
+			}
+			return err
+		},
+	}}
+	ignores := types.ErrIgnoreList{{Error: types.KnownError{Str: "ErrNotDynLinked", Err: types.ErrNotDynLinked}, Files: []string{"/true"}}}
+
+	res := ScanBinary(context.Background(), &types.Config{}, "/bin", "/true", nil, ignores)
+	if !res.IsSuccess() || len(res.Exceptions) != 1 {
+		t.Errorf("want the error excepted, got %+v", res)
+	}
+
+	cfg := &types.Config{ConfigFile: types.ConfigFile{Required: []types.RequiredPaths{{Files: []string{"/true"}}}}}
+	res = ScanBinary(context.Background(), cfg, "/bin", "/true", nil, ignores)
+	if !res.IsLevel(types.Error) || len(res.Exceptions) != 0 {
+		t.Errorf("want the exception refused for a required path, got %+v", res)
+	}
+
+	warn = true
+	res = ScanBinary(context.Background(), &types.Config{}, "/bin", "/true", nil)
+	if !res.IsLevel(types.Warning) {
+		t.Errorf("want a warning, got %+v", res)
+	}
+	res = ScanBinary(context.Background(), cfg, "/bin", "/true", nil)
+	if !res.IsLevel(types.Error) {
+		t.Errorf("want a warning at a required path to be a failure, got %+v", res)
+	}
+}
+
+func TestChecksForDisabled(t *testing.T) {
+	cfg := &types.Config{}
+	all := checksFor(cfg, "exe", nil)
+	if len(all) == 0 {
+		t.Fatal("no exe checks")
+	}
+	if got := checksFor(cfg, "exe", []string{"dyn-linked"}); len(got) != len(all)-1 {
+		t.Errorf("dyn-linked not disabled: got %d checks, want %d", len(got), len(all)-1)
+	}
+}
+
+func TestCheckSeverity(t *testing.T) {
+	cfg := &types.Config{}
+	if got := CheckSeverity(cfg, "relro"); got != types.SeverityLow {
+		t.Errorf("relro: got %v, want low", got)
+	}
+	cfg.Severities = map[string]types.Severity{"relro": types.SeverityHigh}
+	if got := CheckSeverity(cfg, "relro"); got != types.SeverityHigh {
+		t.Errorf("relro remapped: got %v, want high", got)
+	}
+	for _, v := range validations {
+		if v.Severity == types.SeverityUnknown {
+			t.Errorf("%s: no default severity", v.Name)
+		}
+	}
+	if err := ValidateSeverities(map[string]types.Severity{"relro": types.SeverityHigh, "foo": types.SeverityLow}); err == nil {
+		t.Error("ValidateSeverities: want error for unknown check")
+	}
+}
+
+func TestGoSymtableError(t *testing.T) {
+	// Not a go binary, so there is no symbol table to read.
+	path := filepath.Join(t.TempDir(), "notgo")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	newBaton := func() *Baton {
+		return &Baton{GoBuildInfo: &buildinfo.BuildInfo{GoVersion: "go1.20.10"}}
+	}
+	check := func(name string) *Validation {
+		for _, v := range validations {
+			if v.Name == name {
+				return v
+			}
+		}
+		t.Fatalf("no such check: %s", name)
+		return nil
+	}
+	ctx := context.Background()
+	digest := "TestGoSymtableError"
+
+	baton := newBaton()
+	if _, err := cachedGoCryptoBackend(path, digest, baton); err == nil {
+		t.Fatal("cachedGoCryptoBackend: expected an error")
+	}
+	// The error is reported by the first check only.
+	if verr := runCheck(ctx, check("go-crypto-symbols"), path, digest, baton); verr == nil {
+		t.Error("go-crypto-symbols: expected an error")
+	}
+	for _, name := range []string{"go-dyn-linked", "go-openssl"} {
+		if verr := runCheck(ctx, check(name), path, digest, baton); verr != nil {
+			t.Errorf("%s: unexpected error: %v", name, verr.Error)
+		}
+	}
+
+	// The same binary in a component with the first check disabled: the
+	// error is reported by the next one (with its outcome cached above).
+	baton = newBaton()
+	if verr := runCheck(ctx, check("go-dyn-linked"), path, digest, baton); verr == nil {
+		t.Error("go-dyn-linked: expected an error")
+	}
+	if verr := runCheck(ctx, check("go-openssl"), path, digest, baton); verr != nil {
+		t.Errorf("go-openssl: unexpected error: %v", verr.Error)
+	}
+}
@@ -6,17 +6,34 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/multierr"
+	"golang.org/x/term"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 
 	"github.com/openshift/check-payload/dist/releases"
+	"github.com/openshift/check-payload/internal/logging"
+	"github.com/openshift/check-payload/internal/podman"
 	"github.com/openshift/check-payload/internal/scan"
+	"github.com/openshift/check-payload/internal/tracing"
 	"github.com/openshift/check-payload/internal/types"
+	"github.com/openshift/check-payload/internal/validations"
 )
 
 const (
@@ -38,22 +55,129 @@ var applicationDepsNodeScan = []string{
 	"rpm",
 }
 
+var applicationDepsRootfsScan = []string{
+	"nm",
+}
+
+var applicationDepsContainerScan = []string{
+	"nm",
+	"podman",
+	"rpm",
+}
+
 var Commit string
 
+// Exit codes.
+const (
+	exitFailed   = 1 // Some binaries failed validation.
+	exitWarnings = 2 // Some binaries have warnings, and --fail-on-warnings is set.
+	exitError    = 3 // Operational error (bad config, failed pull, etc.).
+)
+
 var (
+	errRunFailed   = errors.New("run failed")
+	errRunWarnings = errors.New("run failed with warnings")
+	// errEarlyExit is used to successfully exit from PersistentPreRunE,
+	// without running the command, or from RunE, without printing results.
+	errEarlyExit = errors.New("early exit")
+)
+
+// exitCode maps an error returned by the command to the exit code.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errRunFailed):
+		return exitFailed
+	case errors.Is(err, errRunWarnings):
+		return exitWarnings
+	}
+	return exitError
+}
+
+// exitReason maps an error returned by the command to the exit reason
+// written to the --status-file (one per exit code).
+func exitReason(err error) string {
+	switch {
+	case err == nil:
+		return "passed"
+	case errors.Is(err, errRunFailed):
+		return "failed"
+	case errors.Is(err, errRunWarnings):
+		return "warnings"
+	}
+	return "error"
+}
+
+var (
+	allArches                             bool
+	arch                                  string
+	archiveMaxDepth                       int
+	archiveMaxSize                        int64
+	authFile                              string
+	baseline                              *scan.Baseline
+	baselineFile                          string
+	cacheDir                              string
+	cacheMaxSize                          int64
+	checks                                []string
+	color                                 bool
 	components                            []string
-	configFile, configForVersion          string
+	compress                              bool
+	configFiles                           []string
+	configForVersion                      string
 	cpuProfile                            string
+	credentialHelpers                     []string
+	dedupe                                bool
+	dryRun                                bool
+	dumpConfig                            bool
+	failFast                              bool
+	failOnSeverity                        string
 	failOnWarnings                        bool
 	filterFiles, filterDirs, filterImages []string
+	filterFileList                        string
+	filterRPMs                            []string
+	includeFiles, includeDirs             []string
+	htmlTemplate                          string
+	httpProxy, httpsProxy, noProxy        string
 	insecurePull                          bool
+	keepTemp                              bool
+	cleanTempOlderThan                    time.Duration
 	limit                                 int
+	logFormat                             string
+	memProfile                            string
+	memProfileFile                        *os.File
+	metadata                              bool
+	metricsAddr                           string
+	metricsServer                         *http.Server
+	noCache                               bool
+	noColor                               bool
+	onlyFailures, onlyWarnings            bool
+	otelEndpoint                          string
+	outputDir                             string
 	outputFile                            string
 	outputFormat                          string
 	parallelism                           int
+	perBinaryTimeout                      time.Duration
+	pprofAddr                             string
+	pprofServer                           *http.Server
+	pullParallelism                       int
+	pullRetries                           int
 	printExceptions                       bool
+	reportDuplicates                      bool
+	reportUnusedExceptions                bool
+	progressInterval                      time.Duration
 	pullSecretFile                        string
+	quiet                                 bool
+	registryMirrors                       []string
+	requireConfig                         bool
+	resumeFile                            string
+	sampleLargest                         int
+	scanArchives                          bool
+	scanStart                             time.Time
+	statusFile                            string
+	strictPerms                           bool
+	summaryOnly                           bool
 	timeLimit                             time.Duration
+	traceFile                             string
+	usedConfigFile                        string
 	verbose                               bool
 )
 
@@ -66,6 +190,13 @@ func main() {
 		SilenceErrors: true,
 	}
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "verbose")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text or json)")
+	cobra.OnInitialize(func() {
+		if err := logging.Setup(logFormat); err != nil {
+			klog.Errorf("Error: %v", err)
+			os.Exit(exitError)
+		}
+	})
 
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -83,21 +214,134 @@ func main() {
 			if err := getConfig(&config.ConfigFile); err != nil {
 				return err
 			}
+			config.FailFast = failFast
 			config.FailOnWarnings = failOnWarnings
+			if failOnSeverity != "" {
+				if failOnWarnings {
+					return errors.New("--fail-on-severity can't be used with --fail-on-warnings")
+				}
+				severity, err := types.ParseSeverity(failOnSeverity)
+				if err != nil {
+					return fmt.Errorf("--fail-on-severity: %w", err)
+				}
+				config.FailOnSeverity = severity
+			}
 			config.FilterFiles = append(config.FilterFiles, filterFiles...)
+			config.FilterFile = filterFileList
+			if config.FilterFile != "" {
+				list, err := readFilterFileList(config.FilterFile)
+				if err != nil {
+					return err
+				}
+				config.FilterFiles = append(config.FilterFiles, list...)
+			}
 			config.FilterDirs = append(config.FilterDirs, filterDirs...)
 			config.FilterImages = append(config.FilterImages, filterImages...)
+			config.FilterRPMs = append(config.FilterRPMs, filterRPMs...)
+			config.IncludeFiles = append(config.IncludeFiles, includeFiles...)
+			config.IncludeDirs = append(config.IncludeDirs, includeDirs...)
+			config.RegistryMirrors = append(config.RegistryMirrors, registryMirrors...)
+			if err := scan.ValidateCredentialHelpers(credentialHelpers); err != nil {
+				return err
+			}
+			config.CredentialHelpers = credentialHelpers
 			config.Parallelism = parallelism
+			config.PullParallelism = pullParallelism
+			config.PullRetries = pullRetries
 			config.InsecurePull = insecurePull
+			config.KeepTemp = keepTemp
+			config.HTTPProxy = httpProxy
+			config.HTTPSProxy = httpsProxy
+			config.NoProxy = noProxy
 			config.OutputFile = outputFile
+			config.Compress = compress
+			config.OutputDir = outputDir
 			config.OutputFormat = outputFormat
+			config.Color = useColor()
+			config.HTMLTemplateFile = htmlTemplate
+			config.OnlyFailures = onlyFailures
+			config.OnlyWarnings = onlyWarnings
+			config.SummaryOnly = summaryOnly
+			config.DryRun = dryRun
 			config.PrintExceptions = printExceptions
+			config.ReportUnusedExceptions = reportUnusedExceptions
+			config.ReportDuplicates = reportDuplicates
+			config.Dedupe = dedupe
+			config.ProgressInterval = progressInterval
 			config.PullSecret = pullSecretFile
+			config.AuthFile = authFile
+			if file := config.RegistryAuthFile(); file != "" {
+				fi, err := os.Stat(file)
+				if err != nil {
+					return fmt.Errorf("registry auth file: %w", err)
+				}
+				if err := checkSecretPerms(file, fi.Mode()); err != nil {
+					if strictPerms {
+						return fmt.Errorf("registry auth file: %w", err)
+					}
+					klog.Warningf("registry auth file: %v", err)
+				}
+			}
+			config.ResumeFile = resumeFile
+			config.Arch = arch
+			if config.Arch != "" {
+				if err := scan.ValidateArch(config.Arch); err != nil {
+					return fmt.Errorf("--arch: %w", err)
+				}
+			}
+			config.AllArches = allArches
+			config.ScanArchives = scanArchives
+			config.ArchiveMaxDepth = archiveMaxDepth
+			config.ArchiveMaxSize = archiveMaxSize << 20 // MiB to bytes.
 			config.Limit = limit
+			if sampleLargest < 0 {
+				return errors.New("--sample-largest must not be negative")
+			}
+			config.SampleLargest = sampleLargest
 			config.TimeLimit = timeLimit
+			config.PerBinaryTimeout = perBinaryTimeout
 			config.Verbose = verbose
+			config.Quiet = quiet
+			config.Checks = checks
+			config.CacheDir = cacheDir
+			config.CacheMaxSize = cacheMaxSize << 30 // GiB to bytes.
+			config.NoCache = noCache
+			if config.SummaryOnly && (config.OutputFormat == "sarif" || config.OutputFormat == "junit") {
+				return fmt.Errorf("--summary-only can't be used with %s output format", config.OutputFormat)
+			}
+			if config.ReportDuplicates {
+				switch {
+				case config.OutputFormat == "sarif" || config.OutputFormat == "junit":
+					return fmt.Errorf("--report-duplicates can't be used with %s output format", config.OutputFormat)
+				case config.HTMLTemplateFile != "":
+					return errors.New("--report-duplicates can't be used with --html-template")
+				case config.SummaryOnly:
+					return errors.New("--report-duplicates can't be used with --summary-only")
+				}
+			}
+			if config.OutputFile != "" && config.OutputDir != "" {
+				return errors.New("--output-file can't be used with --output-dir")
+			}
+			if config.Compress && config.OutputFile == "" {
+				return errors.New("--compress requires --output-file")
+			}
+			if config.HTMLTemplateFile != "" {
+				if config.OutputFormat != "html" {
+					return errors.New("--html-template requires --output-format html")
+				}
+				tmpl, err := scan.LoadHTMLTemplate(config.HTMLTemplateFile)
+				if err != nil {
+					return fmt.Errorf("--html-template: %w", err)
+				}
+				config.HTMLTemplate = tmpl
+			}
+			if config.DryRun && config.ResumeFile != "" {
+				return errors.New("--dry-run can't be used with --resume")
+			}
 			config.Log()
 			klog.InfoS("scan", "version", Commit)
+			scanStart = time.Now()
+			scan.SetPhase(scan.PhaseStarting)
 
 			// Validate the configuration.
 			err, warn := config.Validate()
@@ -105,7 +349,36 @@ func main() {
 				klog.Warning(warn)
 			}
 			if err != nil {
-				return fmt.Errorf("config has bad entries, please fix: %w", err)
+				return fmt.Errorf("config has bad entries, please fix:%s", formatErrors(err))
+			}
+			if err := validations.ValidateCheckNames(config.Checks); err != nil {
+				return err
+			}
+			if err := validations.ValidateGoCryptoBackends(config.GoCryptoBackends); err != nil {
+				return fmt.Errorf("config entry go_crypto_backends: %w", err)
+			}
+			if err := validations.ValidateSeverities(config.Severities); err != nil {
+				return fmt.Errorf("config section [severity]: %w", err)
+			}
+			for _, o := range config.ComponentOverrides {
+				if err := validations.ValidateCheckNames(o.DisableChecks); err != nil {
+					return fmt.Errorf("config section [[component]] name=%s: %w", o.Name, err)
+				}
+			}
+			if baselineFile != "" {
+				var err error
+				if baseline, err = scan.LoadBaseline(baselineFile); err != nil {
+					return err
+				}
+			}
+			if dumpConfig {
+				if err := writeConfig(&config); err != nil {
+					return err
+				}
+				return errEarlyExit
+			}
+			if cleanTempOlderThan > 0 {
+				scan.CleanStaleTempDirs(cleanTempOlderThan)
 			}
 
 			if cpuProfile != "" {
@@ -118,6 +391,41 @@ func main() {
 				}
 				klog.Info("collecting CPU profile data to ", cpuProfile)
 			}
+			if memProfile != "" {
+				// Fail early if the file can't be written.
+				f, err := os.Create(memProfile)
+				if err != nil {
+					return err
+				}
+				memProfileFile = f
+			}
+			if traceFile != "" {
+				f, err := os.Create(traceFile)
+				if err != nil {
+					return err
+				}
+				if err := trace.Start(f); err != nil {
+					return err
+				}
+				klog.Info("collecting execution trace to ", traceFile)
+			}
+			if pprofAddr != "" {
+				var err error
+				if pprofServer, err = startPprofServer(pprofAddr); err != nil {
+					return err
+				}
+			}
+			if metricsAddr != "" {
+				var err error
+				if metricsServer, err = startMetricsServer(metricsAddr); err != nil {
+					return err
+				}
+			}
+			if otelEndpoint != "" {
+				if err := tracing.Setup(otelEndpoint, Commit); err != nil {
+					return fmt.Errorf("--otel-endpoint: %w", err)
+				}
+			}
 
 			return nil
 		},
@@ -126,57 +434,181 @@ func main() {
 				pprof.StopCPUProfile()
 				klog.Info("CPU profile saved to ", cpuProfile)
 			}
+			if traceFile != "" {
+				trace.Stop()
+				klog.Info("execution trace saved to ", traceFile)
+			}
+			if memProfileFile != nil {
+				writeMemProfile(memProfileFile)
+			}
+			if pprofServer != nil {
+				pprofServer.Close()
+			}
+			if metricsServer != nil {
+				// Let a scrape in progress finish.
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				_ = metricsServer.Shutdown(ctx)
+				cancel()
+			}
+			flushTracing()
+			if config.Verbose {
+				logValidationCacheStats()
+			}
+			if baseline != nil {
+				baseline.Apply(&config, results)
+			}
+			if metadata {
+				config.Metadata = reportMetadata(cmd, &config)
+			}
+			scan.SetPhase(scan.PhaseReport)
 			scan.PrintResults(&config, results)
+			scan.SetPhase(scan.PhaseDone)
+			if scan.StoppedOnFailure() {
+				// There might be operational errors caused by
+				// stopping the scan, but it has failed anyway.
+				return errRunFailed
+			}
+			if scan.IsOperationalFailure(results) {
+				return errors.New("run failed due to operational errors")
+			}
+			if config.FailOnSeverity != types.SeverityUnknown {
+				if scan.IsFailedAtSeverity(results, config.FailOnSeverity) {
+					return errRunFailed
+				}
+				return nil
+			}
 			if scan.IsFailed(results) {
-				return errors.New("run failed")
+				return errRunFailed
+			}
+			isWarnings := scan.IsWarnings(results)
+			if baseline != nil {
+				isWarnings = baseline.IsWarnings(results)
 			}
-			if scan.IsWarnings(results) && config.FailOnWarnings {
-				return errors.New("run failed with warnings")
+			if isWarnings && config.FailOnWarnings {
+				return errRunWarnings
 			}
 			return nil
 		},
 	}
-	scanCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "use toml config file (default: "+defaultConfigFile+")")
+	scanCmd.PersistentFlags().StringSliceVarP(&configFiles, "config", "c", nil, "use toml config file (default: "+defaultConfigFile+"; repeatable, later files are merged into earlier ones)")
 	scanCmd.PersistentFlags().StringVarP(&configForVersion, "config-for-version", "V", "", "use embedded toml config file for specified version")
+	scanCmd.PersistentFlags().BoolVar(&requireConfig, "require-config", false, "fail if no config file is found, rather than using the embedded config")
 	scanCmd.PersistentFlags().StringSliceVar(&filterFiles, "filter-files", nil, "")
+	scanCmd.PersistentFlags().StringVar(&filterFileList, "filter-file-list", "", "read additional filter files entries from a file, one per line")
 	scanCmd.PersistentFlags().StringSliceVar(&filterDirs, "filter-dirs", nil, "")
 	scanCmd.PersistentFlags().StringSliceVar(&filterImages, "filter-images", nil, "")
-	scanCmd.PersistentFlags().StringSliceVar(&components, "components", nil, "")
+	scanCmd.PersistentFlags().StringSliceVar(&includeFiles, "include-files", nil, "only scan these files (same syntax as --filter-files)")
+	scanCmd.PersistentFlags().StringSliceVar(&includeDirs, "include-dirs", nil, "only scan files in these directories")
+	scanCmd.PersistentFlags().StringSliceVar(&registryMirrors, "registry-mirror", nil, "rewrite image references starting with source to use mirror instead (source=mirror, repeatable)")
+	scanCmd.PersistentFlags().StringSliceVar(&credentialHelpers, "credential-helper", nil, "get registry credentials from a docker credential helper, such as ecr-login (for docker-credential-ecr-login), before pulling images ([registry=]helper, where registry may be a pattern, repeatable)")
+	scanCmd.PersistentFlags().StringSliceVar(&filterRPMs, "exclude-rpm", nil, "exclude rpm packages (shell patterns, such as containerd*, are supported)")
+	scanCmd.PersistentFlags().StringVar(&baselineFile, "baseline", "", "report failures listed in this baseline file (see write-baseline) as warnings")
+	scanCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "cache image root filesystems in this directory, keyed by image digest")
+	scanCmd.PersistentFlags().Int64Var(&cacheMaxSize, "cache-max-size", 50, "maximum cache size, in GiB (0 for unlimited)")
+	scanCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "do not use cached image root filesystems (but update the cache)")
+	scanCmd.PersistentFlags().StringSliceVar(&checks, "checks", nil, "only run the specified checks (see list-checks)")
+	scanCmd.PersistentFlags().StringSliceVar(&components, "components", nil, "only scan these payload components (shell patterns, such as cluster-*, are supported)")
+	scanCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "only list the files to be scanned, without running any checks")
+	scanCmd.PersistentFlags().BoolVar(&dumpConfig, "dump-config", false, "print the effective (merged) config as toml, and exit")
 	scanCmd.PersistentFlags().BoolVar(&failOnWarnings, "fail-on-warnings", false, "fail on warnings")
+	scanCmd.PersistentFlags().StringVar(&failOnSeverity, "fail-on-severity", "", "only fail on failures of at least this severity (info, low, medium, high)")
+	scanCmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "stop the scan on the first failure (the report is then incomplete)")
+	scanCmd.PersistentFlags().BoolVar(&keepTemp, "keep-temp", false, "do not remove temporary directories and do not unmount images after the scan (for debugging)")
+	scanCmd.PersistentFlags().DurationVar(&cleanTempOlderThan, "clean-temp-older-than", 0, "on startup, remove temporary directories left by previous runs older than this (0 to disable)")
 	scanCmd.PersistentFlags().BoolVar(&insecurePull, "insecure-pull", false, "use insecure pull")
+	scanCmd.PersistentFlags().StringVar(&arch, "arch", "", "pull images for this architecture ("+strings.Join(scan.Arches, ", ")+"; default: the host architecture)")
+	scanCmd.PersistentFlags().BoolVar(&allArches, "all-arches", false, "scan all supported architectures of multi-arch images")
+	scanCmd.MarkFlagsMutuallyExclusive("arch", "all-arches")
+	scanCmd.PersistentFlags().StringVar(&httpProxy, "http-proxy", "", "HTTP proxy to use for registry access (overrides HTTP_PROXY)")
+	scanCmd.PersistentFlags().StringVar(&httpsProxy, "https-proxy", "", "HTTPS proxy to use for registry access (overrides HTTPS_PROXY)")
+	scanCmd.PersistentFlags().StringVar(&noProxy, "no-proxy", "", "comma-separated list of hosts to access without proxy (overrides NO_PROXY)")
 	scanCmd.PersistentFlags().IntVar(&limit, "limit", -1, "limit the number of pods scanned")
-	scanCmd.PersistentFlags().IntVar(&parallelism, "parallelism", 5, "how many pods to check at once")
+	scanCmd.PersistentFlags().IntVar(&sampleLargest, "sample-largest", 0, "for quick triage, only scan the N largest files of each image (or root), and report that the scan is sampled (0 means no limit)")
+	scanCmd.PersistentFlags().IntVar(&parallelism, "parallelism", 5, "how many pods (or, for node and container scans, rpms or files) to check at once")
+	scanCmd.PersistentFlags().IntVar(&pullParallelism, "pull-parallelism", 0, "how many images to pull at once (default: same as --parallelism)")
+	scanCmd.PersistentFlags().IntVar(&pullRetries, "pull-retries", 3, "how many times to retry a failed image pull (only for transient errors)")
 	scanCmd.PersistentFlags().StringVar(&outputFile, "output-file", "", "write report to file")
-	scanCmd.PersistentFlags().StringVar(&outputFormat, "output-format", "table", "output format (table, csv, markdown, html)")
+	scanCmd.PersistentFlags().BoolVar(&metadata, "metadata", false, "include the metadata (version, config, flags, scan target and time) into the report")
+	scanCmd.PersistentFlags().BoolVar(&compress, "compress", false, "gzip-compress the report written to --output-file (implied by a .gz file name suffix)")
+	scanCmd.PersistentFlags().StringVar(&outputDir, "output-dir", "", "write a separate report for every image, and an index, to this directory")
+	scanCmd.PersistentFlags().StringVar(&outputFormat, "output-format", "table", "output format (table, csv, markdown, html, json, sarif, junit)")
+	scanCmd.PersistentFlags().StringVar(&htmlTemplate, "html-template", "", "render the html report using a custom Go html/template `file`")
+	scanCmd.PersistentFlags().BoolVar(&color, "color", false, "color the table report even if stdout is not a terminal")
+	scanCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "do not color the table report (failures red, warnings yellow, successes green) even if stdout is a terminal")
+	scanCmd.MarkFlagsMutuallyExclusive("color", "no-color")
+	scanCmd.PersistentFlags().BoolVar(&onlyFailures, "only-failures", false, "only show failures in the report (the summary still counts all results)")
+	scanCmd.PersistentFlags().BoolVar(&onlyWarnings, "only-warnings", false, "only show warnings in the report (the summary still counts all results)")
+	scanCmd.PersistentFlags().BoolVar(&summaryOnly, "summary-only", false, "only print the summary (numbers of results by status)")
+	scanCmd.PersistentFlags().StringVar(&statusFile, "status-file", "", "write the numbers of results by status and the exit reason to a file, as JSON, at the end of the run (whatever the output format)")
 	scanCmd.PersistentFlags().StringVar(&pullSecretFile, "pull-secret", "", "pull secret to use for pulling images")
+	scanCmd.PersistentFlags().BoolVar(&strictPerms, "strict-perms", false, "fail (rather than warn) if the registry auth file is accessible by group or others")
+	scanCmd.PersistentFlags().StringVar(&authFile, "authfile", "", "registry credentials file, such as ~/.docker/config.json (takes precedence over --pull-secret; default: $REGISTRY_AUTH_FILE)")
+	scanCmd.PersistentFlags().BoolVar(&scanArchives, "scan-archives", false, "also scan files inside tar, tar.gz, zip, and jar archives (not for rpm scans)")
+	scanCmd.PersistentFlags().IntVar(&archiveMaxDepth, "archive-max-depth", 3, "maximum nesting level of archives to scan (for --scan-archives)")
+	scanCmd.PersistentFlags().Int64Var(&archiveMaxSize, "archive-max-size", 1024, "maximum total size of files extracted from a single archive, in MiB (for --scan-archives)")
+	scanCmd.PersistentFlags().StringVar(&resumeFile, "resume", "", "save payload scan state to a file, and skip images already saved there")
+	scanCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "do not log the scan results of every file (errors, warnings, and the report are not affected)")
+	scanCmd.PersistentFlags().DurationVar(&progressInterval, "progress-interval", 30*time.Second, "how often to log scan progress (0 to disable)")
 	scanCmd.PersistentFlags().DurationVar(&timeLimit, "time-limit", 1*time.Hour, "limit running time")
+	scanCmd.PersistentFlags().DurationVar(&perBinaryTimeout, "per-binary-timeout", 60*time.Second, "limit scan time of a single binary (0 for no limit)")
 	scanCmd.PersistentFlags().StringVar(&cpuProfile, "cpuprofile", "", "write CPU profile to file")
+	scanCmd.PersistentFlags().StringVar(&memProfile, "memprofile", "", "write heap profile to file (at the end of the scan)")
+	scanCmd.PersistentFlags().StringVar(&traceFile, "trace", "", "write execution trace to file")
+	scanCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "serve Prometheus metrics at /metrics on this address during the scan, such as :9090 (localhost, unless the host is given)")
+	scanCmd.PersistentFlags().StringVar(&otelEndpoint, "otel-endpoint", "", "export OpenTelemetry traces of the scan to this OTLP/HTTP collector, such as http://localhost:4318")
+	scanCmd.PersistentFlags().StringVar(&pprofAddr, "pprof-addr", "", "serve pprof endpoints on this address during the scan, such as :6060 (localhost, unless the host is given)")
 	scanCmd.PersistentFlags().BoolVarP(&printExceptions, "print-exceptions", "p", false, "display exception list")
+	scanCmd.PersistentFlags().BoolVar(&reportDuplicates, "report-duplicates", false, "report binaries which are identical (by SHA-256 digest) in more than one image, and the images containing them")
+	scanCmd.PersistentFlags().BoolVar(&dedupe, "dedupe", false, "collapse the results of the same binary (by SHA-256 digest) at several paths of an image, with the same status, into a single row listing all the paths")
+	scanCmd.PersistentFlags().BoolVar(&reportUnusedExceptions, "report-unused-exceptions", false, "after the scan, print config exceptions which did not match any file")
 
 	scanPayload := &cobra.Command{
 		Use:          "payload [image pull spec]",
 		SilenceUsage: true,
+		Args:         cobra.MaximumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return scan.ValidateApplicationDependencies(applicationDeps)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, cancel := context.WithTimeout(context.Background(), timeLimit)
+			ctx, cancel := newContext(&config)
 			defer cancel()
 			config.FromURL, _ = cmd.Flags().GetString("url")
 			config.FromFile, _ = cmd.Flags().GetString("file")
-			if config.FromURL == "" && config.FromFile == "" {
-				return errors.New("either -u, --url or -f, --file option is required")
+			config.FromMapping, _ = cmd.Flags().GetString("mapping")
+			if len(args) > 0 {
+				if config.FromURL != "" || config.FromFile != "" || config.FromMapping != "" {
+					return errors.New("image pull spec argument can't be used with -u, --url, -f, --file, or --mapping")
+				}
+				config.FromURL = args[0]
+			}
+			if config.FromURL == "" && config.FromFile == "" && config.FromMapping == "" {
+				return errors.New("either image pull spec argument, -u, --url, -f, --file, or --mapping option is required")
+			}
+			if config.FromURL != "" {
+				config.FromURL = scan.ReleaseImage(config.FromURL)
 			}
 			config.PrintExceptions, _ = cmd.Flags().GetBool("print-exceptions")
-			config.UseRPMScan, _ = cmd.Flags().GetBool("rpm-scan")
-			results = scan.RunPayloadScan(ctx, &config)
-			return nil
+			rpmScan, err := rpmScanFlag(cmd, &config)
+			if err != nil {
+				return err
+			}
+			config.UseRPMScan = rpmScan
+			if dump, _ := cmd.Flags().GetBool("dump-images"); dump {
+				if err := scan.DumpImages(ctx, &config); err != nil {
+					return err
+				}
+				return errEarlyExit
+			}
+			results, err = scan.RunPayloadScan(ctx, &config)
+			return err
 		},
 	}
-	scanPayload.Flags().StringP("url", "u", "", "payload url")
+	scanPayload.Flags().StringP("url", "u", "", "payload url (release image pull spec, or a bare sha256 digest of an ocp-release image)")
 	scanPayload.Flags().StringP("file", "f", "", "payload from json file")
-	scanPayload.MarkFlagsMutuallyExclusive("url", "file")
+	scanPayload.Flags().String("mapping", "", "scan the images from an oc-mirror mapping file (with source=destination lines), pulling the destination images")
+	scanPayload.MarkFlagsMutuallyExclusive("url", "file", "mapping")
 	scanPayload.Flags().Bool("rpm-scan", false, "use RPM scan (same as during node scan)")
+	scanPayload.Flags().Bool("dump-images", false, "only print the payload images to be scanned (tag name and pull spec), and exit")
 
 	scanNode := &cobra.Command{
 		Use:          "node --root /myroot [--walk-scan]",
@@ -185,17 +617,34 @@ func main() {
 			return scan.ValidateApplicationDependencies(applicationDepsNodeScan)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, cancel := context.WithTimeout(context.Background(), timeLimit)
+			ctx, cancel := newContext(&config)
 			defer cancel()
 			root, _ := cmd.Flags().GetString("root")
 			walkScan, _ := cmd.Flags().GetBool("walk-scan")
 			config.UseRPMScan = !walkScan
+			config.FollowSymlinks, _ = cmd.Flags().GetBool("follow-symlinks")
+			if config.FollowSymlinks && walkScan {
+				// The walk finds all the files anyway.
+				return errors.New("--follow-symlinks can't be used with --walk-scan")
+			}
+			if config.SampleLargest > 0 && !walkScan {
+				// The rpm scan does not support sampling.
+				return errors.New("--sample-largest can only be used with --walk-scan")
+			}
+			if since, _ := cmd.Flags().GetString("modified-since"); since != "" {
+				var err error
+				if config.ModifiedSince, err = scan.ParseModifiedSince(since); err != nil {
+					return fmt.Errorf("--modified-since: %w", err)
+				}
+			}
 			results = scan.RunNodeScan(ctx, &config, root)
 			return nil
 		},
 	}
 	scanNode.Flags().String("root", "", "root path to scan")
 	scanNode.Flags().Bool("walk-scan", false, "scan all files using directory tree walk")
+	scanNode.Flags().Bool("follow-symlinks", false, "scan the targets of symlinks from rpms (under the root), rather than skipping the symlinks")
+	scanNode.Flags().String("modified-since", "", "skip files not modified since this `timestamp` (RFC 3339, such as 2024-01-02T15:04:05Z, or a date), reporting them as unchanged")
 	_ = scanNode.MarkFlagRequired("root")
 
 	scanImage := &cobra.Command{
@@ -203,24 +652,196 @@ func main() {
 		Aliases:      []string{"operator"},
 		SilenceUsage: true,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if rootfs, _ := cmd.Flags().GetString("rootfs"); rootfs != "" {
+				// No pull, so no need for oc or podman.
+				return scan.ValidateApplicationDependencies(applicationDepsRootfsScan)
+			}
 			return scan.ValidateApplicationDependencies(applicationDeps)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, cancel := context.WithTimeout(context.Background(), timeLimit)
+			ctx, cancel := newContext(&config)
 			defer cancel()
+			if rootfs, _ := cmd.Flags().GetString("rootfs"); rootfs != "" {
+				if config.AllArches {
+					return errors.New("--all-arches can't be used with --rootfs")
+				}
+				if cmd.Flags().Changed("previous-image") {
+					return errors.New("--previous-image can't be used with --rootfs")
+				}
+				if rpmScan, _ := cmd.Flags().GetBool("rpm-scan"); rpmScan {
+					return errors.New("--rpm-scan can't be used with --rootfs (use scan node instead)")
+				}
+				results = scan.RunRootfsScan(ctx, &config, rootfs)
+				return nil
+			}
 			config.ContainerImage, _ = cmd.Flags().GetString("spec")
-			config.UseRPMScan, _ = cmd.Flags().GetBool("rpm-scan")
+			config.FromArchive, _ = cmd.Flags().GetString("from-archive")
+			specFile, _ := cmd.Flags().GetString("spec-file")
+			if config.ContainerImage == "-" {
+				specFile = "-"
+			}
+			if config.ContainerImage == "" && config.FromArchive == "" && specFile == "" {
+				return errors.New("either --spec, --spec-file, --from-archive, or --rootfs option is required")
+			}
+			if specFile != "" {
+				images, err := readImageList(specFile)
+				if err != nil {
+					return err
+				}
+				config.ContainerImages = images
+			}
+			if config.FromArchive != "" && config.AllArches {
+				return errors.New("--all-arches can't be used with --from-archive")
+			}
+			if config.FromArchive != "" {
+				// Check the archive format early.
+				transport, err := podman.ArchiveTransport(config.FromArchive)
+				if err != nil {
+					return err
+				}
+				config.FromArchiveTransport = transport
+			}
+			config.PreviousImage, _ = cmd.Flags().GetString("previous-image")
+			config.PreviousReport, _ = cmd.Flags().GetString("previous-report")
+			if config.PreviousImage != "" && (config.ContainerImage == "" || len(config.ContainerImages) > 0) {
+				return errors.New("--previous-image requires --spec")
+			}
+			rpmScan, err := rpmScanFlag(cmd, &config)
+			if err != nil {
+				return err
+			}
+			config.UseRPMScan = rpmScan
+			if config.PreviousImage != "" && config.UseRPMScan {
+				return errors.New("--previous-image can't be used with --rpm-scan")
+			}
 			results = scan.RunOperatorScan(ctx, &config)
 			return nil
 		},
 	}
-	scanImage.Flags().String("spec", "", "payload url")
+	scanImage.Flags().String("spec", "", "image pull spec (use - to read a list of images from stdin)")
+	scanImage.Flags().String("spec-file", "", "read a list of image pull specs from a file, one per line")
+	scanImage.Flags().String("from-archive", "", "scan image from OCI or docker archive file (such as created by podman save)")
+	scanImage.Flags().String("rootfs", "", "scan an image root filesystem already extracted to this directory (no pull or extraction)")
+	scanImage.MarkFlagsMutuallyExclusive("spec", "spec-file", "from-archive", "rootfs")
 	scanImage.Flags().Bool("rpm-scan", false, "use RPM scan (same as during node scan)")
-	_ = scanImage.MarkFlagRequired("spec")
+	scanImage.Flags().String("previous-image", "", "only scan files changed since this (previously scanned) image, and use previous results for the rest")
+	scanImage.Flags().String("previous-report", "", "JSON report of the previous image scan (required for --previous-image)")
+	scanImage.MarkFlagsRequiredTogether("previous-image", "previous-report")
+	scanManifest := &cobra.Command{
+		Use:          "manifest <file or directory>",
+		Short:        "Scan the container images used by Kubernetes manifests",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return scan.ValidateApplicationDependencies(applicationDeps)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := newContext(&config)
+			defer cancel()
+			images, err := scan.ReadManifestImages(args[0])
+			if err != nil {
+				return err
+			}
+			klog.Infof("found %d images in %s", len(images), args[0])
+			config.ContainerImages = images
+			rpmScan, err := rpmScanFlag(cmd, &config)
+			if err != nil {
+				return err
+			}
+			config.UseRPMScan = rpmScan
+			results = scan.RunOperatorScan(ctx, &config)
+			return nil
+		},
+	}
+	scanManifest.Flags().Bool("rpm-scan", false, "use RPM scan (same as during node scan)")
+	scanContainer := &cobra.Command{
+		Use:          "container <name or id>",
+		Short:        "Scan a running or stopped container",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return scan.ValidateApplicationDependencies(applicationDepsContainerScan)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := newContext(&config)
+			defer cancel()
+			rpmScan, err := rpmScanFlag(cmd, &config)
+			if err != nil {
+				return err
+			}
+			config.UseRPMScan = rpmScan
+			results = scan.RunContainerScan(ctx, &config, args[0])
+			return nil
+		},
+	}
+	scanContainer.Flags().Bool("rpm-scan", false, "use RPM scan (same as during node scan)")
 
+	listChecks := &cobra.Command{
+		Use:                "list-checks",
+		Short:              "List available checks",
+		PersistentPreRunE:  func(*cobra.Command, []string) error { return nil },
+		PersistentPostRunE: func(*cobra.Command, []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return scan.PrintValidations(outputFormat)
+		},
+	}
+
+	diffCmd := &cobra.Command{
+		Use:                "diff <old.json> <new.json>",
+		Short:              "Compare two JSON scan reports",
+		Args:               cobra.ExactArgs(2),
+		SilenceUsage:       true,
+		PersistentPreRunE:  func(*cobra.Command, []string) error { return nil },
+		PersistentPostRunE: func(*cobra.Command, []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			newFailures, newWarnings, err := scan.DiffReports(args[0], args[1], outputFormat)
+			if err != nil {
+				return err
+			}
+			if newFailures > 0 {
+				return errRunFailed
+			}
+			if newWarnings > 0 && failOnWarnings {
+				return errRunWarnings
+			}
+			return nil
+		},
+	}
+
+	writeBaseline := &cobra.Command{
+		Use:                "write-baseline <report.json>",
+		Short:              "Write a baseline file (for --baseline) with all failures from a JSON scan report",
+		Args:               cobra.ExactArgs(1),
+		SilenceUsage:       true,
+		PersistentPreRunE:  func(*cobra.Command, []string) error { return nil },
+		PersistentPostRunE: func(*cobra.Command, []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return scan.WriteBaseline(args[0], outputFile)
+		},
+	}
+
+	verifyConfig := &cobra.Command{
+		Use:   "verify-config",
+		Short: "Validate the config and print a summary, without scanning",
+		Args:  cobra.NoArgs,
+		// The config is loaded and validated by scan's PersistentPreRunE;
+		// there are no scan results to print.
+		PersistentPostRunE: func(*cobra.Command, []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			klog.Info("config is valid")
+			return scan.PrintConfigSummary(&config, outputFormat)
+		},
+	}
+
+	scanCmd.AddCommand(diffCmd)
+	scanCmd.AddCommand(writeBaseline)
+	scanCmd.AddCommand(verifyConfig)
+	scanCmd.AddCommand(listChecks)
 	scanCmd.AddCommand(scanPayload)
 	scanCmd.AddCommand(scanNode)
 	scanCmd.AddCommand(scanImage)
+	scanCmd.AddCommand(scanManifest)
+	scanCmd.AddCommand(scanContainer)
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(scanCmd)
@@ -230,28 +851,352 @@ func main() {
 	klog.InitFlags(klogFlags)
 	rootCmd.PersistentFlags().AddGoFlagSet(klogFlags)
 
-	if err := rootCmd.Execute(); err != nil {
-		klog.Fatalf("Error: %v\n", err)
+	err := rootCmd.Execute()
+	shutdownTracing()
+	if statusFile != "" && !errors.Is(err, errEarlyExit) {
+		var opErr error // Only operational errors are worth reporting.
+		if err != nil && exitCode(err) == exitError {
+			opErr = err
+		}
+		if serr := scan.WriteStatusFile(statusFile, results, exitReason(err), opErr); serr != nil {
+			klog.Errorf("can't write status file: %v", serr)
+			if err == nil {
+				err = serr
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, errEarlyExit) {
+			return
+		}
+		klog.Errorf("Error: %v", err)
+		klog.Flush()
+		os.Exit(exitCode(err))
+	}
+}
+
+// flushTracing exports the trace spans of the scan, if tracing is enabled
+// (see --otel-endpoint). Errors are logged but otherwise ignored, as the
+// scan is done anyway.
+func flushTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := tracing.Flush(ctx); err != nil {
+		klog.Warningf("can't export traces: %v", err)
+	}
+}
+
+// shutdownTracing exports the remaining trace spans, if tracing is enabled
+// (see --otel-endpoint). Errors are logged but otherwise ignored, as the
+// scan is done anyway.
+func shutdownTracing() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := tracing.Shutdown(ctx); err != nil {
+		klog.Warningf("can't export traces: %v", err)
+	}
+}
+
+// writeMemProfile writes the heap profile to f, and closes it.
+// Errors are logged but otherwise ignored, as the scan is done anyway.
+func writeMemProfile(f *os.File) {
+	runtime.GC() // Get up-to-date statistics.
+	err := pprof.WriteHeapProfile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		klog.Warningf("can't write heap profile: %v", err)
+		return
+	}
+	klog.Info("heap profile saved to ", f.Name())
+}
+
+// logValidationCacheStats logs the validation cache hit rate.
+func logValidationCacheStats() {
+	hits, misses := validations.CacheStats()
+	if total := hits + misses; total > 0 {
+		klog.InfoS("validation cache", "hits", hits, "misses", misses, "hit_rate", fmt.Sprintf("%.1f%%", float64(hits)*100/float64(total)))
+	}
+}
+
+// startPprofServer starts an HTTP server with pprof endpoints (under
+// /debug/pprof/) on addr. If addr has no host part (such as ":6060"),
+// the server is bound to localhost.
+func startPprofServer(addr string) (*http.Server, error) {
+	ln, err := listenLocal(addr)
+	if err != nil {
+		return nil, fmt.Errorf("bad --pprof-addr: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	srv := serveHTTP("pprof", ln, mux)
+	klog.Infof("pprof server listening on http://%s/debug/pprof/", ln.Addr())
+	return srv, nil
+}
+
+// startMetricsServer starts an HTTP server serving the Prometheus metrics
+// at /metrics, on addr (see startPprofServer for the address handling).
+func startMetricsServer(addr string) (*http.Server, error) {
+	ln, err := listenLocal(addr)
+	if err != nil {
+		return nil, fmt.Errorf("bad --metrics-addr: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", scan.MetricsHandler())
+	srv := serveHTTP("metrics", ln, mux)
+	klog.Infof("metrics server listening on http://%s/metrics", ln.Addr())
+	return srv, nil
+}
+
+// listenLocal listens on the TCP address addr, which is on localhost
+// unless the host is given.
+func listenLocal(addr string) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		addr = net.JoinHostPort("localhost", port)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serveHTTP serves handler on ln in the background. The name is
+// used to log the server errors.
+func serveHTTP(name string, ln net.Listener, handler http.Handler) *http.Server {
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Warningf("%s server: %v", name, err)
+		}
+	}()
+	return srv
+}
+
+// newContext returns a context for the scan, which is canceled after
+// --time-limit, or upon receiving SIGINT or SIGTERM. In the latter case,
+// the scan is stopped (and any subprocesses are killed) gracefully; the
+// second signal terminates the program immediately. With --fail-fast,
+// it is also canceled on the first failure (other than the known ones,
+// and those below --fail-on-severity).
+func newContext(cfg *types.Config) (context.Context, context.CancelFunc) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCtx.Done()
+		// Restore the default signal behavior.
+		stop()
+	}()
+	ctx, cancel := context.WithTimeout(sigCtx, timeLimit)
+	if !failFast {
+		return ctx, func() {
+			cancel()
+			stop()
+		}
+	}
+	known := func(res *types.ScanResult) bool {
+		// Known failures, and those which do not fail the run,
+		// do not stop the scan.
+		return (baseline != nil && baseline.Has(res)) ||
+			scan.IsBelowSeverity(res, cfg.FailOnSeverity)
+	}
+	ctx, ffCancel := scan.WithFailFast(ctx, known)
+	return ctx, func() {
+		ffCancel()
+		cancel()
+		stop()
+	}
+}
+
+// readImageList reads a list of images from a file, or from stdin
+// if file is "-".
+func readImageList(file string) ([]string, error) {
+	if file == "-" {
+		return scan.ReadImageList(os.Stdin)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	images, err := scan.ReadImageList(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
 	}
+	return images, nil
+}
+
+// checkSecretPerms returns an error if the file with secrets (such as
+// a pull secret), having a given mode, is accessible by group or others.
+func checkSecretPerms(file string, mode os.FileMode) error {
+	if perm := mode.Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("%s has too permissive mode %04o (should not be accessible by group or others, such as 0600)", file, perm)
+	}
+	return nil
+}
+
+// rpmScanFlag returns the value of the --rpm-scan flag, or an error if
+// it is used with --sample-largest, which the rpm scan does not support
+// (same as for "scan node" without --walk-scan).
+func rpmScanFlag(cmd *cobra.Command, cfg *types.Config) (bool, error) {
+	rpmScan, _ := cmd.Flags().GetBool("rpm-scan")
+	if rpmScan && cfg.SampleLargest > 0 {
+		return false, errors.New("--sample-largest can't be used with --rpm-scan")
+	}
+	return rpmScan, nil
+}
+
+// readFilterFileList reads filter files entries from a file, one per line.
+// Empty lines and lines starting with # are ignored.
+func readFilterFileList(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list, err := scan.ReadList(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	klog.Infof("using %d filter files entries from %s", len(list), file)
+	return list, nil
+}
+
+// useColor tells if the table report is to be colored: always with
+// --color, and otherwise if stdout is a terminal, unless disabled by
+// --no-color or the NO_COLOR environment variable (see https://no-color.org).
+func useColor() bool {
+	switch {
+	case color:
+		return true
+	case noColor, os.Getenv("NO_COLOR") != "":
+		return false
+	}
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// reportMetadata returns the metadata describing how the report of
+// the command cmd is produced (see --metadata).
+func reportMetadata(cmd *cobra.Command, config *types.Config) *types.ReportMetadata {
+	meta := &types.ReportMetadata{
+		Version:          Commit,
+		ConfigFile:       usedConfigFile,
+		ConfigForVersion: configForVersion,
+		Command:          cmd.CommandPath(),
+		Time:             scanStart,
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		value := f.Value.String()
+		if strings.HasSuffix(f.Name, "-proxy") {
+			value = types.RedactProxy(value)
+		}
+		meta.Flags = append(meta.Flags, "--"+f.Name+"="+value)
+	})
+	if len(config.ContainerImages) > 0 {
+		meta.Target = strings.Join(config.ContainerImages, ",")
+		return meta
+	}
+	for _, target := range []string{config.FromURL, config.FromFile, config.FromMapping, config.FromArchive, config.ContainerImage} {
+		if target != "" {
+			meta.Target = target
+			break
+		}
+	}
+	return meta
+}
+
+// writeConfig writes the effective config file entries as toml to the
+// output file, or to stdout if the output file is not set.
+func writeConfig(config *types.Config) error {
+	w := os.Stdout
+	if config.OutputFile != "" {
+		f, err := os.Create(config.OutputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return toml.NewEncoder(w).Encode(&config.ConfigFile)
+}
+
+// undecodedError returns an error listing all unknown keys found in the
+// config file, or nil if there are none.
+func undecodedError(file string, keys []toml.Key) error {
+	var err error
+	for _, key := range keys {
+		multierr.AppendInto(&err, fmt.Errorf("unknown key %q", key.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("config file %q has unknown keys:%s", file, formatErrors(err))
+	}
+	return nil
+}
+
+// formatErrors formats a (possibly multi-) error as a list, one error per line.
+func formatErrors(err error) string {
+	var msg strings.Builder
+	for _, e := range multierr.Errors(err) {
+		msg.WriteString("\n  - " + e.Error())
+	}
+	return msg.String()
+}
+
+// decodeConfigFile decodes the config file, which is either YAML (if it
+// has .yaml or .yml extension) or TOML. Unknown keys are treated as errors.
+func decodeConfigFile(file string, config *types.ConfigFile) error {
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		// UnmarshalStrict errors out on unknown keys (typos).
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return fmt.Errorf("can't parse config file %q: %w", file, err)
+		}
+		return nil
+	}
+
+	res, err := toml.DecodeFile(file, config)
+	if err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			// Include the line and column, with some context.
+			return fmt.Errorf("can't parse config file %q: %s", file, perr.ErrorWithPosition())
+		}
+		return fmt.Errorf("can't parse config file %q: %w", file, err)
+	}
+	return undecodedError(file, res.Undecoded())
 }
 
 func getConfig(config *types.ConfigFile) error {
 	// Handle --config.
-	file := configFile
-	if file == "" {
-		file = defaultConfigFile
+	files := configFiles
+	if len(files) == 0 {
+		files = []string{defaultConfigFile}
 	}
-	res, err := toml.DecodeFile(file, &config)
+	err := decodeConfigFile(files[0], config)
 	if err == nil {
-		klog.Infof("using config file: %v", file)
-		if un := res.Undecoded(); len(un) != 0 {
-			return fmt.Errorf("unknown keys in config: %+v", un)
-		}
-	} else if errors.Is(err, os.ErrNotExist) && configFile == "" {
+		klog.Infof("using config file: %v", files[0])
+		usedConfigFile = strings.Join(files, ",")
+		config.ExpandEnv()
+	} else if errors.Is(err, os.ErrNotExist) && len(configFiles) == 0 {
 		// When --config not specified and defaultConfigFile is not found,
-		// fall back to embedded config.
-		klog.Info("using embedded config")
-		res, err = toml.Decode(embeddedConfig, &config)
+		// fall back to embedded config (unless --require-config is set).
+		if requireConfig {
+			return fmt.Errorf("--require-config is set, but no config file is found (%s does not exist, and --config is not set)", defaultConfigFile)
+		}
+		klog.Warningf("%s not found, and --config is not set, using embedded config (use --require-config to make this an error)", defaultConfigFile)
+		usedConfigFile = "embedded"
+		res, err := toml.Decode(embeddedConfig, &config)
 		if err != nil { // Should never happen.
 			panic("invalid embedded config: " + err.Error())
 		}
@@ -260,7 +1205,20 @@ func getConfig(config *types.ConfigFile) error {
 		}
 	} else {
 		// Otherwise, error out.
-		return fmt.Errorf("can't parse config file %q: %w", file, err)
+		return err
+	}
+
+	// Merge the other --config files, in order.
+	for _, file := range files[1:] {
+		addConfig := &types.ConfigFile{}
+		if err := decodeConfigFile(file, addConfig); err != nil {
+			return err
+		}
+		klog.Infof("adding config file: %v", file)
+		addConfig.ExpandEnv()
+		if warn := config.Layer(addConfig); warn != nil {
+			klog.Warningf("config file %q:%s", file, formatErrors(warn))
+		}
 	}
 
 	if configForVersion != "" {
@@ -271,7 +1229,7 @@ func getConfig(config *types.ConfigFile) error {
 		}
 		klog.Infof("adding rules from embedded config for %s", configForVersion)
 		addConfig := &types.ConfigFile{}
-		res, err = toml.Decode(string(cfg), &addConfig)
+		res, err := toml.Decode(string(cfg), &addConfig)
 		if err != nil { // Should never happen.
 			panic("invalid embedded config: " + err.Error())
 		}
@@ -0,0 +1,75 @@
+// Package checkpayload is the Go API to run check-payload scans from other
+// programs, rather than running the check-payload binary.
+//
+// A scan is configured by a Config (the same as used by the command line
+// tool, including the config file entries), and returns a list of
+// ScanResults (one per image, or a single one for a node scan). The zero
+// Config is usable, and means a scan with no file filters or exceptions,
+// one image (or file) scanned at a time, and no time limits. The scans
+// never exit the program; errors are either returned, or reported in the
+// results. To get the scan results as soon as they are available
+// (in addition to all of them being returned), set Config.OnResult.
+//
+// The external programs used by the scans must be installed: nm and, for
+// image and payload scans, podman and oc, or, for rpm node scans, rpm.
+package checkpayload
+
+import (
+	"context"
+
+	"github.com/openshift/check-payload/internal/scan"
+	"github.com/openshift/check-payload/internal/types"
+)
+
+type (
+	// Config is the scan configuration.
+	Config = types.Config
+	// ConfigFile is the part of Config which is read from a config file.
+	ConfigFile = types.ConfigFile
+	// ScanResults are the results of a single image (or node) scan.
+	ScanResults = types.ScanResults
+	// ScanResult is the result of a single file scan.
+	ScanResult = types.ScanResult
+	// Severity is the severity of a failed check.
+	Severity = types.Severity
+)
+
+// RunPayloadScan scans the images of the release payload given by
+// cfg.FromURL (a release image pull spec), cfg.FromFile (the output
+// of "oc adm release info --output json --pullspecs"), or cfg.FromMapping
+// (an oc-mirror mapping file). It only returns an error if the payload
+// can't be read.
+func RunPayloadScan(ctx context.Context, cfg *Config) ([]*ScanResults, error) {
+	return scan.RunPayloadScan(ctx, cfg)
+}
+
+// RunImageScan scans the container images given by cfg.ContainerImages (or
+// cfg.ContainerImage), or the image archive given by cfg.FromArchive.
+func RunImageScan(ctx context.Context, cfg *Config) []*ScanResults {
+	return scan.RunOperatorScan(ctx, cfg)
+}
+
+// RunNodeScan scans the node root filesystem mounted at root: the files
+// of all installed rpm packages if cfg.UseRPMScan is set, or all files
+// otherwise.
+func RunNodeScan(ctx context.Context, cfg *Config, root string) []*ScanResults {
+	return scan.RunNodeScan(ctx, cfg, root)
+}
+
+// IsFailed tells if any of the results is a failure.
+func IsFailed(results []*ScanResults) bool {
+	return scan.IsFailed(results)
+}
+
+// IsWarnings tells if any of the results is a warning.
+func IsWarnings(results []*ScanResults) bool {
+	return scan.IsWarnings(results)
+}
+
+// IsFailedAtSeverity tells if any of the results is a failure with
+// the severity of at least threshold (failures not caused by a check
+// are considered to be of the highest severity). Warnings are not
+// considered.
+func IsFailedAtSeverity(results []*ScanResults, threshold Severity) bool {
+	return scan.IsFailedAtSeverity(results, threshold)
+}
@@ -0,0 +1,45 @@
+package checkpayload_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/check-payload/pkg/checkpayload"
+)
+
+func TestRunNodeScan(t *testing.T) {
+	exe, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skip(err)
+	}
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr/bin/true"), exe, 0o755))
+
+	// The zero config is usable.
+	cfg := &checkpayload.Config{Checks: []string{"dyn-linked"}}
+	results := checkpayload.RunNodeScan(context.Background(), cfg, root)
+	require.Len(t, results, 1)
+	var bin *checkpayload.ScanResult
+	for _, res := range results[0].Items {
+		if res.Path == "/usr/bin/true" {
+			bin = res
+		}
+	}
+	require.NotNil(t, bin)
+	assert.True(t, bin.IsSuccess())
+	// The root has no openssl library.
+	assert.True(t, checkpayload.IsFailed(results))
+	assert.False(t, checkpayload.IsWarnings(results))
+}
+
+func TestRunPayloadScanBadPayload(t *testing.T) {
+	cfg := &checkpayload.Config{FromFile: filepath.Join(t.TempDir(), "missing.json")}
+	_, err := checkpayload.RunPayloadScan(context.Background(), cfg)
+	assert.Error(t, err)
+}